@@ -2,13 +2,15 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/pewe21/library"
@@ -20,6 +22,10 @@ type AuthService struct {
 	JWTSecret             string
 	RefreshSecret         string
 	UserServiceGrpcClient userProto.UserClient
+	Store                 RefreshTokenStore
+	Attempts              LoginAttemptStore
+	Keys                  *KeySet
+	TOTPKey               []byte
 }
 
 type tokenChan struct {
@@ -28,11 +34,15 @@ type tokenChan struct {
 	tokenType string
 }
 
-func NewAuthService(jwtSecret string, grpcClient userProto.UserClient, refreshSecret string) *AuthService {
+func NewAuthService(jwtSecret string, grpcClient userProto.UserClient, refreshSecret string, store RefreshTokenStore, attempts LoginAttemptStore, keys *KeySet, totpKey []byte) *AuthService {
 	return &AuthService{
 		JWTSecret:             jwtSecret,
 		UserServiceGrpcClient: grpcClient,
 		RefreshSecret:         refreshSecret,
+		Store:                 store,
+		Attempts:              attempts,
+		Keys:                  keys,
+		TOTPKey:               totpKey,
 	}
 }
 
@@ -45,6 +55,24 @@ func (s *AuthService) RegisterRoutes(r *mux.Router) {
 
 	// v1/auth/refresh
 	r.HandleFunc("/refresh", library.CreateHandler(s.handleRefreshAuth)).Methods(http.MethodPost, http.MethodOptions)
+
+	// v1/auth/logout
+	r.HandleFunc("/logout", library.CreateHandler(s.handleLogoutAuth)).Methods(http.MethodPost, http.MethodOptions)
+
+	// v1/auth/.well-known/jwks.json
+	r.HandleFunc("/.well-known/jwks.json", library.CreateHandler(s.handleGetJWKS)).Methods(http.MethodGet, http.MethodOptions)
+
+	// v1/auth/logout-all
+	r.HandleFunc("/logout-all", library.CreateHandler(library.JWTMiddleware(s.handleLogoutAllAuth))).Methods(http.MethodPost, http.MethodOptions)
+
+	// v1/auth/2fa/setup --> buat secret TOTP baru, return otpauth uri untuk di scan authenticator app
+	r.HandleFunc("/2fa/setup", library.CreateHandler(library.JWTMiddleware(s.handleTwoFactorSetup))).Methods(http.MethodPost, http.MethodOptions)
+
+	// v1/auth/2fa/verify --> konfirmasi kode TOTP pertama, 2FA aktif dan recovery code dikirim sekali
+	r.HandleFunc("/2fa/verify", library.CreateHandler(library.JWTMiddleware(s.handleTwoFactorVerify))).Methods(http.MethodPost, http.MethodOptions)
+
+	// v1/auth/2fa/login --> langkah kedua login, tukar challenge token + kode TOTP/recovery code dengan access/refresh token
+	r.HandleFunc("/2fa/login", library.CreateHandler(s.handleTwoFactorLogin)).Methods(http.MethodPost, http.MethodOptions)
 }
 
 func (s *AuthService) handleRegisterAuth(w http.ResponseWriter, r *http.Request) (int, error) {
@@ -65,13 +93,17 @@ func (s *AuthService) handleRegisterAuth(w http.ResponseWriter, r *http.Request)
 		return http.StatusBadRequest, fmt.Errorf("invalid user detail")
 	}
 
-	/////////////////////////////
-	//TODO validasi input user//
-	///////////////////////////
+	v := library.NewValidator()
+	v.Field("username", user.Username, library.UsernameRules()...)
+	v.Field("name", user.Name, library.NameRules()...)
+	v.Field("password", user.Password, library.PasswordRules(user.Username)...)
+	if err := v.Err(); err != nil {
+		return http.StatusBadRequest, err
+	}
 
 	uuid := uuid.NewString()
 
-	hashPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), 12)
+	hashPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), BCRYPT_COST)
 	if err != nil {
 		log.Println("Error when hashing password:", err)
 		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
@@ -88,7 +120,9 @@ func (s *AuthService) handleRegisterAuth(w http.ResponseWriter, r *http.Request)
 	_, err = s.UserServiceGrpcClient.CreateUser(r.Context(), in)
 	if err != nil {
 		log.Println("Error when calling s.GrpcClient:", err)
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		// username yang sudah dipakai datang sebagai username_taken dari userService --> 409
+		appErr := library.FromGrpcError(err)
+		return appErr.HttpStatus, appErr
 	}
 
 	resp := library.NewResp("user created!", nil)
@@ -99,9 +133,6 @@ func (s *AuthService) handleRegisterAuth(w http.ResponseWriter, r *http.Request)
 }
 
 func (s *AuthService) handleLoginAuth(w http.ResponseWriter, r *http.Request) (int, error) {
-	ch := make(chan tokenChan)
-	defer close(ch)
-
 	log.Println("hit handle register auth")
 
 	body, err := io.ReadAll(r.Body)
@@ -118,159 +149,288 @@ func (s *AuthService) handleLoginAuth(w http.ResponseWriter, r *http.Request) (i
 		return http.StatusBadRequest, fmt.Errorf("invalid user creds")
 	}
 
-	/////////////////////////////
-	//TODO validasi input user//
-	///////////////////////////
+	// rule register tidak dipakai disini supaya akun lama yang dibuat sebelum ada validasi tetap bisa login,
+	// cukup batasi panjang input sebelum bcrypt dan counter login gagal
+	v := library.NewValidator()
+	v.Field("username", user.Username, library.Required(), library.MaxLength(255))
+	v.Field("password", user.Password, library.Required(), library.MaxBytes(library.PASSWORD_MAX_BYTES))
+	if err := v.Err(); err != nil {
+		return http.StatusBadRequest, err
+	}
 
 	// panggil grpc getUserByUsername
 	in := &userProto.GetUserByUsernameReq{
 		Username: user.Username,
 	}
 
+	// brute-force --> username dan ip yang terlalu sering gagal harus menunggu dulu. Percobaan ini
+	// langsung dihitung gagal sebelum password dicek, jadi request paralel tidak bisa melewati limit
+	now := time.Now()
+	attemptKeys := []string{loginUsernameKey(user.Username), loginIPKey(clientIP(r))}
+
+	if code, err := s.reserveLoginAttempts(w, r, attemptKeys, now); err != nil {
+		return code, err
+	}
+
 	userDb, err := s.UserServiceGrpcClient.GetUserPasswordByUsername(r.Context(), in)
 	if err != nil {
-		log.Println("Error when calling GetUserByUsername:", err)
-		return http.StatusBadRequest, fmt.Errorf("username/password wrong")
+		if appErr := library.FromGrpcError(err); appErr.Code != library.ERR_NOT_FOUND {
+			s.releaseLoginAttempts(r, attemptKeys)
+			log.Println("Error when calling GetUserByUsername:", err)
+			return appErr.HttpStatus, appErr
+		}
+
+		// username tidak ada tetap di compare supaya waktu response nya sama dengan password salah
+		compareDummyPassword(user.Password)
+		return http.StatusBadRequest, fmt.Errorf("invalid username/password")
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(userDb.HashPassword), []byte(user.Password)); err != nil {
 		return http.StatusBadRequest, fmt.Errorf("invalid username/password")
 	}
 
-	// generate jwt token, refresh token
-	// expAccess := time.Now().Add(6 * time.Second)
-	expAccess := time.Now().Add(6 * time.Hour)
-	expRefresh := time.Now().Add(24 * time.Hour)
-	go func() {
-		jwtToken, err := library.CreateJWT(userDb.Id, s.JWTSecret, expAccess)
-		ch <- tokenChan{token: jwtToken, err: err, tokenType: "access"}
-	}()
-
-	go func() {
-		refreshToken, err := library.CreateJWT(userDb.Id, s.RefreshSecret, expRefresh)
-		ch <- tokenChan{token: refreshToken, err: err, tokenType: "refresh"}
-	}()
-
-	var jwtToken string
-	var refreshToken string
-	var errs []error
-
-	for i := 0; i < 2; i++ {
-		res := <-ch
-		if res.err != nil {
-			errs = append(errs, res.err)
-			continue
-		}
-		if res.tokenType == "access" {
-			jwtToken = res.token
-		} else {
-			refreshToken = res.token
-		}
+	s.loginAttemptSucceeded(r, attemptKeys)
 
+	// dicek setelah password supaya status suspend tidak bocor ke yang menebak password
+	if userDb.SuspendedAt != 0 {
+		return http.StatusForbidden, library.ErrAccountSuspended
 	}
 
-	if len(errs) > 0 {
-		log.Println("Error when generating jwt access and refresh token:")
-		for _, err := range errs {
-			log.Println(err)
+	// 2FA aktif --> access/refresh token baru dibuat di /2fa/login setelah kode TOTP nya benar
+	if userDb.TwoFactorEnabled {
+		challengeToken, err := createTwoFactorChallengeJWT(userDb.Id, s.RefreshSecret)
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("something went wrong")
 		}
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+
+		resp := library.NewResp("two factor authentication required", map[string]interface{}{"twoFactorRequired": true, "challengeToken": challengeToken})
+
+		library.WriteJson(w, http.StatusOK, resp)
+
+		return http.StatusOK, nil
 	}
 
-	access := &http.Cookie{
-		Name:     "accessToken",
-		Value:    jwtToken,
-		Path:     "/",
-		Expires:  expAccess,
-		HttpOnly: true,
+	// generate jwt token, refresh token di family baru
+	principal := &library.Principal{
+		UserId:   userDb.Id,
+		Username: user.Username,
+		Roles:    userRoles(userDb.Role),
+		TokenId:  uuid.NewString(),
 	}
-	refresh := &http.Cookie{
-		Name:     "refreshToken",
-		Value:    refreshToken,
-		Path:     "/",
-		Expires:  expRefresh,
-		HttpOnly: true,
+
+	return s.writeNewSession(w, r, principal)
+}
+
+// writeNewSession --> buat access/refresh token di family baru untuk user yang baru login
+func (s *AuthService) writeNewSession(w http.ResponseWriter, r *http.Request, principal *library.Principal) (int, error) {
+	pair, err := s.issueTokens(r.Context(), principal, uuid.NewString())
+	if err != nil {
+		log.Println("Error when issuing tokens:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
 	}
 
-	resp := library.NewResp("User authenticated", map[string]interface{}{"accessToken": jwtToken, "refreshToken": refreshToken})
+	resp := library.NewResp("User authenticated", map[string]interface{}{"accessToken": pair.accessToken, "refreshToken": pair.refreshToken})
 
-	http.SetCookie(w, access)
-	http.SetCookie(w, refresh)
+	setTokenCookies(w, pair)
 
 	library.WriteJson(w, http.StatusOK, resp)
 
 	return http.StatusOK, nil
 }
 
-func (s *AuthService) handleRefreshAuth(w http.ResponseWriter, r *http.Request) (int, error) {
-	ch := make(chan tokenChan)
+// reserveLoginAttempts --> reserve semua key sebelum password/kode dicek,
+// 429 dengan Retry-After kalau salah satu key masih diblok
+func (s *AuthService) reserveLoginAttempts(w http.ResponseWriter, r *http.Request, keys []string, now time.Time) (int, error) {
+	for i, key := range keys {
+		attempt, err := s.Attempts.ReserveLoginAttempt(r.Context(), key, now)
+		if err == nil {
+			continue
+		}
 
-	///////////////////////////
-	//TODO handleRefreshAuth//
-	/////////////////////////
+		// percobaan ini tidak jadi dicek, key sebelumnya yang sudah di reserve dibatalkan
+		s.releaseLoginAttempts(r, keys[:i])
 
+		if errors.Is(err, ErrLoginAttemptBlocked) {
+			retryAfter := int64(math.Ceil(attempt.BlockedUntil().Sub(now).Seconds()))
+			w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+			return http.StatusTooManyRequests, fmt.Errorf("too many login attempts, try again later")
+		}
+
+		log.Println("Error when reserving login attempt:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	return http.StatusOK, nil
+}
+
+// releaseLoginAttempts --> batalkan reserve, untuk percobaan yang gagal karena error server
+func (s *AuthService) releaseLoginAttempts(r *http.Request, keys []string) {
+	for _, key := range keys {
+		if err := s.Attempts.ReleaseLoginAttempt(r.Context(), key); err != nil {
+			log.Println("Error when releasing login attempt:", err)
+		}
+	}
+}
+
+// loginAttemptSucceeded --> counter key pertama (username/2fa) di reset. Key lain (ip) hanya reserve
+// percobaan ini yang dibatalkan, supaya login ke akun sendiri tidak bisa dipakai untuk reset counter ip
+func (s *AuthService) loginAttemptSucceeded(r *http.Request, keys []string) {
+	if err := s.Attempts.ResetLoginAttempts(r.Context(), keys[0]); err != nil {
+		log.Println("Error when resetting login attempts:", err)
+	}
+
+	s.releaseLoginAttempts(r, keys[1:])
+}
+
+func (s *AuthService) handleRefreshAuth(w http.ResponseWriter, r *http.Request) (int, error) {
 	log.Println("hit handle refresh auth")
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Println("Error when reading body:", err)
-		return http.StatusBadRequest, fmt.Errorf("invalid user detail")
+		return http.StatusBadRequest, fmt.Errorf("invalid refresh token")
 	}
 
 	defer r.Body.Close()
 
-	re := &Refresh{}
-	if err := json.Unmarshal(body, re); err != nil {
-		log.Println("Error when unmarshaling body:", err)
-		return http.StatusBadRequest, fmt.Errorf("invalid refresh token")
+	claims, err := parseRefreshJWT(getRefreshToken(body, r), s.RefreshSecret)
+	if err != nil {
+		return http.StatusUnauthorized, fmt.Errorf("invalid refresh token")
 	}
 
-	token, err := library.ValidateJWT(re.RefreshToken, s.RefreshSecret)
+	// refresh token yang dibuat sebelum user ganti password/dihapus/logout-all ditolak
+	validAfter, err := userServiceTokensValidAfter(s.UserServiceGrpcClient)(r.Context(), claims.Subject)
 	if err != nil {
+		if errors.Is(err, library.ErrUnknownTokenSubject) {
+			return http.StatusUnauthorized, fmt.Errorf("invalid refresh token")
+		}
+		log.Println("Error when getting tokensValidAfter:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	if claims.IssuedAt == nil || claims.IssuedAt.Before(validAfter) {
 		return http.StatusUnauthorized, fmt.Errorf("invalid refresh token")
 	}
 
-	userId := token.Claims.(jwt.MapClaims)["sub"].(string)
+	// username di access token diambil ulang, bisa saja sudah berubah sejak login
+	userDb, err := s.UserServiceGrpcClient.GetUserById(r.Context(), &userProto.GetUserByIdReq{Id: claims.Subject})
+	if err != nil {
+		appErr := library.FromGrpcError(err)
+		if appErr.Code == library.ERR_NOT_FOUND {
+			return http.StatusUnauthorized, library.Unauthorized("invalid refresh token")
+		}
+		log.Println("Error when calling GetUserById:", err)
+		return appErr.HttpStatus, appErr
+	}
+
+	if userDb.SuspendedAt != 0 {
+		clearTokenCookies(w)
+		return http.StatusForbidden, library.ErrAccountSuspended
+	}
+
+	// refresh token single-use, token yang dipakai ulang berarti bocor --> revoke seluruh family
+	// supaya pemilik asli dan pencuri sama-sama harus login ulang
+	now := time.Now()
+	if _, err := s.Store.UseRefreshToken(r.Context(), claims.ID, now); err != nil {
+		switch {
+		case errors.Is(err, ErrRefreshTokenReused):
+			log.Println("Refresh token reuse detected, revoking family:", claims.FamilyId)
+			if err := s.Store.RevokeRefreshTokenFamily(r.Context(), claims.FamilyId, now); err != nil {
+				log.Println("Error when revoking refresh token family:", err)
+				return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+			}
+			clearTokenCookies(w)
+			return http.StatusUnauthorized, fmt.Errorf("invalid refresh token")
+		case errors.Is(err, ErrRefreshTokenNotFound), errors.Is(err, ErrRefreshTokenRevoked), errors.Is(err, ErrRefreshTokenExpired):
+			return http.StatusUnauthorized, fmt.Errorf("invalid refresh token")
+		default:
+			log.Println("Error when using refresh token:", err)
+			return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		}
+	}
 
-	// generate jwt token, refresh token
-	go func() {
-		// jwtToken, err := library.CreateJWT(userId, s.JWTSecret, time.Now().Add(6*time.Second))
-		jwtToken, err := library.CreateJWT(userId, s.JWTSecret, time.Now().Add(6*time.Hour))
-		ch <- tokenChan{token: jwtToken, err: err, tokenType: "access"}
-	}()
+	principal := &library.Principal{
+		UserId:   userDb.Id,
+		Username: userDb.Username,
+		Roles:    userRoles(userDb.Role),
+		TokenId:  uuid.NewString(),
+	}
 
-	go func() {
-		refreshToken, err := library.CreateJWT(userId, s.RefreshSecret, time.Now().Add(24*time.Hour))
-		ch <- tokenChan{token: refreshToken, err: err, tokenType: "refresh"}
-	}()
+	pair, err := s.issueTokens(r.Context(), principal, claims.FamilyId)
+	if err != nil {
+		log.Println("Error when issuing tokens:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
 
-	var jwtToken string
-	var refreshToken string
-	var errs []error
+	resp := library.NewResp("token refreshed", map[string]interface{}{"accessToken": pair.accessToken, "refreshToken": pair.refreshToken})
 
-	for i := 0; i < 2; i++ {
-		res := <-ch
-		if res.err != nil {
-			errs = append(errs, res.err)
-			continue
-		}
-		if res.tokenType == "access" {
-			jwtToken = res.token
-		} else {
-			refreshToken = res.token
+	setTokenCookies(w, pair)
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+// handleLogoutAuth --> revoke family refresh token yang dipakai dan hapus cookie.
+// Token yang tidak valid/expired tetap dianggap logout berhasil
+func (s *AuthService) handleLogoutAuth(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle logout auth")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Error when reading body:", err)
+		return http.StatusBadRequest, fmt.Errorf("invalid refresh token")
+	}
+
+	defer r.Body.Close()
+
+	if claims, err := parseRefreshJWT(getRefreshToken(body, r), s.RefreshSecret); err == nil {
+		if err := s.Store.RevokeRefreshTokenFamily(r.Context(), claims.FamilyId, time.Now()); err != nil {
+			log.Println("Error when revoking refresh token family:", err)
+			return http.StatusInternalServerError, fmt.Errorf("something went wrong")
 		}
+	}
+
+	clearTokenCookies(w)
 
+	resp := library.NewResp("user logged out", nil)
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+// handleLogoutAllAuth --> semua access token dan refresh token user di semua device jadi tidak valid
+func (s *AuthService) handleLogoutAllAuth(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle logout all auth")
+
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userId := principal.UserId
+
+	in := &userProto.GetUserByIdReq{
+		Id: userId,
 	}
 
-	if len(errs) > 0 {
-		log.Println("Error when generating jwt access and refresh token:")
-		for _, err := range errs {
-			log.Println(err)
+	if _, err := s.UserServiceGrpcClient.RevokeUserTokens(r.Context(), in); err != nil {
+		appErr := library.FromGrpcError(err)
+		if appErr.Code == library.ERR_NOT_FOUND {
+			return http.StatusUnauthorized, library.Unauthorized("invalid token")
 		}
+		log.Println("Error when calling RevokeUserTokens:", err)
+		return appErr.HttpStatus, appErr
+	}
+
+	if err := s.Store.RevokeUserRefreshTokens(r.Context(), userId, time.Now()); err != nil {
+		log.Println("Error when revoking user refresh tokens:", err)
 		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
 	}
 
-	resp := library.NewResp("token refreshed", map[string]interface{}{"accessToken": jwtToken, "refreshToken": refreshToken})
+	clearTokenCookies(w)
+
+	resp := library.NewResp("user logged out from all devices", nil)
 
 	library.WriteJson(w, http.StatusOK, resp)
 
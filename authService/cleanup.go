@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// CLEANUP_INTERVAL --> jarak antar pembersihan data auth yang sudah tidak dipakai
+const CLEANUP_INTERVAL = 10 * time.Minute
+
+// StoreCleaner --> hapus refresh token expired dan login attempt yang sudah lewat window secara berkala,
+// tanpa ini tabel refresh_tokens/login_attempts terus membesar
+type StoreCleaner struct {
+	Tokens   RefreshTokenStore
+	Attempts LoginAttemptStore
+	Interval time.Duration
+}
+
+func NewStoreCleaner(tokens RefreshTokenStore, attempts LoginAttemptStore, interval time.Duration) *StoreCleaner {
+	return &StoreCleaner{
+		Tokens:   tokens,
+		Attempts: attempts,
+		Interval: interval,
+	}
+}
+
+func (c *StoreCleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("store cleaner stopped")
+			return
+		case <-ticker.C:
+			c.Clean(ctx, time.Now())
+		}
+	}
+}
+
+func (c *StoreCleaner) Clean(ctx context.Context, now time.Time) {
+	deleted, err := c.Tokens.DeleteExpiredRefreshTokens(ctx, now)
+	if err != nil {
+		log.Println("Error when deleting expired refresh tokens:", err)
+	} else if deleted > 0 {
+		log.Println("store cleaner deleted refresh tokens:", deleted)
+	}
+
+	deleted, err = c.Attempts.DeleteStaleLoginAttempts(ctx, now.Add(-LOGIN_ATTEMPT_WINDOW))
+	if err != nil {
+		log.Println("Error when deleting stale login attempts:", err)
+	} else if deleted > 0 {
+		log.Println("store cleaner deleted login attempts:", deleted)
+	}
+}
@@ -3,12 +3,36 @@ package main
 import (
 	"log"
 	"os"
+
+	"github.com/pewe21/library"
 )
 
 type AppConfig struct {
 	JwtSecret           string
 	RefreshSecret       string
 	UserServiceHostname string
+
+	// REFRESH_TOKEN_STORE: postgres (default) atau memory
+	RefreshTokenStore string
+
+	// LOGIN_ATTEMPT_STORE: postgres (default) atau memory, counter login gagal untuk brute-force protection
+	LoginAttemptStore string
+
+	// JWT_PRIVATE_KEY_FILES: private key untuk sign access token, contoh "kid1=/keys/kid1.pem,kid2=/keys/kid2.pem"
+	PrivateKeyFiles []PrivateKeyFile
+	// JWT_ACTIVE_KID: kid yang dipakai sign access token, default key pertama
+	ActiveKid string
+	// JWT_EPHEMERAL_KEY: true untuk development, kalau JWT_PRIVATE_KEY_FILES kosong buat key sementara.
+	// Hanya bisa dipakai dengan satu instance authService
+	EphemeralKey bool
+
+	// TOTP_ENCRYPTION_KEY: base64 key AES-256 untuk enkripsi secret TOTP, kosong berarti 2FA tidak bisa di setup
+	TOTPEncryptionKey []byte
+}
+
+type PrivateKeyFile struct {
+	Kid  string
+	Path string
 }
 
 func InitConfig() AppConfig {
@@ -16,7 +40,8 @@ func InitConfig() AppConfig {
 	jwtSecret := os.Getenv("JWT_SECRET")
 	refreshSecret := os.Getenv("REFRESH_SECRET")
 	userServiceHostName := os.Getenv("USER_SERVICE_HOSTNAME")
-	if jwtSecret == "" {
+	// JWT_SECRET hanya dipakai di legacy mode HS256
+	if jwtSecret == "" && library.JWTLegacyHS256Enabled() {
 		log.Fatal("JWT_SECRET key not found!")
 	}
 
@@ -29,9 +54,41 @@ func InitConfig() AppConfig {
 		userServiceHostName = "localhost"
 	}
 
+	refreshTokenStore := os.Getenv("REFRESH_TOKEN_STORE")
+	if refreshTokenStore == "" {
+		log.Println("REFRESH_TOKEN_STORE environment variable is missing, fallback to postgres")
+		refreshTokenStore = "postgres"
+	}
+
+	loginAttemptStore := os.Getenv("LOGIN_ATTEMPT_STORE")
+	if loginAttemptStore == "" {
+		log.Println("LOGIN_ATTEMPT_STORE environment variable is missing, fallback to postgres")
+		loginAttemptStore = "postgres"
+	}
+
+	privateKeyFiles, err := parsePrivateKeyFiles(os.Getenv("JWT_PRIVATE_KEY_FILES"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	totpEncryptionKey, err := parseTOTPEncryptionKey(os.Getenv("TOTP_ENCRYPTION_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if totpEncryptionKey == nil {
+		log.Println("TOTP_ENCRYPTION_KEY key is missing, two factor authentication is disabled")
+	}
+
 	return AppConfig{
 		JwtSecret:           jwtSecret,
 		RefreshSecret:       refreshSecret,
 		UserServiceHostname: userServiceHostName,
+		RefreshTokenStore:   refreshTokenStore,
+		LoginAttemptStore:   loginAttemptStore,
+		PrivateKeyFiles:     privateKeyFiles,
+		ActiveKid:           os.Getenv("JWT_ACTIVE_KID"),
+		EphemeralKey:        os.Getenv("JWT_EPHEMERAL_KEY") == "true",
+		TOTPEncryptionKey:   totpEncryptionKey,
 	}
 }
@@ -6,14 +6,17 @@ require (
 	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
+	github.com/lib/pq v1.10.9
 	github.com/pewe21/library v1.0.0
 	github.com/pewe21/userProto v0.0.0-00010101000000-000000000000
+	github.com/pquerna/otp v1.5.0
 	golang.org/x/crypto v0.21.0
 	golang.org/x/sync v0.6.0
 	google.golang.org/grpc v1.64.0
 )
 
 require (
+	github.com/boombuler/barcode v1.0.1-0.20190219062509-6c824513bacc // indirect
 	github.com/rabbitmq/amqp091-go v1.10.0 // indirect
 	golang.org/x/net v0.22.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
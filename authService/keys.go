@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pewe21/library"
+)
+
+// KeySet --> semua private key authService. Access token di sign dengan key active,
+// key lain tetap dipublish di JWKS supaya token lama masih valid selama rotasi
+type KeySet struct {
+	active *library.SigningKey
+	keys   map[string]*library.SigningKey
+}
+
+// LoadKeySet --> baca key dari JWT_PRIVATE_KEY_FILES. Kalau kosong, hanya legacy mode atau
+// JWT_EPHEMERAL_KEY=true yang boleh jalan, selain itu error
+//
+// Key ephemeral hilang saat restart dan tidak dibagi antar instance, kid nya random supaya token dari
+// instance lain ditolak sebagai kid tidak dikenal. Hanya untuk development dengan satu instance authService:
+// JWKS diambil lewat load balancer, jadi dengan lebih dari satu instance token bisa ditolak secara acak
+func LoadKeySet(cfg AppConfig) (*KeySet, error) {
+	set := &KeySet{
+		keys: map[string]*library.SigningKey{},
+	}
+
+	for _, file := range cfg.PrivateKeyFiles {
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := library.ParseSigningKeyPEM(file.Kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key %s: %w", file.Kid, err)
+		}
+
+		set.keys[file.Kid] = key
+	}
+
+	if len(set.keys) == 0 {
+		if library.JWTLegacyHS256Enabled() {
+			log.Println("JWT_PRIVATE_KEY_FILES is empty, signing access token with legacy HS256")
+			return set, nil
+		}
+
+		if !cfg.EphemeralKey {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILES is empty, set JWT_EPHEMERAL_KEY=true to use an ephemeral key for development")
+		}
+
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := "ephemeral-" + uuid.NewString()
+		log.Println("JWT_PRIVATE_KEY_FILES is empty, generating ephemeral Ed25519 key", kid, "(single instance only). Dont use this on productions")
+
+		set.active = &library.SigningKey{Kid: kid, Key: private}
+		set.keys[set.active.Kid] = set.active
+
+		return set, nil
+	}
+
+	activeKid := cfg.ActiveKid
+	if activeKid == "" {
+		activeKid = cfg.PrivateKeyFiles[0].Kid
+	}
+
+	active, ok := set.keys[activeKid]
+	if !ok {
+		return nil, fmt.Errorf("JWT_ACTIVE_KID %s not found in JWT_PRIVATE_KEY_FILES", activeKid)
+	}
+
+	set.active = active
+
+	return set, nil
+}
+
+// Active --> key untuk sign access token, nil berarti legacy HS256
+func (s *KeySet) Active() *library.SigningKey {
+	return s.active
+}
+
+// PublicKey --> implementasi library.KeySource, authService verifikasi token dengan key nya sendiri
+func (s *KeySet) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, library.ErrUnknownKid
+	}
+
+	return key.Key.Public(), nil
+}
+
+func (s *KeySet) JWKS() (*library.JWKS, error) {
+	jwks := &library.JWKS{Keys: []library.JWK{}}
+
+	for kid, key := range s.keys {
+		jwk, err := library.NewJWK(kid, key.Key.Public())
+		if err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks, nil
+}
+
+// createAccessToken --> sign dengan key active, atau HS256 JWT_SECRET di legacy mode
+func (s *AuthService) createAccessToken(principal *library.Principal, expiry time.Time) (string, error) {
+	if key := s.Keys.Active(); key != nil {
+		return library.CreateSignedJWT(principal, key, expiry)
+	}
+
+	return library.CreateJWT(principal, s.JWTSecret, expiry)
+}
+
+func (s *AuthService) handleGetJWKS(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle get jwks")
+
+	jwks, err := s.Keys.JWKS()
+	if err != nil {
+		log.Println("Error when building JWKS:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	// service lain cache JWKS sendiri, key baru hasil rotasi diambil ulang saat kid nya belum dikenal
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	library.WriteJson(w, http.StatusOK, jwks)
+
+	return http.StatusOK, nil
+}
+
+// parsePrivateKeyFiles --> parse "kid1=/keys/kid1.pem,kid2=/keys/kid2.pem"
+func parsePrivateKeyFiles(value string) ([]PrivateKeyFile, error) {
+	files := []PrivateKeyFile{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("invalid JWT_PRIVATE_KEY_FILES entry: %s", entry)
+		}
+
+		files = append(files, PrivateKeyFile{Kid: kid, Path: path})
+	}
+
+	return files, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadKeySetWithoutKeys(t *testing.T) {
+	t.Setenv("JWT_LEGACY_HS256", "")
+
+	if _, err := LoadKeySet(AppConfig{}); err == nil {
+		t.Fatalf("LoadKeySet without keys error = nil, want error")
+	}
+
+	first, err := LoadKeySet(AppConfig{EphemeralKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := LoadKeySet(AppConfig{EphemeralKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(first.Active().Kid, "ephemeral-") {
+		t.Errorf("ephemeral kid = %q, want ephemeral- prefix", first.Active().Kid)
+	}
+
+	// tiap instance punya kid sendiri
+	if first.Active().Kid == second.Active().Kid {
+		t.Errorf("two ephemeral key sets share kid %q", first.Active().Kid)
+	}
+
+	t.Setenv("JWT_LEGACY_HS256", "true")
+
+	legacy, err := LoadKeySet(AppConfig{})
+	if err != nil || legacy.Active() != nil {
+		t.Errorf("LoadKeySet in legacy mode = %v, %v, want no active key", legacy, err)
+	}
+}
+
+func TestLoadKeySetFromFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	files := []PrivateKeyFile{}
+	for _, kid := range []string{"old", "new"} {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(dir, kid+".pem")
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		files = append(files, PrivateKeyFile{Kid: kid, Path: path})
+	}
+
+	set, err := LoadKeySet(AppConfig{PrivateKeyFiles: files, ActiveKid: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if set.Active().Kid != "new" {
+		t.Errorf("active kid = %q, want new", set.Active().Kid)
+	}
+
+	jwks, err := set.JWKS()
+	if err != nil || len(jwks.Keys) != 2 {
+		t.Errorf("JWKS = %+v, %v, want 2 keys", jwks, err)
+	}
+
+	if _, err := LoadKeySet(AppConfig{PrivateKeyFiles: files, ActiveKid: "missing"}); err == nil {
+		t.Errorf("LoadKeySet with unknown active kid error = nil, want error")
+	}
+
+	missing := []PrivateKeyFile{{Kid: "missing", Path: filepath.Join(dir, "missing.pem")}}
+	if _, err := LoadKeySet(AppConfig{PrivateKeyFiles: missing}); err == nil {
+		t.Errorf("LoadKeySet with missing file error = nil, want error")
+	}
+}
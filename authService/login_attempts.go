@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BCRYPT_COST --> cost hash password, dummy hash untuk username yang tidak ada juga pakai cost ini
+// supaya waktu response nya sama
+const BCRYPT_COST = 12
+
+// setelah gagal LOGIN_BACKOFF_AFTER kali login berikutnya harus menunggu LOGIN_BACKOFF_BASE,
+// dikali dua setiap gagal lagi sampai LOGIN_BACKOFF_MAX
+const LOGIN_BACKOFF_AFTER = 3
+const LOGIN_BACKOFF_BASE = 1 * time.Second
+const LOGIN_BACKOFF_MAX = 5 * time.Minute
+
+// gagal LOGIN_LOCKOUT_AFTER kali --> dikunci selama LOGIN_LOCKOUT_DURATION
+const LOGIN_LOCKOUT_AFTER = 10
+const LOGIN_LOCKOUT_DURATION = 15 * time.Minute
+
+// LOGIN_ATTEMPT_WINDOW --> counter gagal mulai dari nol lagi kalau gagal terakhir sudah lebih lama dari ini
+const LOGIN_ATTEMPT_WINDOW = 1 * time.Hour
+
+// LoginAttempt --> jumlah login gagal berturut-turut untuk satu key (username atau ip)
+type LoginAttempt struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+}
+
+// BlockedUntil --> waktu sampai key boleh mencoba login lagi, zero kalau tidak diblok
+func (a *LoginAttempt) BlockedUntil() time.Time {
+	if a.Failures >= LOGIN_LOCKOUT_AFTER {
+		return a.LastFailureAt.Add(LOGIN_LOCKOUT_DURATION)
+	}
+
+	if a.Failures < LOGIN_BACKOFF_AFTER {
+		return time.Time{}
+	}
+
+	// shift dibatasi supaya tidak overflow, 1s << 20 sudah jauh diatas LOGIN_BACKOFF_MAX
+	backoff := LOGIN_BACKOFF_MAX
+	if shift := a.Failures - LOGIN_BACKOFF_AFTER; shift < 20 {
+		backoff = min(LOGIN_BACKOFF_BASE<<shift, LOGIN_BACKOFF_MAX)
+	}
+
+	return a.LastFailureAt.Add(backoff)
+}
+
+// ErrLoginAttemptBlocked --> key masih diblok, attempt tidak di reserve
+var ErrLoginAttemptBlocked = errors.New("login attempt blocked")
+
+// reserveLoginAttempt --> cek blok lalu hitung attempt ini sebagai gagal. Dipanggil store didalam lock
+// supaya request paralel tidak bisa melewati cek yang sama sebelum counter nya bertambah
+func reserveLoginAttempt(attempt *LoginAttempt, now time.Time) error {
+	if now.Sub(attempt.LastFailureAt) > LOGIN_ATTEMPT_WINDOW {
+		attempt.Failures = 0
+	}
+
+	if now.Before(attempt.BlockedUntil()) {
+		return ErrLoginAttemptBlocked
+	}
+
+	attempt.Failures++
+	attempt.LastFailureAt = now
+
+	return nil
+}
+
+// LoginAttemptStore --> tempat counter login gagal disimpan, postgres supaya dibagi antar instance authService.
+// Setiap percobaan di reserve (dihitung gagal) sebelum password/kode dicek, kalau berhasil counter nya
+// di reset atau di release
+type LoginAttemptStore interface {
+	// ReserveLoginAttempt --> secara atomic cek blok lalu tambah counter. Return ErrLoginAttemptBlocked
+	// beserta attempt nya (untuk Retry-After) kalau key masih diblok
+	ReserveLoginAttempt(ctx context.Context, key string, now time.Time) (*LoginAttempt, error)
+
+	// ReleaseLoginAttempt --> batalkan satu reserve, untuk percobaan yang berhasil atau gagal karena error server
+	ReleaseLoginAttempt(ctx context.Context, key string) error
+
+	ResetLoginAttempts(ctx context.Context, key string) error
+
+	// DeleteStaleLoginAttempts --> hapus attempt yang gagal terakhirnya sebelum before, dipanggil StoreCleaner.
+	// Attempt lewat LOGIN_ATTEMPT_WINDOW counter nya sudah dianggap nol, jadi aman dihapus
+	DeleteStaleLoginAttempts(ctx context.Context, before time.Time) (int64, error)
+}
+
+// MemoryLoginAttemptStore --> LoginAttemptStore di memory, counter tidak dibagi antar instance
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]LoginAttempt
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		attempts: map[string]LoginAttempt{},
+	}
+}
+
+func (s *MemoryLoginAttemptStore) ReserveLoginAttempt(ctx context.Context, key string, now time.Time) (*LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		attempt = LoginAttempt{Key: key}
+	}
+
+	if err := reserveLoginAttempt(&attempt, now); err != nil {
+		return &attempt, err
+	}
+
+	s.attempts[key] = attempt
+
+	return &attempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) ReleaseLoginAttempt(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil
+	}
+
+	attempt.Failures--
+	if attempt.Failures <= 0 {
+		delete(s.attempts, key)
+		return nil
+	}
+
+	s.attempts[key] = attempt
+
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) ResetLoginAttempts(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) DeleteStaleLoginAttempts(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for key, attempt := range s.attempts {
+		if attempt.LastFailureAt.Before(before) {
+			delete(s.attempts, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func loginUsernameKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// loginTwoFactorKey --> counter kode 2FA salah, terpisah dari counter password
+func loginTwoFactorKey(userId string) string {
+	return "2fa:" + userId
+}
+
+func loginIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// clientIP --> ip client dari header X-Real-IP yang diisi nginx, fallback ke RemoteAddr
+func clientIP(r *http.Request) string {
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// dummyPasswordHash --> dibuat sekali saat start supaya compare pertama tidak lebih lama
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), BCRYPT_COST)
+
+// compareDummyPassword --> bcrypt compare untuk username yang tidak ada, supaya waktu response nya
+// sama dengan password salah dan username tidak bisa ditebak dari lama response
+func compareDummyPassword(password string) {
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
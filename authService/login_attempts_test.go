@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginAttemptBlockedUntil(t *testing.T) {
+	last := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{9, 64 * time.Second},
+		{10, LOGIN_LOCKOUT_DURATION},
+		{1000, LOGIN_LOCKOUT_DURATION},
+	}
+
+	for _, tt := range tests {
+		attempt := &LoginAttempt{Failures: tt.failures, LastFailureAt: last}
+
+		want := time.Time{}
+		if tt.want > 0 {
+			want = last.Add(tt.want)
+		}
+
+		if got := attempt.BlockedUntil(); !got.Equal(want) {
+			t.Errorf("BlockedUntil with %d failures = %v, want %v", tt.failures, got, want)
+		}
+	}
+}
+
+func TestMemoryLoginAttemptStore(t *testing.T) {
+	store := NewMemoryLoginAttemptStore()
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+
+	for i := 1; i <= LOGIN_BACKOFF_AFTER; i++ {
+		attempt, err := store.ReserveLoginAttempt(ctx, "user:bob", now)
+		if err != nil || attempt.Failures != i {
+			t.Fatalf("reserve %d = %+v, %v, want %d failures", i, attempt, err, i)
+		}
+	}
+
+	// gagal ketiga --> harus menunggu LOGIN_BACKOFF_BASE
+	attempt, err := store.ReserveLoginAttempt(ctx, "user:bob", now)
+	if !errors.Is(err, ErrLoginAttemptBlocked) {
+		t.Fatalf("reserve while blocked = %v, want ErrLoginAttemptBlocked", err)
+	}
+	if want := now.Add(LOGIN_BACKOFF_BASE); !attempt.BlockedUntil().Equal(want) {
+		t.Errorf("BlockedUntil = %v, want %v", attempt.BlockedUntil(), want)
+	}
+
+	// reserve yang ditolak tidak menambah counter
+	if got := store.attempts["user:bob"].Failures; got != LOGIN_BACKOFF_AFTER {
+		t.Errorf("failures after blocked reserve = %d, want %d", got, LOGIN_BACKOFF_AFTER)
+	}
+
+	// release membatalkan satu reserve, key lain tidak ikut
+	if _, err := store.ReserveLoginAttempt(ctx, "ip:10.0.0.1", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ReleaseLoginAttempt(ctx, "user:bob"); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.attempts["user:bob"].Failures; got != LOGIN_BACKOFF_AFTER-1 {
+		t.Errorf("failures after release = %d, want %d", got, LOGIN_BACKOFF_AFTER-1)
+	}
+
+	if err := store.ReleaseLoginAttempt(ctx, "ip:10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.attempts["ip:10.0.0.1"]; ok {
+		t.Errorf("attempt with zero failures was not deleted")
+	}
+
+	// release key yang tidak ada tidak error
+	if err := store.ReleaseLoginAttempt(ctx, "ip:missing"); err != nil {
+		t.Errorf("release missing key = %v, want nil", err)
+	}
+
+	if err := store.ResetLoginAttempts(ctx, "user:bob"); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, err := store.ReserveLoginAttempt(ctx, "user:bob", now); err != nil || attempt.Failures != 1 {
+		t.Errorf("reserve after reset = %+v, %v, want 1 failure", attempt, err)
+	}
+}
+
+func TestMemoryLoginAttemptStoreWindow(t *testing.T) {
+	store := NewMemoryLoginAttemptStore()
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+
+	for i := 0; i < LOGIN_LOCKOUT_AFTER; i++ {
+		at := now.Add(time.Duration(i) * LOGIN_BACKOFF_MAX)
+		if _, err := store.ReserveLoginAttempt(ctx, "user:bob", at); err != nil {
+			t.Fatalf("reserve %d = %v", i, err)
+		}
+	}
+
+	last := now.Add((LOGIN_LOCKOUT_AFTER - 1) * LOGIN_BACKOFF_MAX)
+
+	if _, err := store.ReserveLoginAttempt(ctx, "user:bob", last.Add(LOGIN_LOCKOUT_DURATION-time.Second)); !errors.Is(err, ErrLoginAttemptBlocked) {
+		t.Errorf("reserve during lockout = %v, want ErrLoginAttemptBlocked", err)
+	}
+
+	// setelah lockout masih didalam window --> counter tetap, gagal lagi langsung lockout lagi
+	attempt, err := store.ReserveLoginAttempt(ctx, "user:bob", last.Add(LOGIN_LOCKOUT_DURATION))
+	if err != nil || attempt.Failures != LOGIN_LOCKOUT_AFTER+1 {
+		t.Errorf("reserve after lockout = %+v, %v, want %d failures", attempt, err, LOGIN_LOCKOUT_AFTER+1)
+	}
+
+	// lewat window --> counter mulai dari nol
+	later := last.Add(LOGIN_LOCKOUT_DURATION + LOGIN_ATTEMPT_WINDOW + time.Second)
+	attempt, err = store.ReserveLoginAttempt(ctx, "user:bob", later)
+	if err != nil || attempt.Failures != 1 {
+		t.Errorf("reserve after window = %+v, %v, want 1 failure", attempt, err)
+	}
+}
+
+func TestMemoryLoginAttemptStoreConcurrent(t *testing.T) {
+	store := NewMemoryLoginAttemptStore()
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+
+	// request paralel di detik yang sama tidak bisa melewati limit
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.ReserveLoginAttempt(ctx, "user:bob", now); err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if reserved != LOGIN_BACKOFF_AFTER {
+		t.Errorf("concurrent reservations = %d, want %d", reserved, LOGIN_BACKOFF_AFTER)
+	}
+}
+
+func TestStoreCleanerDeletesStaleLoginAttempts(t *testing.T) {
+	store := NewMemoryLoginAttemptStore()
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+
+	if _, err := store.ReserveLoginAttempt(ctx, "user:old", now.Add(-LOGIN_ATTEMPT_WINDOW-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ReserveLoginAttempt(ctx, "user:recent", now.Add(-LOGIN_ATTEMPT_WINDOW+time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	NewStoreCleaner(NewMemoryRefreshTokenStore(), store, CLEANUP_INTERVAL).Clean(ctx, now)
+
+	if _, ok := store.attempts["user:old"]; ok {
+		t.Errorf("attempt older than LOGIN_ATTEMPT_WINDOW was not deleted")
+	}
+	if _, ok := store.attempts["user:recent"]; !ok {
+		t.Errorf("attempt inside LOGIN_ATTEMPT_WINDOW was deleted")
+	}
+}
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"log"
+	"time"
+)
+
 const PORT = ":3003"
 const GRPC_USER_SERVICE_PORT = ":4002"
 const GRPC_NUM_INSTANCE = 2
@@ -10,8 +15,51 @@ func main() {
 
 	cfg := InitConfig()
 
+	var postgresStorage *PostgresStorage
+
+	if cfg.RefreshTokenStore == "postgres" || cfg.LoginAttemptStore == "postgres" {
+		postgresStorage = NewPostgresStorage()
+		postgresStorage.Init()
+
+		// set db conn limit
+		postgresStorage.db.SetMaxOpenConns(25)
+		postgresStorage.db.SetMaxIdleConns(25)
+		postgresStorage.db.SetConnMaxLifetime(5 * time.Minute)
+
+		defer postgresStorage.db.Close()
+	}
+
+	var store RefreshTokenStore
+
+	switch cfg.RefreshTokenStore {
+	case "postgres":
+		store = postgresStorage
+	case "memory":
+		// refresh token hilang saat restart dan tidak dibagi antar instance
+		store = NewMemoryRefreshTokenStore()
+	default:
+		log.Fatalf("unknown refresh token store: %s", cfg.RefreshTokenStore)
+	}
+
+	var attempts LoginAttemptStore
+
+	switch cfg.LoginAttemptStore {
+	case "postgres":
+		attempts = postgresStorage
+	case "memory":
+		// counter per instance, dengan 2 instance dibelakang load balancer limit nya efektif jadi 2x
+		attempts = NewMemoryLoginAttemptStore()
+	default:
+		log.Fatalf("unknown login attempt store: %s", cfg.LoginAttemptStore)
+	}
+
+	keys, err := LoadKeySet(cfg)
+	if err != nil {
+		log.Fatalf("Cannot load jwt signing keys: %v", err)
+	}
+
 	// start http server
-	server := NewServer(PORT, cfg)
+	server := NewServer(PORT, cfg, store, attempts, keys)
 	server.Run()
 
 }
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+type PostgresStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresStorage() *PostgresStorage {
+	userDB := os.Getenv("POSTGRES_USER")
+	passDB := os.Getenv("POSTGRES_PASSWORD")
+	databaseDB := os.Getenv("POSTGRES_DB")
+	hostDB := os.Getenv("POSTGRES_HOST")
+	connStr := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", userDB, passDB, hostDB, databaseDB)
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatal("Cannot establish connection to database: ", err.Error())
+	}
+
+	if err = db.Ping(); err != nil {
+		log.Fatal("Cannot ping to database: ", err.Error())
+	}
+
+	log.Println("Connected to database")
+
+	return &PostgresStorage{
+		db: db,
+	}
+}
+
+func (s *PostgresStorage) Init() {
+	if err := s.createRefreshTokenTable(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.createLoginAttemptTable(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// refresh_tokens --> satu row per refresh token (jti), familyId sama untuk semua token hasil rotasi satu login
+func (s *PostgresStorage) createRefreshTokenTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS refresh_tokens (
+            id TEXT PRIMARY KEY,
+            familyId TEXT NOT NULL,
+            userId TEXT NOT NULL,
+
+            expiresAt INTEGER NOT NULL,
+            createdAt INTEGER NOT NULL,
+            usedAt INTEGER,
+            revokedAt INTEGER
+        )`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (familyId)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS refresh_tokens_user_idx ON refresh_tokens (userId)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS refresh_tokens_expires_idx ON refresh_tokens (expiresAt)`)
+
+	return err
+}
+
+// login_attempts --> counter login gagal per key ("user:<username>" atau "ip:<ip>")
+func (s *PostgresStorage) createLoginAttemptTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS login_attempts (
+            key TEXT PRIMARY KEY,
+            failures INTEGER NOT NULL,
+
+            lastFailureAt INTEGER NOT NULL
+        )`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS login_attempts_last_failure_idx ON login_attempts (lastFailureAt)`)
+
+	return err
+}
+
+func (s *PostgresStorage) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+        INSERT INTO refresh_tokens (id, familyId, userId, expiresAt, createdAt)
+        VALUES ($1, $2, $3, $4, $5)`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, token.Id, token.FamilyId, token.UserId, token.ExpiresAt.Unix(), token.CreatedAt.Unix())
+
+	return err
+}
+
+// UseRefreshToken --> update hanya berhasil untuk token yang belum dipakai/revoke/expired,
+// jadi dua request bersamaan dengan token yang sama tidak bisa sama-sama berhasil
+func (s *PostgresStorage) UseRefreshToken(ctx context.Context, id string, now time.Time) (*RefreshToken, error) {
+	token, err := scanRefreshToken(s.db.QueryRowContext(ctx, `
+        UPDATE refresh_tokens
+        SET
+            usedAt = $1
+        WHERE
+            id = $2
+            AND usedAt IS NULL
+            AND revokedAt IS NULL
+            AND expiresAt > $1
+        RETURNING id, familyId, userId, expiresAt, createdAt, usedAt, revokedAt
+        `, now.Unix(), id))
+	if err == nil {
+		return token, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// cari tahu kenapa token tidak bisa dipakai
+	token, err = scanRefreshToken(s.db.QueryRowContext(ctx, `
+        SELECT id, familyId, userId, expiresAt, createdAt, usedAt, revokedAt
+        FROM refresh_tokens
+        WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	if err := checkRefreshToken(token, now); err != nil {
+		return token, err
+	}
+
+	// token berubah diantara dua query (dipakai request lain)
+	return token, ErrRefreshTokenReused
+}
+
+func (s *PostgresStorage) RevokeRefreshTokenFamily(ctx context.Context, familyId string, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+        UPDATE refresh_tokens
+        SET
+            revokedAt = $1
+        WHERE
+            familyId = $2
+            AND revokedAt IS NULL
+        `, now.Unix(), familyId)
+
+	return err
+}
+
+func (s *PostgresStorage) RevokeUserRefreshTokens(ctx context.Context, userId string, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+        UPDATE refresh_tokens
+        SET
+            revokedAt = $1
+        WHERE
+            userId = $2
+            AND revokedAt IS NULL
+        `, now.Unix(), userId)
+
+	return err
+}
+
+func (s *PostgresStorage) DeleteExpiredRefreshTokens(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `
+        DELETE FROM refresh_tokens WHERE expiresAt < $1`, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func scanRefreshToken(row *sql.Row) (*RefreshToken, error) {
+	token := &RefreshToken{}
+
+	var expiresAt, createdAt int64
+	var usedAt, revokedAt sql.NullInt64
+
+	if err := row.Scan(&token.Id, &token.FamilyId, &token.UserId, &expiresAt, &createdAt, &usedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+
+	token.ExpiresAt = time.Unix(expiresAt, 0)
+	token.CreatedAt = time.Unix(createdAt, 0)
+
+	if usedAt.Valid {
+		t := time.Unix(usedAt.Int64, 0)
+		token.UsedAt = &t
+	}
+
+	if revokedAt.Valid {
+		t := time.Unix(revokedAt.Int64, 0)
+		token.RevokedAt = &t
+	}
+
+	return token, nil
+}
+
+// ReserveLoginAttempt --> row di lock (upsert ON CONFLICT DO UPDATE) selama cek blok dan increment, supaya
+// request paralel ke instance manapun menunggu giliran dan melihat counter yang sudah bertambah.
+// Upsert juga membuat ulang row yang baru saja dihapus DeleteStaleLoginAttempts
+func (s *PostgresStorage) ReserveLoginAttempt(ctx context.Context, key string, now time.Time) (*LoginAttempt, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	attempt := &LoginAttempt{Key: key}
+
+	var lastFailureAt int64
+
+	// row dibuat kalau belum ada supaya key yang belum pernah gagal juga bisa di lock
+	if err := tx.QueryRowContext(ctx, `
+        INSERT INTO login_attempts (key, failures, lastFailureAt)
+        VALUES ($1, 0, 0)
+        ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+        RETURNING failures, lastFailureAt`, key).Scan(&attempt.Failures, &lastFailureAt); err != nil {
+		return nil, err
+	}
+
+	attempt.LastFailureAt = time.Unix(lastFailureAt, 0)
+
+	if err := reserveLoginAttempt(attempt, now); err != nil {
+		return attempt, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE login_attempts
+        SET
+            failures = $1,
+            lastFailureAt = $2
+        WHERE key = $3`, attempt.Failures, attempt.LastFailureAt.Unix(), key); err != nil {
+		return nil, err
+	}
+
+	return attempt, tx.Commit()
+}
+
+func (s *PostgresStorage) ReleaseLoginAttempt(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `
+        UPDATE login_attempts
+        SET failures = GREATEST(failures - 1, 0)
+        WHERE key = $1`, key)
+
+	return err
+}
+
+func (s *PostgresStorage) ResetLoginAttempts(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `
+        DELETE FROM login_attempts WHERE key = $1`, key)
+
+	return err
+}
+
+func (s *PostgresStorage) DeleteStaleLoginAttempts(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `
+        DELETE FROM login_attempts WHERE lastFailureAt < $1`, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
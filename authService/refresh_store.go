@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenExpired  = errors.New("refresh token expired")
+	ErrRefreshTokenRevoked  = errors.New("refresh token revoked")
+
+	// ErrRefreshTokenReused --> refresh token yang sudah pernah dipakai dipakai lagi,
+	// kemungkinan token dicuri, seluruh family harus di revoke
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// RefreshToken --> satu refresh token yang pernah dibuat. Semua token hasil rotasi dari satu login
+// punya FamilyId yang sama
+type RefreshToken struct {
+	Id        string
+	FamilyId  string
+	UserId    string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// RefreshTokenStore --> tempat refresh token disimpan supaya bisa single-use dan di revoke
+type RefreshTokenStore interface {
+	CreateRefreshToken(ctx context.Context, token *RefreshToken) error
+
+	// UseRefreshToken --> tandai token sudah dipakai secara atomic dan return token nya.
+	// Token yang sudah dipakai return ErrRefreshTokenReused
+	UseRefreshToken(ctx context.Context, id string, now time.Time) (*RefreshToken, error)
+
+	// RevokeRefreshTokenFamily --> revoke semua token di family, token yang sudah di revoke tidak berubah
+	RevokeRefreshTokenFamily(ctx context.Context, familyId string, now time.Time) error
+
+	// RevokeUserRefreshTokens --> revoke semua token user di semua family (logout-all)
+	RevokeUserRefreshTokens(ctx context.Context, userId string, now time.Time) error
+
+	// DeleteExpiredRefreshTokens --> hapus token yang sudah expired, dipanggil StoreCleaner.
+	// Token expired sudah ditolak saat verifikasi jwt, jadi row nya tidak dibutuhkan untuk deteksi reuse
+	DeleteExpiredRefreshTokens(ctx context.Context, now time.Time) (int64, error)
+}
+
+// MemoryRefreshTokenStore --> RefreshTokenStore di memory, untuk development/testing tanpa postgres
+type MemoryRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]RefreshToken
+}
+
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{
+		tokens: map[string]RefreshToken{},
+	}
+}
+
+func (s *MemoryRefreshTokenStore) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.Id] = *token
+
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) UseRefreshToken(ctx context.Context, id string, now time.Time) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+
+	if err := checkRefreshToken(&token, now); err != nil {
+		return &token, err
+	}
+
+	token.UsedAt = &now
+	s.tokens[id] = token
+
+	return &token, nil
+}
+
+func (s *MemoryRefreshTokenStore) RevokeRefreshTokenFamily(ctx context.Context, familyId string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.tokens {
+		if token.FamilyId == familyId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			s.tokens[id] = token
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) RevokeUserRefreshTokens(ctx context.Context, userId string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.tokens {
+		if token.UserId == userId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			s.tokens[id] = token
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) DeleteExpiredRefreshTokens(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, token := range s.tokens {
+		if token.ExpiresAt.Before(now) {
+			delete(s.tokens, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// checkRefreshToken --> alasan token tidak bisa dipakai, urutan nya penting:
+// token revoked tidak dianggap reuse supaya family tidak di revoke ulang
+func checkRefreshToken(token *RefreshToken, now time.Time) error {
+	switch {
+	case token.RevokedAt != nil:
+		return ErrRefreshTokenRevoked
+	case token.UsedAt != nil:
+		return ErrRefreshTokenReused
+	case !now.Before(token.ExpiresAt):
+		return ErrRefreshTokenExpired
+	}
+
+	return nil
+}
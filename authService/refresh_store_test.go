@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testRefreshToken(id, familyId, userId string, now time.Time) *RefreshToken {
+	return &RefreshToken{
+		Id:        id,
+		FamilyId:  familyId,
+		UserId:    userId,
+		ExpiresAt: now.Add(REFRESH_TOKEN_TTL),
+		CreatedAt: now,
+	}
+}
+
+func TestMemoryRefreshTokenStoreRotation(t *testing.T) {
+	store := NewMemoryRefreshTokenStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	// login lalu dua kali refresh di family yang sama
+	for _, id := range []string{"token-1", "token-2", "token-3"} {
+		if err := store.CreateRefreshToken(ctx, testRefreshToken(id, "family-1", "user-1", now)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []string{"token-1", "token-2"} {
+		token, err := store.UseRefreshToken(ctx, id, now)
+		if err != nil {
+			t.Fatalf("UseRefreshToken(%s) = %v, want nil", id, err)
+		}
+		if token.FamilyId != "family-1" || token.UserId != "user-1" || token.UsedAt == nil {
+			t.Errorf("UseRefreshToken(%s) = %+v", id, token)
+		}
+	}
+
+	if _, err := store.UseRefreshToken(ctx, "missing", now); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("UseRefreshToken(missing) = %v, want ErrRefreshTokenNotFound", err)
+	}
+
+	expired := testRefreshToken("expired", "family-2", "user-1", now.Add(-REFRESH_TOKEN_TTL))
+	if err := store.CreateRefreshToken(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.UseRefreshToken(ctx, "expired", now); !errors.Is(err, ErrRefreshTokenExpired) {
+		t.Errorf("UseRefreshToken(expired) = %v, want ErrRefreshTokenExpired", err)
+	}
+}
+
+func TestMemoryRefreshTokenStoreReuseRevokesFamily(t *testing.T) {
+	store := NewMemoryRefreshTokenStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	tokens := []*RefreshToken{
+		testRefreshToken("token-1", "family-1", "user-1", now),
+		testRefreshToken("token-2", "family-1", "user-1", now),
+		testRefreshToken("other-device", "family-2", "user-1", now),
+	}
+	for _, token := range tokens {
+		if err := store.CreateRefreshToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.UseRefreshToken(ctx, "token-1", now); err != nil {
+		t.Fatal(err)
+	}
+
+	// token-1 dipakai lagi (dicuri) --> reuse, handler revoke family nya
+	if _, err := store.UseRefreshToken(ctx, "token-1", now.Add(time.Minute)); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("second UseRefreshToken(token-1) = %v, want ErrRefreshTokenReused", err)
+	}
+
+	revokedAt := now.Add(time.Minute)
+	if err := store.RevokeRefreshTokenFamily(ctx, "family-1", revokedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	// token terbaru di family ikut tidak bisa dipakai, token reuse tidak dianggap reuse lagi
+	for _, id := range []string{"token-1", "token-2"} {
+		if _, err := store.UseRefreshToken(ctx, id, now.Add(2*time.Minute)); !errors.Is(err, ErrRefreshTokenRevoked) {
+			t.Errorf("UseRefreshToken(%s) after revoke = %v, want ErrRefreshTokenRevoked", id, err)
+		}
+	}
+
+	// revoke ulang tidak mengubah RevokedAt
+	if err := store.RevokeRefreshTokenFamily(ctx, "family-1", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.tokens["token-2"].RevokedAt; got == nil || !got.Equal(revokedAt) {
+		t.Errorf("RevokedAt after second revoke = %v, want %v", got, revokedAt)
+	}
+
+	// family lain tidak ikut di revoke
+	if _, err := store.UseRefreshToken(ctx, "other-device", now); err != nil {
+		t.Errorf("UseRefreshToken(other-device) = %v, want nil", err)
+	}
+}
+
+func TestMemoryRefreshTokenStoreRevokeUser(t *testing.T) {
+	store := NewMemoryRefreshTokenStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	tokens := []*RefreshToken{
+		testRefreshToken("phone", "family-1", "user-1", now),
+		testRefreshToken("laptop", "family-2", "user-1", now),
+		testRefreshToken("other-user", "family-3", "user-2", now),
+	}
+	for _, token := range tokens {
+		if err := store.CreateRefreshToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.RevokeUserRefreshTokens(ctx, "user-1", now); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"phone", "laptop"} {
+		if _, err := store.UseRefreshToken(ctx, id, now); !errors.Is(err, ErrRefreshTokenRevoked) {
+			t.Errorf("UseRefreshToken(%s) = %v, want ErrRefreshTokenRevoked", id, err)
+		}
+	}
+
+	if _, err := store.UseRefreshToken(ctx, "other-user", now); err != nil {
+		t.Errorf("UseRefreshToken(other-user) = %v, want nil", err)
+	}
+}
+
+func TestStoreCleanerDeletesExpiredRefreshTokens(t *testing.T) {
+	store := NewMemoryRefreshTokenStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	expired := testRefreshToken("expired", "family-1", "user-1", now.Add(-REFRESH_TOKEN_TTL-time.Second))
+	valid := testRefreshToken("valid", "family-1", "user-1", now)
+
+	for _, token := range []*RefreshToken{expired, valid} {
+		if err := store.CreateRefreshToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	NewStoreCleaner(store, NewMemoryLoginAttemptStore(), CLEANUP_INTERVAL).Clean(ctx, now)
+
+	if _, err := store.UseRefreshToken(ctx, "expired", now); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("UseRefreshToken(expired) = %v, want ErrRefreshTokenNotFound", err)
+	}
+
+	if _, err := store.UseRefreshToken(ctx, "valid", now); err != nil {
+		t.Errorf("UseRefreshToken(valid) = %v, want nil", err)
+	}
+}
+
+func TestRefreshJWT(t *testing.T) {
+	now := time.Now()
+	refresh := testRefreshToken("token-1", "family-1", "user-1", now)
+
+	token, err := createRefreshJWT(refresh, "refresh-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := parseRefreshJWT(token, "refresh-secret")
+	if err != nil {
+		t.Fatalf("parseRefreshJWT = %v, want nil", err)
+	}
+	if claims.ID != "token-1" || claims.FamilyId != "family-1" || claims.Subject != "user-1" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, err := parseRefreshJWT(token, "other-secret"); err == nil {
+		t.Errorf("parseRefreshJWT with wrong secret = nil, want error")
+	}
+
+	// token lama tanpa family ditolak
+	legacy, err := createRefreshJWT(testRefreshToken("token-2", "", "user-1", now), "refresh-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseRefreshJWT(legacy, "refresh-secret"); err == nil {
+		t.Errorf("parseRefreshJWT without family = nil, want error")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/pewe21/library"
 	"github.com/pewe21/userProto"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
@@ -20,9 +21,10 @@ type AppServer struct {
 	Cfg                 AppConfig
 	Server              http.Server
 	UserServiceGrpcConn *grpc.ClientConn
+	Cleaner             *StoreCleaner
 }
 
-func NewServer(listenAddr string, cfg AppConfig) *AppServer {
+func NewServer(listenAddr string, cfg AppConfig, store RefreshTokenStore, attempts LoginAttemptStore, keys *KeySet) *AppServer {
 
 	rb := &exampleResolverBuilder{
 		UserServiceHostname: cfg.UserServiceHostname,
@@ -37,13 +39,21 @@ func NewServer(listenAddr string, cfg AppConfig) *AppServer {
 	}
 
 	grpcClient := userProto.NewUserClient(conn)
+
+	// JWTMiddleware verifikasi token dengan key authService sendiri, bukan lewat JWKS
+	library.SetKeySource(keys)
+
+	// JWTMiddleware tolak token yang dibuat sebelum tokensValidAfter user
+	library.SetTokensValidAfterSource(library.NewTokensValidAfterCache(userServiceTokensValidAfter(grpcClient), library.TOKENS_VALID_AFTER_CACHE_TTL))
+
 	routes := mux.NewRouter().PathPrefix("/v1/auth").Subrouter()
 
-	userService := NewAuthService(cfg.JwtSecret, grpcClient, cfg.RefreshSecret)
+	userService := NewAuthService(cfg.JwtSecret, grpcClient, cfg.RefreshSecret, store, attempts, keys, cfg.TOTPEncryptionKey)
 	userService.RegisterRoutes(routes)
 	return &AppServer{
 		Cfg:                 cfg,
 		UserServiceGrpcConn: conn,
+		Cleaner:             NewStoreCleaner(store, attempts, CLEANUP_INTERVAL),
 		Server: http.Server{
 			Addr:    listenAddr,
 			Handler: routes,
@@ -68,6 +78,12 @@ func (s *AppServer) Run() {
 		return s.Server.ListenAndServe()
 	})
 
+	// hapus refresh token expired dan login attempt yang sudah lewat window
+	g.Go(func() error {
+		s.Cleaner.Run(gctx)
+		return nil
+	})
+
 	g.Go(func() error {
 		<-gctx.Done()
 
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/pewe21/library"
+)
+
+const ACCESS_TOKEN_TTL = 6 * time.Hour
+const REFRESH_TOKEN_TTL = 24 * time.Hour
+
+const ACCESS_TOKEN_COOKIE = "accessToken"
+const REFRESH_TOKEN_COOKIE = "refreshToken"
+
+// RefreshClaims --> claim refresh token, jti (ID) adalah id token di RefreshTokenStore
+// dan fam adalah family id nya
+type RefreshClaims struct {
+	FamilyId string `json:"fam"`
+	jwt.RegisteredClaims
+}
+
+type tokenPair struct {
+	accessToken      string
+	refreshToken     string
+	accessExpiresAt  time.Time
+	refreshExpiresAt time.Time
+}
+
+// issueTokens --> buat access token dan refresh token baru di family, refresh token nya disimpan di store
+func (s *AuthService) issueTokens(ctx context.Context, principal *library.Principal, familyId string) (*tokenPair, error) {
+	ch := make(chan tokenChan)
+	defer close(ch)
+
+	now := time.Now()
+	pair := &tokenPair{
+		accessExpiresAt:  now.Add(ACCESS_TOKEN_TTL),
+		refreshExpiresAt: now.Add(REFRESH_TOKEN_TTL),
+	}
+
+	refresh := &RefreshToken{
+		Id:        uuid.NewString(),
+		FamilyId:  familyId,
+		UserId:    principal.UserId,
+		ExpiresAt: pair.refreshExpiresAt,
+		CreatedAt: now,
+	}
+
+	go func() {
+		jwtToken, err := s.createAccessToken(principal, pair.accessExpiresAt)
+		ch <- tokenChan{token: jwtToken, err: err, tokenType: "access"}
+	}()
+
+	go func() {
+		refreshToken, err := createRefreshJWT(refresh, s.RefreshSecret)
+		ch <- tokenChan{token: refreshToken, err: err, tokenType: "refresh"}
+	}()
+
+	var errs []error
+
+	for i := 0; i < 2; i++ {
+		res := <-ch
+		if res.err != nil {
+			errs = append(errs, res.err)
+			continue
+		}
+		if res.tokenType == "access" {
+			pair.accessToken = res.token
+		} else {
+			pair.refreshToken = res.token
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("error when generating jwt access and refresh token: %v", errs)
+	}
+
+	if err := s.Store.CreateRefreshToken(ctx, refresh); err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// userRoles --> claim roles access token dari kolom users.role, admin tetap punya role user
+func userRoles(role string) []string {
+	if role == "" || role == library.ROLE_USER {
+		return []string{library.ROLE_USER}
+	}
+
+	return []string{library.ROLE_USER, role}
+}
+
+func createRefreshJWT(refresh *RefreshToken, secret string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, RefreshClaims{
+		FamilyId: refresh.FamilyId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        refresh.Id,
+			Subject:   refresh.UserId,
+			Issuer:    "gomicroservice",
+			ExpiresAt: jwt.NewNumericDate(refresh.ExpiresAt),
+			NotBefore: jwt.NewNumericDate(refresh.CreatedAt),
+			IssuedAt:  jwt.NewNumericDate(refresh.CreatedAt),
+		},
+	})
+
+	refreshToken, err := token.SignedString([]byte(secret))
+	if err != nil {
+		log.Printf("Error when signing refreshToken %+v", err.Error())
+		return "", err
+	}
+
+	return refreshToken, nil
+}
+
+// parseRefreshJWT --> validasi signature dan expiry refresh token. Token lama tanpa jti/fam ditolak
+func parseRefreshJWT(refreshToken, secret string) (*RefreshClaims, error) {
+	claims := &RefreshClaims{}
+
+	token, err := jwt.ParseWithClaims(refreshToken, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %+v", t.Header["alg"])
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || claims.ID == "" || claims.FamilyId == "" || claims.Subject == "" {
+		return nil, fmt.Errorf("invalid refresh token claims")
+	}
+
+	return claims, nil
+}
+
+// getRefreshToken --> refresh token dari body json, fallback ke cookie refreshToken
+func getRefreshToken(body []byte, r *http.Request) string {
+	re := &Refresh{}
+	if len(body) > 0 && json.Unmarshal(body, re) == nil && re.RefreshToken != "" {
+		return re.RefreshToken
+	}
+
+	if cookie, err := r.Cookie(REFRESH_TOKEN_COOKIE); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+func setTokenCookies(w http.ResponseWriter, pair *tokenPair) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ACCESS_TOKEN_COOKIE,
+		Value:    pair.accessToken,
+		Path:     "/",
+		Expires:  pair.accessExpiresAt,
+		HttpOnly: true,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     REFRESH_TOKEN_COOKIE,
+		Value:    pair.refreshToken,
+		Path:     "/",
+		Expires:  pair.refreshExpiresAt,
+		HttpOnly: true,
+	})
+}
+
+func clearTokenCookies(w http.ResponseWriter) {
+	for _, name := range []string{ACCESS_TOKEN_COOKIE, REFRESH_TOKEN_COOKIE} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			HttpOnly: true,
+		})
+	}
+}
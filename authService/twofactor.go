@@ -0,0 +1,517 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/pewe21/library"
+	"github.com/pewe21/userProto"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// issuer yang tampil di authenticator app
+const TWO_FACTOR_ISSUER = "gomicroservice"
+
+// challenge token dari login langkah pertama, hanya bisa dipakai di /2fa/login
+const TWO_FACTOR_CHALLENGE_TTL = 5 * time.Minute
+const TWO_FACTOR_CHALLENGE_AUDIENCE = "gomicroservice-2fa"
+
+const RECOVERY_CODE_COUNT = 10
+
+// kode TOTP dari 30 detik sebelum/sesudah masih diterima, untuk jam device yang sedikit beda
+var totpValidateOpts = totp.ValidateOpts{
+	Period:    30,
+	Skew:      1,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+func (s *AuthService) handleTwoFactorSetup(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle two factor setup")
+
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+
+	if s.TOTPKey == nil {
+		return http.StatusServiceUnavailable, fmt.Errorf("two factor authentication is not configured")
+	}
+
+	accountName := principal.Username
+	if accountName == "" {
+		accountName = principal.UserId
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      TWO_FACTOR_ISSUER,
+		AccountName: accountName,
+	})
+	if err != nil {
+		log.Println("Error when generating totp key:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	encryptedSecret, err := encryptTOTPSecret(s.TOTPKey, principal.UserId, key.Secret())
+	if err != nil {
+		log.Println("Error when encrypting totp secret:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	in := &userProto.SaveTwoFactorSecretReq{
+		Id:              principal.UserId,
+		EncryptedSecret: encryptedSecret,
+	}
+
+	if _, err := s.UserServiceGrpcClient.SaveTwoFactorSecret(r.Context(), in); err != nil {
+		appErr := library.FromGrpcError(err)
+		switch appErr.Code {
+		case library.ERR_NOT_FOUND:
+			return http.StatusUnauthorized, library.Unauthorized("invalid token")
+		case library.ERR_PRECONDITION:
+			return http.StatusConflict, library.FailedPrecondition("two factor authentication already enabled")
+		}
+		log.Println("Error when calling SaveTwoFactorSecret:", err)
+		return appErr.HttpStatus, appErr
+	}
+
+	resp := library.NewResp("scan the otpauth uri then verify the code", map[string]interface{}{"otpauthUri": key.URL(), "secret": key.Secret()})
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+func (s *AuthService) handleTwoFactorVerify(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle two factor verify")
+
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Error when reading body:", err)
+		return http.StatusBadRequest, fmt.Errorf("invalid two factor code")
+	}
+
+	defer r.Body.Close()
+
+	req := &TwoFactorCode{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid two factor code")
+	}
+
+	twoFactor, err := s.UserServiceGrpcClient.GetTwoFactor(r.Context(), &userProto.GetUserByIdReq{Id: principal.UserId})
+	if err != nil {
+		appErr := library.FromGrpcError(err)
+		if appErr.Code == library.ERR_NOT_FOUND {
+			return http.StatusUnauthorized, library.Unauthorized("invalid token")
+		}
+		log.Println("Error when calling GetTwoFactor:", err)
+		return appErr.HttpStatus, appErr
+	}
+
+	if twoFactor.Enabled {
+		return http.StatusConflict, fmt.Errorf("two factor authentication already enabled")
+	}
+
+	if twoFactor.EncryptedSecret == "" {
+		return http.StatusBadRequest, fmt.Errorf("two factor setup has not been started")
+	}
+
+	step, valid, err := s.validateTOTP(principal.UserId, twoFactor.EncryptedSecret, req.Code, time.Now())
+	if err != nil {
+		log.Println("Error when validating totp code:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	if valid {
+		valid, err = s.useTOTPStep(r.Context(), principal.UserId, step)
+		if err != nil {
+			appErr := library.FromGrpcError(err)
+			if appErr.Code == library.ERR_NOT_FOUND {
+				return http.StatusUnauthorized, library.Unauthorized("invalid token")
+			}
+			log.Println("Error when calling UseTotpStep:", err)
+			return appErr.HttpStatus, appErr
+		}
+	}
+
+	if !valid {
+		return http.StatusBadRequest, fmt.Errorf("invalid two factor code")
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := generateRecoveryCodes(RECOVERY_CODE_COUNT)
+	if err != nil {
+		log.Println("Error when generating recovery codes:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	in := &userProto.EnableTwoFactorReq{
+		Id:                 principal.UserId,
+		RecoveryCodeHashes: recoveryCodeHashes,
+	}
+
+	if _, err := s.UserServiceGrpcClient.EnableTwoFactor(r.Context(), in); err != nil {
+		appErr := library.FromGrpcError(err)
+		switch appErr.Code {
+		case library.ERR_NOT_FOUND:
+			return http.StatusUnauthorized, library.Unauthorized("invalid token")
+		case library.ERR_PRECONDITION:
+			return http.StatusConflict, library.FailedPrecondition("two factor authentication already enabled")
+		}
+		log.Println("Error when calling EnableTwoFactor:", err)
+		return appErr.HttpStatus, appErr
+	}
+
+	// recovery code hanya dikirim sekali, yang disimpan cuma hash nya
+	resp := library.NewResp("two factor authentication enabled", map[string]interface{}{"recoveryCodes": recoveryCodes})
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+// handleTwoFactorLogin --> login langkah kedua, kode yang salah dihitung seperti password salah
+func (s *AuthService) handleTwoFactorLogin(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle two factor login")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Error when reading body:", err)
+		return http.StatusBadRequest, fmt.Errorf("invalid two factor code")
+	}
+
+	defer r.Body.Close()
+
+	req := &TwoFactorLogin{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid two factor code")
+	}
+
+	claims, err := parseTwoFactorChallengeJWT(req.ChallengeToken, s.RefreshSecret)
+	if err != nil {
+		return http.StatusUnauthorized, fmt.Errorf("invalid challenge token")
+	}
+
+	userId := claims.Subject
+
+	// challenge yang dibuat sebelum ganti password/logout-all ikut tidak valid
+	if err := library.CheckTokensValidAfter(r.Context(), userId, claims.IssuedAt.Time); err != nil {
+		if errors.Is(err, library.ErrTokenRevoked) || errors.Is(err, library.ErrUnknownTokenSubject) {
+			return http.StatusUnauthorized, fmt.Errorf("invalid challenge token")
+		}
+		log.Println("Error when checking tokensValidAfter:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	// sama seperti password, percobaan di reserve dulu sebelum kode nya dicek
+	now := time.Now()
+	attemptKeys := []string{loginTwoFactorKey(userId), loginIPKey(clientIP(r))}
+
+	if code, err := s.reserveLoginAttempts(w, r, attemptKeys, now); err != nil {
+		return code, err
+	}
+
+	twoFactor, err := s.UserServiceGrpcClient.GetTwoFactor(r.Context(), &userProto.GetUserByIdReq{Id: userId})
+	if err != nil {
+		s.releaseLoginAttempts(r, attemptKeys)
+		appErr := library.FromGrpcError(err)
+		if appErr.Code == library.ERR_NOT_FOUND {
+			return http.StatusUnauthorized, library.Unauthorized("invalid challenge token")
+		}
+		log.Println("Error when calling GetTwoFactor:", err)
+		return appErr.HttpStatus, appErr
+	}
+
+	if !twoFactor.Enabled {
+		s.releaseLoginAttempts(r, attemptKeys)
+		return http.StatusUnauthorized, fmt.Errorf("invalid challenge token")
+	}
+
+	var valid bool
+
+	switch {
+	case req.Code != "":
+		var step int64
+		step, valid, err = s.validateTOTP(userId, twoFactor.EncryptedSecret, req.Code, now)
+		if err != nil {
+			s.releaseLoginAttempts(r, attemptKeys)
+			log.Println("Error when validating totp code:", err)
+			return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		}
+
+		// kode yang sudah pernah dipakai dihitung sebagai kode salah
+		if valid {
+			valid, err = s.useTOTPStep(r.Context(), userId, step)
+			if err != nil {
+				s.releaseLoginAttempts(r, attemptKeys)
+				appErr := library.FromGrpcError(err)
+				if appErr.Code == library.ERR_NOT_FOUND {
+					return http.StatusUnauthorized, library.Unauthorized("invalid challenge token")
+				}
+				log.Println("Error when calling UseTotpStep:", err)
+				return appErr.HttpStatus, appErr
+			}
+		}
+	case req.RecoveryCode != "":
+		in := &userProto.UseRecoveryCodeReq{
+			Id:       userId,
+			CodeHash: hashRecoveryCode(req.RecoveryCode),
+		}
+
+		if _, err := s.UserServiceGrpcClient.UseRecoveryCode(r.Context(), in); err != nil {
+			if appErr := library.FromGrpcError(err); appErr.Code != library.ERR_NOT_FOUND {
+				s.releaseLoginAttempts(r, attemptKeys)
+				log.Println("Error when calling UseRecoveryCode:", err)
+				return appErr.HttpStatus, appErr
+			}
+		} else {
+			valid = true
+		}
+	}
+
+	// kode salah sudah dihitung gagal saat reserve
+	if !valid {
+		return http.StatusBadRequest, fmt.Errorf("invalid two factor code")
+	}
+
+	s.loginAttemptSucceeded(r, attemptKeys)
+
+	userDb, err := s.UserServiceGrpcClient.GetUserById(r.Context(), &userProto.GetUserByIdReq{Id: userId})
+	if err != nil {
+		appErr := library.FromGrpcError(err)
+		if appErr.Code == library.ERR_NOT_FOUND {
+			return http.StatusUnauthorized, library.Unauthorized("invalid challenge token")
+		}
+		log.Println("Error when calling GetUserById:", err)
+		return appErr.HttpStatus, appErr
+	}
+
+	if userDb.SuspendedAt != 0 {
+		return http.StatusForbidden, library.ErrAccountSuspended
+	}
+
+	principal := &library.Principal{
+		UserId:   userDb.Id,
+		Username: userDb.Username,
+		Roles:    userRoles(userDb.Role),
+		TokenId:  uuid.NewString(),
+	}
+
+	return s.writeNewSession(w, r, principal)
+}
+
+// validateTOTP --> cek kode terhadap step sekarang dan step dalam skew, return step yang cocok
+// supaya kode yang sama tidak bisa dipakai lagi lewat useTOTPStep
+func (s *AuthService) validateTOTP(userId, encryptedSecret, code string, now time.Time) (int64, bool, error) {
+	if s.TOTPKey == nil {
+		return 0, false, fmt.Errorf("TOTP_ENCRYPTION_KEY is not set")
+	}
+
+	secret, err := decryptTOTPSecret(s.TOTPKey, userId, encryptedSecret)
+	if err != nil {
+		return 0, false, err
+	}
+
+	code = strings.TrimSpace(code)
+	period := int64(totpValidateOpts.Period)
+	current := now.Unix() / period
+	skew := int64(totpValidateOpts.Skew)
+
+	for step := current - skew; step <= current+skew; step++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*period, 0), totpValidateOpts)
+		if err != nil {
+			return 0, false, err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// useTOTPStep --> simpan step kode yang diterima di userService, false kalau kode di step itu
+// atau sesudahnya sudah pernah dipakai (replay kode yang sama dalam 90 detik)
+func (s *AuthService) useTOTPStep(ctx context.Context, userId string, step int64) (bool, error) {
+	in := &userProto.UseTotpStepReq{
+		Id:   userId,
+		Step: step,
+	}
+
+	if _, err := s.UserServiceGrpcClient.UseTotpStep(ctx, in); err != nil {
+		if library.FromGrpcError(err).Code == library.ERR_PRECONDITION {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// TwoFactorChallengeClaims --> claim challenge token, audience nya beda supaya tidak bisa dipakai sebagai access/refresh token
+type TwoFactorChallengeClaims struct {
+	jwt.RegisteredClaims
+}
+
+func createTwoFactorChallengeJWT(userId, secret string) (string, error) {
+	now := time.Now()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, TwoFactorChallengeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   userId,
+			Issuer:    library.JWT_ISSUER,
+			Audience:  jwt.ClaimStrings{TWO_FACTOR_CHALLENGE_AUDIENCE},
+			ExpiresAt: jwt.NewNumericDate(now.Add(TWO_FACTOR_CHALLENGE_TTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+
+	challengeToken, err := token.SignedString([]byte(secret))
+	if err != nil {
+		log.Printf("Error when signing challengeToken %+v", err.Error())
+		return "", err
+	}
+
+	return challengeToken, nil
+}
+
+func parseTwoFactorChallengeJWT(challengeToken, secret string) (*TwoFactorChallengeClaims, error) {
+	claims := &TwoFactorChallengeClaims{}
+
+	token, err := jwt.ParseWithClaims(challengeToken, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || claims.Subject == "" || claims.IssuedAt == nil || !claims.VerifyAudience(TWO_FACTOR_CHALLENGE_AUDIENCE, true) {
+		return nil, fmt.Errorf("invalid challenge token claims")
+	}
+
+	return claims, nil
+}
+
+// encryptTOTPSecret --> AES-256-GCM, userId dipakai sebagai associated data supaya ciphertext
+// tidak bisa dipindah ke user lain. Hasilnya base64(nonce + ciphertext)
+func encryptTOTPSecret(key []byte, userId, secret string) (string, error) {
+	gcm, err := newTOTPCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), []byte(userId))
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptTOTPSecret(key []byte, userId, encryptedSecret string) (string, error) {
+	gcm, err := newTOTPCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encryptedSecret)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted totp secret")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+	secret, err := gcm.Open(nil, nonce, ciphertext, []byte(userId))
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+func newTOTPCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// parseTOTPEncryptionKey --> key base64 32 byte, kosong return nil
+func parseTOTPEncryptionKey(value string) ([]byte, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP_ENCRYPTION_KEY: %w", err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid TOTP_ENCRYPTION_KEY: must be 32 bytes, got %d", len(key))
+	}
+
+	return key, nil
+}
+
+// generateRecoveryCodes --> recovery code random 10 karakter base32 (xxxxx-xxxxx) beserta hash nya
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	recoveryCodes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+
+	for i := 0; i < n; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+		code = code[:5] + "-" + code[5:]
+
+		recoveryCodes = append(recoveryCodes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return recoveryCodes, hashes, nil
+}
+
+// hashRecoveryCode --> sha256 hex, huruf besar/kecil, spasi dan - diabaikan
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pewe21/library"
+	"github.com/pewe21/userProto"
+	"github.com/pquerna/otp/totp"
+	"google.golang.org/grpc"
+)
+
+var testTOTPKey = bytes.Repeat([]byte{0x42}, 32)
+
+func TestTOTPSecretEncryption(t *testing.T) {
+	encrypted, err := encryptTOTPSecret(testTOTPKey, "user-1", "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := decryptTOTPSecret(testTOTPKey, "user-1", encrypted)
+	if err != nil || secret != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("decryptTOTPSecret = %q, %v, want JBSWY3DPEHPK3PXP", secret, err)
+	}
+
+	// nonce random, secret yang sama menghasilkan ciphertext yang beda
+	again, err := encryptTOTPSecret(testTOTPKey, "user-1", "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == encrypted {
+		t.Errorf("encryptTOTPSecret returned the same ciphertext twice")
+	}
+
+	sealed, _ := base64.StdEncoding.DecodeString(encrypted)
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 0x01
+
+	invalid := []struct {
+		name      string
+		key       []byte
+		userId    string
+		encrypted string
+	}{
+		{"other user", testTOTPKey, "user-2", encrypted},
+		{"other key", bytes.Repeat([]byte{0x24}, 32), "user-1", encrypted},
+		{"tampered", testTOTPKey, "user-1", base64.StdEncoding.EncodeToString(tampered)},
+		{"truncated", testTOTPKey, "user-1", base64.StdEncoding.EncodeToString(sealed[:8])},
+		{"not base64", testTOTPKey, "user-1", "not base64!"},
+		{"empty", testTOTPKey, "user-1", ""},
+		{"short key", testTOTPKey[:10], "user-1", encrypted},
+	}
+
+	for _, tt := range invalid {
+		if _, err := decryptTOTPSecret(tt.key, tt.userId, tt.encrypted); err == nil {
+			t.Errorf("%s: decryptTOTPSecret error = nil, want error", tt.name)
+		}
+	}
+}
+
+func TestParseTOTPEncryptionKey(t *testing.T) {
+	key, err := parseTOTPEncryptionKey(base64.StdEncoding.EncodeToString(testTOTPKey))
+	if err != nil || !bytes.Equal(key, testTOTPKey) {
+		t.Errorf("parseTOTPEncryptionKey = %x, %v, want %x", key, err, testTOTPKey)
+	}
+
+	if key, err := parseTOTPEncryptionKey(""); key != nil || err != nil {
+		t.Errorf("parseTOTPEncryptionKey(\"\") = %x, %v, want nil, nil", key, err)
+	}
+
+	invalid := []string{
+		"not base64!",
+		base64.StdEncoding.EncodeToString(testTOTPKey[:16]),
+		base64.StdEncoding.EncodeToString(append(bytes.Clone(testTOTPKey), 0)),
+	}
+
+	for _, value := range invalid {
+		if _, err := parseTOTPEncryptionKey(value); err == nil {
+			t.Errorf("parseTOTPEncryptionKey(%q) error = nil, want error", value)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	s := &AuthService{TOTPKey: testTOTPKey}
+	now := time.Unix(1700000000, 0)
+	current := now.Unix() / 30
+
+	encrypted, err := encryptTOTPSecret(testTOTPKey, "user-1", "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codeAt := func(at time.Time) string {
+		code, err := totp.GenerateCodeCustom("JBSWY3DPEHPK3PXP", at, totpValidateOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	code := codeAt(now)
+
+	tests := []struct {
+		code  string
+		step  int64
+		valid bool
+	}{
+		{code, current, true},
+		{" " + code + " ", current, true},
+		{codeAt(now.Add(-30 * time.Second)), current - 1, true},
+		{codeAt(now.Add(30 * time.Second)), current + 1, true},
+		{codeAt(now.Add(-60 * time.Second)), 0, false},
+		{"000000000", 0, false},
+		{"abcdef", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		step, valid, err := s.validateTOTP("user-1", encrypted, tt.code, now)
+		if err != nil || valid != tt.valid || step != tt.step {
+			t.Errorf("validateTOTP(%q) = %d, %v, %v, want %d, %v", tt.code, step, valid, err, tt.step, tt.valid)
+		}
+	}
+
+	if _, _, err := s.validateTOTP("user-2", encrypted, code, now); err == nil {
+		t.Errorf("validateTOTP with secret of other user error = nil, want error")
+	}
+
+	if _, _, err := (&AuthService{}).validateTOTP("user-1", encrypted, code, now); err == nil {
+		t.Errorf("validateTOTP without TOTPKey error = nil, want error")
+	}
+}
+
+// fakeTotpStepClient --> userService yang hanya menyimpan step TOTP terakhir
+type fakeTotpStepClient struct {
+	userProto.UserClient
+	lastStep map[string]int64
+}
+
+func (c *fakeTotpStepClient) UseTotpStep(ctx context.Context, in *userProto.UseTotpStepReq, opts ...grpc.CallOption) (*userProto.TwoFactorResp, error) {
+	if in.Step <= c.lastStep[in.Id] {
+		return nil, library.FailedPrecondition("two factor code already used")
+	}
+	c.lastStep[in.Id] = in.Step
+	return &userProto.TwoFactorResp{Id: in.Id}, nil
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	s := &AuthService{UserServiceGrpcClient: &fakeTotpStepClient{lastStep: map[string]int64{}}}
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		userId string
+		step   int64
+		want   bool
+	}{
+		{"first code", "user-1", 100, true},
+		{"same code replayed", "user-1", 100, false},
+		{"older code inside skew", "user-1", 99, false},
+		{"next code", "user-1", 101, true},
+		{"other user same step", "user-2", 100, true},
+	}
+
+	for _, tt := range tests {
+		if got, err := s.useTOTPStep(ctx, tt.userId, tt.step); err != nil || got != tt.want {
+			t.Errorf("%s: useTOTPStep = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes(RECOVERY_CODE_COUNT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != RECOVERY_CODE_COUNT || len(hashes) != RECOVERY_CODE_COUNT {
+		t.Fatalf("generateRecoveryCodes = %d codes, %d hashes, want %d", len(codes), len(hashes), RECOVERY_CODE_COUNT)
+	}
+
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := map[string]bool{}
+
+	for i, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("recovery code %q does not match xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("recovery code %q generated twice", code)
+		}
+		seen[code] = true
+
+		if hashes[i] != hashRecoveryCode(code) {
+			t.Errorf("hash of %q = %s, want %s", code, hashes[i], hashRecoveryCode(code))
+		}
+	}
+
+	// input user dinormalisasi sebelum di hash
+	want := hashRecoveryCode("abcde-fghij")
+	for _, code := range []string{"abcdefghij", "ABCDE-FGHIJ", " abcde fghij ", "abc-de-fghij"} {
+		if got := hashRecoveryCode(code); got != want {
+			t.Errorf("hashRecoveryCode(%q) = %s, want %s", code, got, want)
+		}
+	}
+
+	if hashRecoveryCode("abcde-fghik") == want {
+		t.Errorf("different recovery codes have the same hash")
+	}
+}
+
+func TestTwoFactorChallengeJWT(t *testing.T) {
+	challenge, err := createTwoFactorChallengeJWT("user-1", "refresh-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := parseTwoFactorChallengeJWT(challenge, "refresh-secret")
+	if err != nil {
+		t.Fatalf("parseTwoFactorChallengeJWT = %v, want nil", err)
+	}
+	if claims.Subject != "user-1" || claims.IssuedAt == nil {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// challenge token tidak bisa dipakai sebagai refresh token
+	if _, err := parseRefreshJWT(challenge, "refresh-secret"); err == nil {
+		t.Errorf("parseRefreshJWT(challenge) error = nil, want error")
+	}
+
+	// refresh token dengan secret yang sama tidak punya audience challenge
+	refresh, err := createRefreshJWT(testRefreshToken("token-1", "family-1", "user-1", time.Now()), "refresh-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	sign := func(claims jwt.Claims, method jwt.SigningMethod) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString([]byte("refresh-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	challengeClaims := func(audience string, expiresAt time.Time) jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Subject:   "user-1",
+			Issuer:    library.JWT_ISSUER,
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		}
+	}
+
+	noIssuedAt := challengeClaims(TWO_FACTOR_CHALLENGE_AUDIENCE, now.Add(time.Minute))
+	noIssuedAt.IssuedAt = nil
+
+	noSubject := challengeClaims(TWO_FACTOR_CHALLENGE_AUDIENCE, now.Add(time.Minute))
+	noSubject.Subject = ""
+
+	invalid := map[string]string{
+		"refresh token":  refresh,
+		"wrong audience": sign(challengeClaims("gomicroservice", now.Add(time.Minute)), jwt.SigningMethodHS256),
+		"expired":        sign(challengeClaims(TWO_FACTOR_CHALLENGE_AUDIENCE, now.Add(-time.Second)), jwt.SigningMethodHS256),
+		"no issued at":   sign(noIssuedAt, jwt.SigningMethodHS256),
+		"no subject":     sign(noSubject, jwt.SigningMethodHS256),
+		"hs512":          sign(challengeClaims(TWO_FACTOR_CHALLENGE_AUDIENCE, now.Add(time.Minute)), jwt.SigningMethodHS512),
+		"malformed":      "not-a-jwt",
+	}
+
+	for name, token := range invalid {
+		if _, err := parseTwoFactorChallengeJWT(token, "refresh-secret"); err == nil {
+			t.Errorf("%s: parseTwoFactorChallengeJWT error = nil, want error", name)
+		}
+	}
+
+	if _, err := parseTwoFactorChallengeJWT(challenge, "other-secret"); err == nil {
+		t.Errorf("parseTwoFactorChallengeJWT with wrong secret error = nil, want error")
+	}
+}
@@ -14,3 +14,14 @@ type LoginUser struct {
 type Refresh struct {
 	RefreshToken string `json:"refreshToken"`
 }
+
+type TwoFactorCode struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorLogin --> isi salah satu, code dari authenticator app atau recoveryCode
+type TwoFactorLogin struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recoveryCode"`
+}
@@ -22,7 +22,10 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type UserClient interface {
+	// CreateImage --> kirim seluruh file dalam satu message, dibatasi 4MB default grpc.
+	// Gunakan UploadImage untuk file besar
 	CreateImage(ctx context.Context, in *CreateImageReq, opts ...grpc.CallOption) (*ImageResp, error)
+	UploadImage(ctx context.Context, opts ...grpc.CallOption) (User_UploadImageClient, error)
 }
 
 type userClient struct {
@@ -42,11 +45,48 @@ func (c *userClient) CreateImage(ctx context.Context, in *CreateImageReq, opts .
 	return out, nil
 }
 
+func (c *userClient) UploadImage(ctx context.Context, opts ...grpc.CallOption) (User_UploadImageClient, error) {
+	stream, err := c.cc.NewStream(ctx, &User_ServiceDesc.Streams[0], "/imageProto.User/UploadImage", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &userUploadImageClient{stream}
+	return x, nil
+}
+
+type User_UploadImageClient interface {
+	Send(*UploadImageReq) error
+	CloseAndRecv() (*ImageResp, error)
+	grpc.ClientStream
+}
+
+type userUploadImageClient struct {
+	grpc.ClientStream
+}
+
+func (x *userUploadImageClient) Send(m *UploadImageReq) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *userUploadImageClient) CloseAndRecv() (*ImageResp, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImageResp)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // UserServer is the server API for User service.
 // All implementations must embed UnimplementedUserServer
 // for forward compatibility
 type UserServer interface {
+	// CreateImage --> kirim seluruh file dalam satu message, dibatasi 4MB default grpc.
+	// Gunakan UploadImage untuk file besar
 	CreateImage(context.Context, *CreateImageReq) (*ImageResp, error)
+	UploadImage(User_UploadImageServer) error
 	mustEmbedUnimplementedUserServer()
 }
 
@@ -57,6 +97,9 @@ type UnimplementedUserServer struct {
 func (UnimplementedUserServer) CreateImage(context.Context, *CreateImageReq) (*ImageResp, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateImage not implemented")
 }
+func (UnimplementedUserServer) UploadImage(User_UploadImageServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadImage not implemented")
+}
 func (UnimplementedUserServer) mustEmbedUnimplementedUserServer() {}
 
 // UnsafeUserServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +131,32 @@ func _User_CreateImage_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _User_UploadImage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(UserServer).UploadImage(&userUploadImageServer{stream})
+}
+
+type User_UploadImageServer interface {
+	SendAndClose(*ImageResp) error
+	Recv() (*UploadImageReq, error)
+	grpc.ServerStream
+}
+
+type userUploadImageServer struct {
+	grpc.ServerStream
+}
+
+func (x *userUploadImageServer) SendAndClose(m *ImageResp) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *userUploadImageServer) Recv() (*UploadImageReq, error) {
+	m := new(UploadImageReq)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // User_ServiceDesc is the grpc.ServiceDesc for User service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -100,6 +169,12 @@ var User_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _User_CreateImage_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadImage",
+			Handler:       _User_UploadImage_Handler,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "image.proto",
 }
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrInvalidBlobKey --> key yang keluar dari root dir (absolute path, "..", dll)
+var ErrInvalidBlobKey = errors.New("invalid blob key")
+
+// BlobStore --> tempat file image disimpan, key nya berbentuk "original/<filename>" atau "thumbnail/<filename>"
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type BlobInfo struct {
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+
+	// strong ETag (sudah dengan tanda kutip) dari hash isi file
+	ETag string
+}
+
+// NewBlobStore --> pilih implementasi BlobStore berdasarkan BLOB_STORE
+func NewBlobStore(cfg AppConfig) (BlobStore, error) {
+	switch cfg.BlobStore {
+	case "local":
+		return NewLocalBlobStore(cfg.DataDir), nil
+	case "s3":
+		return NewS3BlobStore(cfg.S3)
+	case "memory":
+		return NewMemoryBlobStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown blob store: %s", cfg.BlobStore)
+	}
+}
+
+// LocalBlobStore --> simpan file di local disk dibawah root dir, content type disimpan
+// di file metadata <filename>.meta disebelahnya
+type LocalBlobStore struct {
+	root string
+}
+
+const localMetaSuffix = ".meta"
+
+type localBlobMeta struct {
+	ContentType string `json:"contentType"`
+	Sha256      string `json:"sha256,omitempty"`
+}
+
+func NewLocalBlobStore(root string) *LocalBlobStore {
+	return &LocalBlobStore{
+		root: root,
+	}
+}
+
+// filename --> path file untuk key, key harus relatif dan tetap di dalam root dir
+func (s *LocalBlobStore) filename(key string) (string, error) {
+	if strings.ContainsRune(key, '\\') || !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", ErrInvalidBlobKey
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	filename, err := s.filename(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(localBlobMeta{ContentType: contentType, Sha256: sha256Hex(data)})
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filename+localMetaSuffix, meta); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filename, data)
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	filename, err := s.filename(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrBlobNotFound
+		}
+		return nil, nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	meta := s.readMeta(filename)
+
+	// file lama yang belum punya hash di metadata, hash dihitung dari isi file
+	if meta.Sha256 == "" {
+		h := sha256.New()
+		if _, err := io.Copy(h, file); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+
+		meta.Sha256 = hex.EncodeToString(h.Sum(nil))
+	}
+
+	info := &BlobInfo{
+		ContentType: meta.ContentType,
+		Size:        stat.Size(),
+		ModTime:     stat.ModTime(),
+		ETag:        `"` + meta.Sha256 + `"`,
+	}
+
+	return file, info, nil
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	filename, err := s.filename(key)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{filename, filename + localMetaSuffix} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readMeta --> baca file metadata, file lama yang belum punya metadata pakai
+// content type dari extension
+func (s *LocalBlobStore) readMeta(filename string) localBlobMeta {
+	meta := localBlobMeta{}
+
+	data, err := os.ReadFile(filename + localMetaSuffix)
+	if err != nil || json.Unmarshal(data, &meta) != nil {
+		meta = localBlobMeta{}
+	}
+
+	if meta.ContentType == "" {
+		meta.ContentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+
+	return meta
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// tulis ke file sementara lalu rename, supaya reader tidak pernah baca file yang setengah jadi
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".upload-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// MemoryBlobStore --> BlobStore di memory, untuk development/testing tanpa disk atau s3
+type MemoryBlobStore struct {
+	mu    sync.RWMutex
+	blobs map[string]memoryBlob
+}
+
+type memoryBlob struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+	etag        string
+}
+
+func NewMemoryBlobStore() *MemoryBlobStore {
+	return &MemoryBlobStore{
+		blobs: map[string]memoryBlob{},
+	}
+}
+
+func (s *MemoryBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blobs[key] = memoryBlob{
+		data:        bytes.Clone(data),
+		contentType: contentType,
+		modTime:     time.Now(),
+		etag:        `"` + sha256Hex(data) + `"`,
+	}
+
+	return nil
+}
+
+func (s *MemoryBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blob, ok := s.blobs[key]
+	if !ok {
+		return nil, nil, ErrBlobNotFound
+	}
+
+	info := &BlobInfo{
+		ContentType: blob.contentType,
+		Size:        int64(len(blob.data)),
+		ModTime:     blob.modTime,
+		ETag:        blob.etag,
+	}
+
+	return io.NopCloser(bytes.NewReader(blob.data)), info, nil
+}
+
+func (s *MemoryBlobStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.blobs, key)
+
+	return nil
+}
@@ -3,24 +3,62 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type AppConfig struct {
 	Host         string
 	Port         string
 	InternalPort string
+	GrpcPort     string
+
+	// BLOB_STORE: local (default), s3 atau memory
+	BlobStore string
+	DataDir   string
+	S3        S3Config
+
+	// THUMBNAIL_SIZES: lebar thumbnail yang dibuat saat upload, contoh "64,256,512,1024"
+	ThumbnailSizes []int
+	// IMAGE_RESIZE_ALLOWLIST: width/height yang boleh diminta di GET /v1/image/{filename}?w=&h=
+	ResizeAllowlist []int
+
+	// MAX_UPLOAD_SIZE: ukuran maksimal file (byte) yang diterima UploadImage grpc
+	MaxUploadSize int64
+
+	RabbitMQHostname string
+
+	// USER_SERVICE_HOSTNAME: grpc userService untuk cek tokensValidAfter di JWTMiddleware
+	UserServiceHostname string
+
+	// IMAGE_GC_INTERVAL: jarak antar sweep image yang tidak dipakai
+	GCInterval time.Duration
+	// IMAGE_GC_GRACE_PERIOD: image dengan refCount 0 baru dihapus setelah grace period
+	GCGracePeriod time.Duration
+
+	// IMAGE_URL_SECRET: secret HMAC signed url image private
+	ImageURLSecret string
+	// SIGNED_URL_TTL: masa berlaku signed url
+	SignedURLTTL time.Duration
 }
 
 func InitConfig() AppConfig {
 	port := os.Getenv("PORT")
 	host := os.Getenv("HOST")
 	internalPort := os.Getenv("INTERNAL_PORT")
+	grpcPort := os.Getenv("GRPC_PORT")
 
 	if internalPort == "" {
 		log.Println("INTERNAL_PORT environment variable is missing, fallback to :3001")
 		internalPort = ":3001"
 	}
 
+	if grpcPort == "" {
+		log.Println("GRPC_PORT environment variable is missing, fallback to :4001")
+		grpcPort = ":4001"
+	}
+
 	if port == "" {
 		log.Println("Port is not set. Using default port 3001")
 		port = ":3001"
@@ -31,10 +69,121 @@ func InitConfig() AppConfig {
 		host = "localhost"
 	}
 
+	blobStore := os.Getenv("BLOB_STORE")
+	if blobStore == "" {
+		log.Println("BLOB_STORE environment variable is missing, fallback to local")
+		blobStore = "local"
+	}
+
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+
+	s3Region := os.Getenv("S3_REGION")
+	if s3Region == "" {
+		s3Region = "us-east-1"
+	}
+
+	thumbnailSizes := parseSizes(os.Getenv("THUMBNAIL_SIZES"))
+	if len(thumbnailSizes) == 0 {
+		log.Println("THUMBNAIL_SIZES environment variable is missing/invalid, fallback to 64,256,512,1024")
+		thumbnailSizes = []int{64, 256, 512, 1024}
+	}
+
+	resizeAllowlist := parseSizes(os.Getenv("IMAGE_RESIZE_ALLOWLIST"))
+	if len(resizeAllowlist) == 0 {
+		log.Println("IMAGE_RESIZE_ALLOWLIST environment variable is missing/invalid, fallback to 32,64,128,256,512,1024")
+		resizeAllowlist = []int{32, 64, 128, 256, 512, 1024}
+	}
+
+	maxUploadSize, err := strconv.ParseInt(os.Getenv("MAX_UPLOAD_SIZE"), 10, 64)
+	if err != nil || maxUploadSize <= 0 {
+		log.Println("MAX_UPLOAD_SIZE environment variable is missing/invalid, fallback to 20MB")
+		maxUploadSize = 20 << 20
+	}
+
+	rabbitMQHostname := os.Getenv("RABBITMQ_HOSTNAME")
+	if rabbitMQHostname == "" {
+		log.Println("RABBITMQ_HOSTNAME is not found, fallback to localhost")
+		rabbitMQHostname = "localhost"
+	}
+
+	userServiceHostname := os.Getenv("USER_SERVICE_HOSTNAME")
+	if userServiceHostname == "" {
+		log.Println("USER_SERVICE_HOSTNAME is not found, fallback to localhost")
+		userServiceHostname = "localhost"
+	}
+
+	gcInterval, err := time.ParseDuration(os.Getenv("IMAGE_GC_INTERVAL"))
+	if err != nil || gcInterval <= 0 {
+		log.Println("IMAGE_GC_INTERVAL environment variable is missing/invalid, fallback to 1h")
+		gcInterval = time.Hour
+	}
+
+	gcGracePeriod, err := time.ParseDuration(os.Getenv("IMAGE_GC_GRACE_PERIOD"))
+	if err != nil || gcGracePeriod < 0 {
+		log.Println("IMAGE_GC_GRACE_PERIOD environment variable is missing/invalid, fallback to 24h")
+		gcGracePeriod = 24 * time.Hour
+	}
+
+	imageURLSecret := os.Getenv("IMAGE_URL_SECRET")
+	if imageURLSecret == "" {
+		log.Println("IMAGE_URL_SECRET environment variable is missing, fallback to JWT_SECRET")
+		imageURLSecret = os.Getenv("JWT_SECRET")
+	}
+
+	// secret kosong berarti siapapun bisa membuat signed url untuk image private
+	if imageURLSecret == "" {
+		log.Fatal("IMAGE_URL_SECRET (or JWT_SECRET) key not found!")
+	}
+
+	signedURLTTL, err := time.ParseDuration(os.Getenv("SIGNED_URL_TTL"))
+	if err != nil || signedURLTTL <= 0 {
+		log.Println("SIGNED_URL_TTL environment variable is missing/invalid, fallback to 1h")
+		signedURLTTL = time.Hour
+	}
+
 	return AppConfig{
 		Host:         host,
 		Port:         port,
 		InternalPort: internalPort,
+		GrpcPort:     grpcPort,
+		BlobStore:    blobStore,
+		DataDir:      dataDir,
+		S3: S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    s3Region,
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		},
+		ThumbnailSizes:  thumbnailSizes,
+		ResizeAllowlist: resizeAllowlist,
+		MaxUploadSize:   maxUploadSize,
+
+		RabbitMQHostname:    rabbitMQHostname,
+		UserServiceHostname: userServiceHostname,
+		GCInterval:          gcInterval,
+		GCGracePeriod:       gcGracePeriod,
+
+		ImageURLSecret: imageURLSecret,
+		SignedURLTTL:   signedURLTTL,
+	}
+
+}
+
+// parseSizes --> parse "64,256,512" jadi []int, nilai yang tidak valid dilewati
+func parseSizes(sizes string) []int {
+	result := []int{}
+
+	for _, size := range strings.Split(sizes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil || n <= 0 || n > 4096 {
+			continue
+		}
+		result = append(result, n)
 	}
 
+	return result
 }
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const USER_SERVICE_EXCHANGE = "userServiceExchange"
+const POST_SERVICE_EXCHANGE = "postServiceExchange"
+
+// IMAGE_REF_RETRY_DELAY --> jeda sebelum event yang gagal disimpan dikembalikan ke queue,
+// supaya database yang sedang down tidak langsung dibanjiri event yang sama
+const IMAGE_REF_RETRY_DELAY = 2 * time.Second
+
+// IMAGE_REF_PREFETCH --> jumlah event yang belum di ack yang boleh dipegang consumer
+const IMAGE_REF_PREFETCH = 10
+
+// errInvalidImageRefEvent --> event yang tidak bisa diproses walaupun dicoba lagi, di drop
+var errInvalidImageRefEvent = errors.New("invalid image ref event")
+
+// postImageEvent --> bagian dari event post.created, post.reply.created dan post.deleted yang dipakai imageService
+type postImageEvent struct {
+	Id    string `json:"id"`
+	Image string `json:"image"`
+}
+
+// userDetailChangeEvent --> event user.detail.change, oldProfile diisi profile sebelum diubah
+type userDetailChangeEvent struct {
+	Id         string `json:"id"`
+	Profile    string `json:"profile"`
+	OldProfile string `json:"oldProfile"`
+}
+
+// userDeletedEvent --> event user.deleted, profile user yang dihapus tidak dipakai lagi
+type userDeletedEvent struct {
+	Id      string `json:"id"`
+	Profile string `json:"profile"`
+}
+
+// ImageRefConsumer --> hitung berapa post/profile yang memakai setiap image dari event post dan user
+type ImageRefConsumer struct {
+	Conn    *amqp.Connection
+	Storage *ImageStorage
+}
+
+func NewImageRefConsumer(conn *amqp.Connection, storage *ImageStorage) *ImageRefConsumer {
+	return &ImageRefConsumer{
+		Conn:    conn,
+		Storage: storage,
+	}
+}
+
+func (c *ImageRefConsumer) Consume() {
+	var wg sync.WaitGroup
+
+	ch, err := c.Conn.Channel()
+	if err != nil {
+		log.Println("Error when creating channel:", err)
+		return
+	}
+
+	defer ch.Close()
+
+	for _, exchange := range []string{USER_SERVICE_EXCHANGE, POST_SERVICE_EXCHANGE} {
+		err = ch.ExchangeDeclare(
+			exchange,
+			"topic",
+			true,
+			false,
+			false,
+			false,
+			nil,
+		)
+		if err != nil {
+			log.Println("Error when declaring exchange:", err)
+		}
+	}
+
+	q, err := ch.QueueDeclare(
+		"imageService_queue",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		log.Println("Error when declaring queue:", err)
+	}
+
+	bindings := []struct {
+		routingKey string
+		exchange   string
+	}{
+		{"post.created", POST_SERVICE_EXCHANGE},
+		{"post.reply.created", POST_SERVICE_EXCHANGE},
+		{"post.deleted", POST_SERVICE_EXCHANGE},
+		{"user.detail.change", USER_SERVICE_EXCHANGE},
+		{"user.deleted", USER_SERVICE_EXCHANGE},
+	}
+
+	for _, b := range bindings {
+		err = ch.QueueBind(
+			q.Name,
+			b.routingKey,
+			b.exchange,
+			false,
+			nil,
+		)
+		if err != nil {
+			log.Println("Error when binding queue:", err)
+		}
+	}
+
+	if err := ch.Qos(IMAGE_REF_PREFETCH, 0, false); err != nil {
+		log.Println("Error when setting qos:", err)
+	}
+
+	// manual ack --> event baru di ack setelah refCount tersimpan, kalau gagal dikembalikan ke queue
+	// supaya sweeper tidak menghapus image yang masih dipakai
+	msgs, err := ch.Consume(
+		q.Name,
+		"",
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		log.Println("Error when consuming queue:", err)
+	}
+
+	wg.Add(1)
+	go func() {
+		for d := range msgs {
+			log.Println("New event receive:", d.RoutingKey, string(d.Body))
+
+			err := c.handleEvent(d.RoutingKey, d.Body)
+			switch {
+			case err == nil:
+				if err := d.Ack(false); err != nil {
+					log.Println("Error when acking event:", err)
+				}
+			case errors.Is(err, errInvalidImageRefEvent):
+				log.Println("Dropping image ref event:", err)
+				if err := d.Nack(false, false); err != nil {
+					log.Println("Error when nacking event:", err)
+				}
+			default:
+				log.Println("Requeue image ref event:", err)
+				time.Sleep(IMAGE_REF_RETRY_DELAY)
+				if err := d.Nack(false, true); err != nil {
+					log.Println("Error when nacking event:", err)
+				}
+			}
+		}
+		defer wg.Done()
+	}()
+	wg.Wait()
+}
+
+// handleEvent --> error selain errInvalidImageRefEvent berarti event nya perlu dicoba lagi
+func (c *ImageRefConsumer) handleEvent(routingKey string, data []byte) error {
+	switch routingKey {
+	case "post.created", "post.reply.created":
+		event := &postImageEvent{}
+		if err := json.Unmarshal(data, event); err != nil {
+			return errors.Join(errInvalidImageRefEvent, err)
+		}
+
+		return c.Storage.store.ChangeImageRefs(event.Image, "")
+	case "post.deleted":
+		event := &postImageEvent{}
+		if err := json.Unmarshal(data, event); err != nil {
+			return errors.Join(errInvalidImageRefEvent, err)
+		}
+
+		return c.Storage.store.ChangeImageRefs("", event.Image)
+	case "user.detail.change":
+		event := &userDetailChangeEvent{}
+		if err := json.Unmarshal(data, event); err != nil {
+			return errors.Join(errInvalidImageRefEvent, err)
+		}
+
+		if event.Profile == event.OldProfile {
+			return nil
+		}
+
+		// increment dan decrement dalam satu transaksi, event yang di requeue tidak menghitung dua kali
+		return c.Storage.store.ChangeImageRefs(event.Profile, event.OldProfile)
+	case "user.deleted":
+		event := &userDeletedEvent{}
+		if err := json.Unmarshal(data, event); err != nil {
+			return errors.Join(errInvalidImageRefEvent, err)
+		}
+
+		return c.Storage.store.ChangeImageRefs("", event.Profile)
+	}
+
+	return nil
+}
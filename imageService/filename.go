@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+)
+
+var ErrInvalidFilename = errors.New("invalid filename")
+
+// nama file selalu dibuat server:
+//
+// <sha256 hex>.<ext>           --> sejak deduplikasi content-addressed
+// <unix>-<uuid>.<ext>          --> upload sebelum deduplikasi dan image private
+//
+// upload lama memakai ekstensi dari nama file client, jadi ".jpeg" juga masih diterima
+var generatedFilenamePattern = regexp.MustCompile(`^(?:[0-9a-f]{64}|[0-9]{1,19}-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\.(?:jpg|jpeg|png|gif|webp)$`)
+
+// validateFilename --> tolak nama file yang bukan buatan server, supaya nama seperti
+// "../x", "/etc/passwd" atau "a\b" tidak pernah sampai ke BlobStore
+func validateFilename(filename string) error {
+	if !generatedFilenamePattern.MatchString(filename) {
+		return ErrInvalidFilename
+	}
+
+	return nil
+}
+
+// imageFilename --> ambil dan validasi {filename} dari route
+func imageFilename(r *http.Request) (string, error) {
+	filename := mux.Vars(r)["filename"]
+
+	if err := validateFilename(filename); err != nil {
+		return "", err
+	}
+
+	return filename, nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pewe21/library"
+)
+
+const testHashFilename = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08.jpg"
+
+func TestValidateFilename(t *testing.T) {
+	valid := []string{
+		testHashFilename,
+		"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08.png",
+		"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08.gif",
+		"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08.webp",
+		"1718000000-3b241101-e2bb-4255-8caf-4136c566a962.jpg",
+		"1718000000-3b241101-e2bb-4255-8caf-4136c566a962.jpeg",
+	}
+
+	for _, filename := range valid {
+		if err := validateFilename(filename); err != nil {
+			t.Errorf("validateFilename(%q) = %v, want nil", filename, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		".",
+		"..",
+		"../secret.jpg",
+		"../../etc/passwd",
+		"original/../../secret.jpg",
+		"/etc/passwd",
+		"/" + testHashFilename,
+		"C:\\Windows\\win.ini",
+		"..\\..\\secret.jpg",
+		"..%2F..%2Fetc%2Fpasswd",
+		"%2e%2e%2fsecret.jpg",
+		"..%5csecret.jpg",
+		testHashFilename + "/..",
+		testHashFilename + "\x00.jpg",
+		"9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08.jpg",
+		"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08.jpg.php",
+		"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08.svg",
+		"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a0.jpg",
+		"1718000000-not-a-uuid.jpg",
+		"avatar.jpg",
+	}
+
+	for _, filename := range invalid {
+		if err := validateFilename(filename); !errors.Is(err, ErrInvalidFilename) {
+			t.Errorf("validateFilename(%q) = %v, want ErrInvalidFilename", filename, err)
+		}
+	}
+}
+
+func TestLocalBlobStoreRejectsKeysOutsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "data")
+	secret := filepath.Join(dir, "secret.jpg")
+
+	if err := os.WriteFile(secret, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewLocalBlobStore(root)
+	ctx := context.Background()
+
+	keys := []string{
+		"",
+		"../secret.jpg",
+		"original/../../secret.jpg",
+		"original/../../../secret.jpg",
+		secret,
+		"/secret.jpg",
+		"..\\secret.jpg",
+		"original\\..\\..\\secret.jpg",
+	}
+
+	for _, key := range keys {
+		if err := store.Put(ctx, key, []byte("overwritten"), "image/jpeg"); !errors.Is(err, ErrInvalidBlobKey) {
+			t.Errorf("Put(%q) = %v, want ErrInvalidBlobKey", key, err)
+		}
+
+		if file, _, err := store.Get(ctx, key); !errors.Is(err, ErrInvalidBlobKey) {
+			if file != nil {
+				file.Close()
+			}
+			t.Errorf("Get(%q) = %v, want ErrInvalidBlobKey", key, err)
+		}
+
+		if err := store.Delete(ctx, key); !errors.Is(err, ErrInvalidBlobKey) {
+			t.Errorf("Delete(%q) = %v, want ErrInvalidBlobKey", key, err)
+		}
+	}
+
+	data, err := os.ReadFile(secret)
+	if err != nil {
+		t.Fatalf("secret file removed: %v", err)
+	}
+
+	if string(data) != "secret" {
+		t.Fatalf("secret file overwritten: %q", data)
+	}
+}
+
+func TestLocalBlobStoreStaysInsideRoot(t *testing.T) {
+	root := t.TempDir()
+	store := NewLocalBlobStore(root)
+	ctx := context.Background()
+	key := "original/" + testHashFilename
+
+	if err := store.Put(ctx, key, []byte("image"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "original", testHashFilename)); err != nil {
+		t.Fatalf("blob not written under root: %v", err)
+	}
+
+	file, _, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "image" {
+		t.Fatalf("Get(%q) = %q, want %q", key, data, "image")
+	}
+}
+
+// forbiddenBlobStore --> gagal kalau request dengan nama file tidak valid sampai ke BlobStore
+type forbiddenBlobStore struct {
+	t *testing.T
+}
+
+func (s forbiddenBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	s.t.Errorf("unexpected Put(%q)", key)
+	return ErrInvalidBlobKey
+}
+
+func (s forbiddenBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	s.t.Errorf("unexpected Get(%q)", key)
+	return nil, nil, ErrInvalidBlobKey
+}
+
+func (s forbiddenBlobStore) Delete(ctx context.Context, key string) error {
+	s.t.Errorf("unexpected Delete(%q)", key)
+	return ErrInvalidBlobKey
+}
+
+func TestImageRoutesRejectUnsafeFilenames(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_LEGACY_HS256", "true")
+
+	token, err := library.CreateJWT(&library.Principal{UserId: "user-1"}, "test-secret", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := NewImageStorage(AppConfig{}, forbiddenBlobStore{t: t}, nil)
+	server := NewAppServer(AppConfig{}, storage)
+
+	tests := []struct {
+		method string
+		target string
+		want   int
+	}{
+		// nama file yang bukan buatan server ditolak handler
+		{http.MethodGet, "/v1/image/thumbnail/..%5C..%5Csecret.jpg", http.StatusBadRequest},
+		{http.MethodGet, "/v1/image/original/%252e%252e%252fsecret.jpg", http.StatusBadRequest},
+		{http.MethodGet, "/v1/image/original/..secret.jpg", http.StatusBadRequest},
+		{http.MethodGet, "/v1/image/original/avatar.jpg", http.StatusBadRequest},
+		{http.MethodHead, "/v1/image/thumbnail/avatar.jpg", http.StatusBadRequest},
+		{http.MethodGet, "/v1/image/avatar.jpg?w=64", http.StatusBadRequest},
+		{http.MethodDelete, "/v1/image/avatar.jpg", http.StatusBadRequest},
+		{http.MethodGet, "/v1/image/avatar.jpg/url", http.StatusBadRequest},
+
+		// encoded separator di decode lalu di clean router dan di redirect, tidak pernah sampai handler
+		{http.MethodGet, "/v1/image/original/..%2F..%2Fetc%2Fpasswd", http.StatusMovedPermanently},
+		{http.MethodGet, "/v1/image/original/%2Fetc%2Fpasswd", http.StatusMovedPermanently},
+		{http.MethodGet, "/v1/image/thumbnail/..%2Fsecret.jpg", http.StatusMovedPermanently},
+		{http.MethodHead, "/v1/image/original/..%2Fsecret.jpg", http.StatusMovedPermanently},
+		{http.MethodGet, "/v1/image/..%2Fsecret.jpg?w=64", http.StatusMovedPermanently},
+		{http.MethodDelete, "/v1/image/..%2Fsecret.jpg", http.StatusMovedPermanently},
+
+		// path traversal biasa juga di clean router lalu di redirect
+		{http.MethodGet, "/v1/image/original/../../etc/passwd", http.StatusMovedPermanently},
+		{http.MethodGet, "/v1/image/original/..", http.StatusMovedPermanently},
+
+		// absolute path tidak match route manapun
+		{http.MethodGet, "/v1/image/original//etc/passwd", http.StatusMovedPermanently},
+		{http.MethodGet, "/v1/image/original/etc/passwd", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+
+		server.Server.Handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.target, rec.Code, tt.want)
+		}
+	}
+}
@@ -3,39 +3,37 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"image"
-	"image/jpeg"
 	"io"
 	"log"
 	"net"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
 
-	"github.com/google/uuid"
 	"github.com/pewe21/imageProto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type GrpcServer struct {
 	GrpcListenAddr string
 	Cfg            AppConfig
+	Storage        *ImageStorage
 	Server         *grpc.Server
 	NetListener    net.Listener
 	imageProto.UnimplementedUserServer
 }
 
-func NewGrpcServer(cfg AppConfig, grpclistenAddr string) *GrpcServer {
+func NewGrpcServer(cfg AppConfig, grpclistenAddr string, storage *ImageStorage) *GrpcServer {
 	listen, err := net.Listen("tcp", grpclistenAddr)
 	if err != nil {
-		log.Fatalf("Failed to start grpc userService server:%v", err)
+		log.Fatalf("Failed to start grpc imageService server:%v", err)
 	}
 
 	return &GrpcServer{
 		GrpcListenAddr: grpclistenAddr,
 		Cfg:            cfg,
+		Storage:        storage,
 		Server:         grpc.NewServer(),
 		NetListener:    listen,
 	}
@@ -53,95 +51,89 @@ func (s *GrpcServer) RunGrpc() {
 func (s *GrpcServer) CreateImage(ctx context.Context, req *imageProto.CreateImageReq) (*imageProto.ImageResp, error) {
 	log.Println("hit Create image grpc")
 
-	imageBytes := req.GetImageFile()
-	if len(imageBytes) == 0 {
-		return nil, fmt.Errorf("missing image from request")
-	}
-
-	fileExt := strings.ToLower(filepath.Ext(req.GetFileName()))
-	if fileExt != ".jpeg" && fileExt != ".jpg" {
-		return nil, fmt.Errorf("invalid file type: %s, only jpg/jpeg supported", fileExt)
-	}
-
-	originalDir := filepath.Join("data", "original")
-	thumbDir := filepath.Join("data", "thumbnail")
-
-	err := os.MkdirAll(originalDir, os.ModePerm)
+	image, err := s.Storage.SaveImage(ctx, req.GetIdUser(), req.GetPrivate(), req.GetImageFile())
 	if err != nil {
-		log.Println("Error when creating original image directory:", err)
-		return nil, fmt.Errorf("something went wrong")
-	}
+		log.Println("Error when saving image:", err)
+		if errors.Is(err, ErrInvalidImage) {
+			return nil, err
+		}
 
-	err = os.MkdirAll(thumbDir, os.ModePerm)
-	if err != nil {
-		log.Println("Error when creating thumbnail image directory:", err)
 		return nil, fmt.Errorf("something went wrong")
 	}
 
-	timestamp := time.Now().Unix()
-	uniqueId := uuid.NewString()
-	stamp := fmt.Sprintf("%d-%s%s", timestamp, uniqueId, fileExt)
+	return newImageResp(image), nil
+}
+
+// UploadImage --> client-streaming upload, message pertama header lalu chunk file.
+// Ukuran file dicek selama streaming supaya file yang terlalu besar langsung ditolak
+func (s *GrpcServer) UploadImage(stream imageProto.User_UploadImageServer) error {
+	log.Println("hit Upload image grpc")
 
-	// save original file
-	oriFilename := filepath.Join("data", "original", stamp)
-	oriFileData, err := os.Create(oriFilename)
+	req, err := stream.Recv()
 	if err != nil {
-		log.Println("Error when creating original file object:", err)
-		return nil, fmt.Errorf("something went wrong")
+		log.Println("Error when receiving upload header:", err)
+		return status.Error(codes.InvalidArgument, "missing upload header")
 	}
 
-	defer oriFileData.Close()
-
-	fileBuff := bytes.NewBuffer(imageBytes)
-
-	// copy thumbnail
-	_, err = io.Copy(oriFileData, fileBuff)
-	if err != nil {
-		log.Println("Error when duplicating file object:", err)
-		return nil, fmt.Errorf("something went wrong")
+	header := req.GetHeader()
+	if header == nil {
+		return status.Error(codes.InvalidArgument, "first message must be upload header")
 	}
 
-	thumbFilename := filepath.Join("data", "thumbnail", stamp)
-	thumbFileData, err := os.Create(thumbFilename)
-	if err != nil {
-		log.Println("Error when creating thumbnail file object")
-		return nil, fmt.Errorf("something went wrong")
+	if header.GetSize() > s.Cfg.MaxUploadSize {
+		return status.Errorf(codes.InvalidArgument, "image too large, max %d bytes", s.Cfg.MaxUploadSize)
 	}
 
-	defer thumbFileData.Close()
+	buf := bytes.NewBuffer(make([]byte, 0, max(header.GetSize(), 0)))
 
-	oriFileData.Seek(0, 0)
-	img, _, err := image.Decode(oriFileData)
-	if err != nil {
-		log.Println("Error when decoding original image file:", err)
-		return nil, fmt.Errorf("something went wrong")
-	}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
 
-	// cek apakah imagenya kecil dari ukuran thumbnail, jika tidak kita resize ke ukuran thumbnail
-	if img.Bounds().Dx() > 512 {
-		thumb := resize(img, 512)
-		err = jpeg.Encode(thumbFileData, thumb, nil)
 		if err != nil {
-			log.Println("Error when scaling image:", err)
-			return nil, fmt.Errorf("invalid image/image is not supported")
+			log.Println("Error when receiving upload chunk:", err)
+			return err
+		}
+
+		chunk := req.GetChunk()
+		if int64(buf.Len()+len(chunk)) > s.Cfg.MaxUploadSize {
+			return status.Errorf(codes.InvalidArgument, "image too large, max %d bytes", s.Cfg.MaxUploadSize)
 		}
+
+		buf.Write(chunk)
 	}
 
-	_, err = oriFileData.Seek(0, 0)
-	if err != nil {
-		log.Println("Error when seeking original image file:", err)
-		return nil, fmt.Errorf("something went wrong")
+	if header.GetSize() > 0 && int64(buf.Len()) != header.GetSize() {
+		return status.Errorf(codes.InvalidArgument, "image size mismatch, declared %d bytes but received %d bytes", header.GetSize(), buf.Len())
 	}
 
-	_, err = io.Copy(thumbFileData, oriFileData)
+	image, err := s.Storage.SaveImage(stream.Context(), header.GetIdUser(), header.GetPrivate(), buf.Bytes())
 	if err != nil {
-		log.Println("Error when duplicating thumbnail file object:", err)
-		return nil, fmt.Errorf("something went wrong")
-	}
+		log.Println("Error when saving image:", err)
+		if errors.Is(err, ErrInvalidImage) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
 
-	resp := &imageProto.ImageResp{
-		Filename: stamp,
+		return status.Error(codes.Internal, "something went wrong")
 	}
 
-	return resp, nil
+	return stream.SendAndClose(newImageResp(image))
+}
+
+func newImageResp(image *AppImage) *imageProto.ImageResp {
+	return &imageProto.ImageResp{
+		Filename: image.Filename,
+		MimeType: image.MimeType,
+		Width:    int32(image.Width),
+		Height:   int32(image.Height),
+		Size:     image.Size,
+
+		Private:      image.Private,
+		OriginalUrl:  image.OriginalUrl,
+		ThumbnailUrl: image.ThumbnailUrl,
+		ResizeUrl:    image.ResizeUrl,
+		UrlExpiresAt: image.UrlExpiresAt,
+	}
 }
@@ -1,35 +1,32 @@
 package main
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
 	"fmt"
-	"image"
-	"image/jpeg"
 	"io"
 	"log"
-	"math"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
+	"strconv"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/pewe21/library"
-	"golang.org/x/image/draw"
 )
 
 type ImageService struct {
 	host         string
 	port         string
 	internalPort string
+	storage      *ImageStorage
 }
 
-func NewImageService(cfg AppConfig) *ImageService {
+func NewImageService(cfg AppConfig, storage *ImageStorage) *ImageService {
 	return &ImageService{
 		host:         cfg.Host,
 		port:         cfg.Port,
 		internalPort: cfg.InternalPort,
+		storage:      storage,
 	}
 }
 
@@ -38,46 +35,81 @@ func (s *ImageService) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/", library.CreateHandler(library.JWTMiddleware(s.handleCreateImage))).Methods(http.MethodPost)
 
 	// v1/image/original/{filename}
-	r.HandleFunc("/original/{filename}", library.CreateHandler(s.handleGetOriImage)).Methods(http.MethodGet)
+	r.HandleFunc("/original/{filename}", library.CreateHandler(s.handleGetOriImage)).Methods(http.MethodGet, http.MethodHead)
 
 	// v1/image/thumbnail/{filename}
-	r.HandleFunc("/thumbnail/{filename}", library.CreateHandler(s.handleGetThumbnailImage)).Methods(http.MethodGet)
+	r.HandleFunc("/thumbnail/{filename}", library.CreateHandler(s.handleGetThumbnailImage)).Methods(http.MethodGet, http.MethodHead)
+
+	// v1/image/{filename}/url --> signed url baru untuk image private
+	r.HandleFunc("/{filename}/url", library.CreateHandler(library.JWTMiddleware(s.handleSignImageURL))).Methods(http.MethodGet)
+
+	// v1/image/{filename}?w=&h=&fit=cover|contain
+	r.HandleFunc("/{filename}", library.CreateHandler(s.handleGetResizedImage)).Methods(http.MethodGet, http.MethodHead)
+
+	// v1/image/{filename}
+	r.HandleFunc("/{filename}", library.CreateHandler(library.JWTMiddleware(s.handleDeleteImage))).Methods(http.MethodDelete)
 }
 
 func (s *ImageService) handleGetOriImage(w http.ResponseWriter, r *http.Request) (int, error) {
-	vars := mux.Vars(r)
-	fileName := vars["filename"]
-	file, err := os.Open(filepath.Join("data", "original", fileName))
+	return s.getImage(w, r, "original")
+}
+
+func (s *ImageService) handleGetThumbnailImage(w http.ResponseWriter, r *http.Request) (int, error) {
+	return s.getImage(w, r, "thumbnail")
+}
+
+func (s *ImageService) handleGetResizedImage(w http.ResponseWriter, r *http.Request) (int, error) {
+	filename, err := imageFilename(r)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return http.StatusBadRequest, err
 	}
-	defer file.Close()
-	//set header
-	w.Header().Set("Content-Type", "image/jpeg")
-	_, err = io.Copy(w, file)
+
+	urlQuery := r.URL.Query()
+	width, err := parseDimension(urlQuery.Get("w"))
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return http.StatusBadRequest, fmt.Errorf("invalid width")
 	}
 
-	return http.StatusOK, nil
-}
+	height, err := parseDimension(urlQuery.Get("h"))
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid height")
+	}
 
-func (s *ImageService) handleGetThumbnailImage(w http.ResponseWriter, r *http.Request) (int, error) {
-	vars := mux.Vars(r)
-	fileName := vars["filename"]
-	file, err := os.Open(filepath.Join("data", "thumbnail", fileName))
+	image, status, err := s.authorizeImage(r, filename)
 	if err != nil {
-		return http.StatusNotFound, err
+		return status, err
 	}
-	defer file.Close()
-	//set header
-	w.Header().Set("Content-Type", "image/jpeg")
-	_, err = io.Copy(w, file)
+
+	file, info, err := s.storage.OpenResizedImage(r.Context(), filename, width, height, urlQuery.Get("fit"))
 	if err != nil {
-		return http.StatusInternalServerError, err
+		if errors.Is(err, ErrBlobNotFound) {
+			return http.StatusNotFound, fmt.Errorf("image not found")
+		}
+
+		if errors.Is(err, ErrInvalidImage) {
+			return http.StatusBadRequest, err
+		}
+
+		log.Println("Error when opening resized image:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
 	}
 
-	return http.StatusOK, nil
+	defer file.Close()
+
+	return writeImage(w, r, file, info, image)
+}
+
+func parseDimension(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid dimension: %s", value)
+	}
+
+	return n, nil
 }
 
 func (s *ImageService) handleCreateImage(w http.ResponseWriter, r *http.Request) (int, error) {
@@ -90,7 +122,7 @@ func (s *ImageService) handleCreateImage(w http.ResponseWriter, r *http.Request)
 	}
 
 	//baca dengan io.reader
-	file, handler, err := r.FormFile("reqImage")
+	file, _, err := r.FormFile("reqImage")
 	if err != nil {
 		log.Println("Error when creating file handler:", err)
 		return http.StatusBadRequest, fmt.Errorf("invalid/missing image")
@@ -98,136 +130,224 @@ func (s *ImageService) handleCreateImage(w http.ResponseWriter, r *http.Request)
 
 	defer file.Close()
 
-	fileExt := strings.ToLower(filepath.Ext(handler.Filename))
-	if fileExt != ".jpeg" && fileExt != ".jpg" {
-		return http.StatusBadRequest, fmt.Errorf("invalid file type: %s, only jpg/jpeg supported", fileExt)
+	imageBytes, err := io.ReadAll(file)
+	if err != nil {
+		log.Println("Error when reading file from form data:", err)
+		return http.StatusBadRequest, fmt.Errorf("invalid/missing image")
 	}
 
-	originalDir := filepath.Join("data", "original")
-	thumbDir := filepath.Join("data", "thumbnail")
+	// image private hanya bisa dibuka dengan signed url dari response
+	private := r.FormValue("private") == "true"
 
-	err = os.MkdirAll(originalDir, os.ModePerm)
-	if err != nil {
-		log.Println("Error when creating original image directory:", err)
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
 	}
 
-	err = os.MkdirAll(thumbDir, os.ModePerm)
+	// nama file dibuat server, format image dideteksi dari isi file
+	data, err := s.storage.SaveImage(r.Context(), principal.UserId, private, imageBytes)
 	if err != nil {
-		log.Println("Error when creating thumbnail image directory:", err)
+		log.Println("Error when saving image:", err)
+		if errors.Is(err, ErrInvalidImage) {
+			return http.StatusBadRequest, err
+		}
+
 		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
 	}
 
-	timestamp := time.Now().Unix()
-	uniqueId := uuid.NewString()
-	stamp := fmt.Sprintf("%d-%s%s", timestamp, uniqueId, fileExt)
-
-	log.Println("stamp:", stamp)
+	resp := library.NewResp("Image created", data)
 
-	// save original file
-	oriFilename := filepath.Join("data", "original", handler.Filename)
-	log.Println("oriFilename", oriFilename)
-	oriFileData, err := os.Create(oriFilename)
-	if err != nil {
-		log.Println("Error when creating original file object:", err)
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	if err = library.WriteJson(w, http.StatusCreated, resp); err != nil {
+		return http.StatusInternalServerError, err
 	}
 
-	defer oriFileData.Close()
+	return http.StatusCreated, nil
+}
 
-	// copy thumbnail
-	_, err = io.Copy(oriFileData, file)
+// handleSignImageURL --> owner minta signed url baru setelah url lama expired
+func (s *ImageService) handleSignImageURL(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle sign image url")
+
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userId := principal.UserId
+	filename, err := imageFilename(r)
 	if err != nil {
-		log.Println("Error when duplicating file object:", err)
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		return http.StatusBadRequest, err
 	}
 
-	//copy thumbnail
-	thumbFilename := filepath.Join("data", "thumbnail", handler.Filename)
-	thumbFileData, err := os.Create(thumbFilename)
+	image, status, err := s.getOwnedImage(filename, userId)
 	if err != nil {
-		log.Println("Error when creating thumbnail file object")
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		return status, err
+	}
+
+	if image.Private {
+		s.storage.SignImageURLs(image)
 	}
 
-	defer thumbFileData.Close()
+	resp := library.NewResp("success", image)
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
 
-	oriFileData.Seek(0, 0)
-	img, _, err := image.Decode(oriFileData)
+// authorizeImage --> image private ditolak dengan 403 kalau signature tidak ada, salah atau expired,
+// image yang sudah dihapus 404. Return record image, nil untuk image lama tanpa record
+func (s *ImageService) authorizeImage(r *http.Request, filename string) (*AppImage, int, error) {
+	image, err := s.storage.AuthorizeRequest(r, filename)
+	if err == nil {
+		return image, http.StatusOK, nil
+	}
+
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil, http.StatusNotFound, fmt.Errorf("image not found")
+	}
+
+	if errors.Is(err, library.ErrMissingSignature) || errors.Is(err, library.ErrInvalidSignature) || errors.Is(err, library.ErrExpiredSignature) {
+		return nil, http.StatusForbidden, err
+	}
+
+	log.Println("Error when authorizing image request:", err)
+	return nil, http.StatusInternalServerError, fmt.Errorf("something went wrong")
+}
+
+// handleDeleteImage --> owner hapus image nya. Blob yang sama bisa dimiliki user lain atau
+// masih dipakai post/profile, blob baru dihapus kalau sudah tidak dipakai dimanapun
+func (s *ImageService) handleDeleteImage(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle delete image")
+
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userId := principal.UserId
+	filename, err := imageFilename(r)
 	if err != nil {
-		// log.Println("extensions", fileExt)
-		log.Println("Error when decoding original image file:", err)
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		return http.StatusBadRequest, err
 	}
-	//cek apakah ukuran lebih besar dari thumbnail, jika tidak resize ke ukuran thumbnail
-	if img.Bounds().Dx() > 512 {
-		thumb := resize(img, 512)
-		if err = jpeg.Encode(thumbFileData, thumb, nil); err != nil {
-			log.Println("Error when scaling image:", err)
-			return http.StatusInternalServerError, fmt.Errorf("Invalid image/image is not supported")
+
+	if _, status, err := s.getOwnedImage(filename, userId); err != nil {
+		return status, err
+	}
+
+	if err := s.storage.RemoveImageOwner(r.Context(), filename, userId); err != nil {
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, fmt.Errorf("image not found")
 		}
-	} // jika error masuk ke else
 
-	_, err = oriFileData.Seek(0, 0)
-	if err != nil {
-		log.Println("Error when seeking original image file:", err)
+		log.Println("Error when deleting image:", err)
 		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
 	}
 
-	//save thumbnail
-	_, err = io.Copy(thumbFileData, oriFileData)
-	if err != nil {
-		log.Println("Error when duplicating thumbnail file object:", err)
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	resp := library.NewResp("Image deleted successfully", nil)
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+// getOwnedImage --> record image yang diupload userId, 404 kalau tidak ada/sudah dihapus, 403 kalau bukan owner
+func (s *ImageService) getOwnedImage(filename, userId string) (*AppImage, int, error) {
+	image := &AppImage{}
+
+	if err := s.storage.GetImage(filename, image); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusNotFound, fmt.Errorf("image not found")
+		}
+
+		log.Println("Error when getting image:", err)
+		return nil, http.StatusInternalServerError, fmt.Errorf("something went wrong")
 	}
 
-	data := AppImage{
-		Filename: handler.Filename,
+	if image.State == IMAGE_STATE_DELETING || image.State == IMAGE_STATE_DELETED {
+		return nil, http.StatusNotFound, fmt.Errorf("image not found")
 	}
 
-	resp := library.NewResp("Image created", data)
+	isOwner, err := s.storage.IsImageOwner(filename, userId)
+	if err != nil {
+		log.Println("Error when checking image owner:", err)
+		return nil, http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
 
-	if err = library.WriteJson(w, http.StatusCreated, resp); err != nil {
-		return http.StatusInternalServerError, err
+	if !isOwner {
+		log.Println("userid from jwt is not owner of image")
+		return nil, http.StatusForbidden, fmt.Errorf("Forbidden")
 	}
 
-	return http.StatusCreated, nil
+	image.IdUser = userId
+
+	return image, http.StatusOK, nil
 }
 
 func (s *ImageService) getImage(w http.ResponseWriter, r *http.Request, imageType string) (int, error) {
-	vars := mux.Vars(r)
-	filename := vars["filename"]
-	log.Println(filename)
+	filename, err := imageFilename(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	image, status, err := s.authorizeImage(r, filename)
+	if err != nil {
+		return status, err
+	}
 
 	// open filename
-	originalFilePath := filepath.Join("data", imageType, filename)
-	oriFile, err := os.Open(originalFilePath)
+	file, info, err := s.storage.OpenImage(r.Context(), imageType, filename)
 	if err != nil {
-		return http.StatusNotFound, err
+		if errors.Is(err, ErrBlobNotFound) {
+			return http.StatusNotFound, fmt.Errorf("image not found")
+		}
+
+		log.Println("Error when opening image:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
 	}
 
-	defer oriFile.Close()
+	defer file.Close()
+
+	return writeImage(w, r, file, info, image)
+}
+
+// cache header untuk image public, nama file selalu baru setiap upload jadi isinya tidak pernah berubah
+const IMAGE_CACHE_CONTROL = "public, max-age=31536000, immutable"
+
+// signed url bisa expired, browser harus revalidasi (ETag) setiap kali dipakai
+const PRIVATE_IMAGE_CACHE_CONTROL = "private, no-cache"
 
+// writeImage --> kirim image dengan http.ServeContent, support ETag/If-None-Match,
+// Last-Modified/If-Modified-Since, Range dan HEAD. image nil untuk image lama tanpa record
+func writeImage(w http.ResponseWriter, r *http.Request, file io.Reader, info *BlobInfo, image *AppImage) (int, error) {
 	// set header
-	w.Header().Set("Content-Type", "image/jpeg")
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "image/jpeg"
+	}
+	w.Header().Set("Content-Type", contentType)
 
-	// return imagenya
-	_, err = io.Copy(w, oriFile)
-	if err != nil {
-		return http.StatusInternalServerError, err
+	if info.ETag != "" {
+		w.Header().Set("ETag", info.ETag)
 	}
 
-	return http.StatusOK, nil
-}
+	if image != nil && image.Private {
+		w.Header().Set("Cache-Control", PRIVATE_IMAGE_CACHE_CONTROL)
+	} else {
+		w.Header().Set("Cache-Control", IMAGE_CACHE_CONTROL)
+	}
 
-func resize(img image.Image, width int) image.Image {
-	originWidth := img.Bounds().Dx()
-	originHeight := img.Bounds().Dy()
-	ration := float64(originHeight) / float64(originWidth)
-	newHeight := int(math.Floor(float64(width) * ration))
-	rect := image.Rect(0, 0, width, newHeight)
-	resized := image.NewRGBA(rect)
-	draw.NearestNeighbor.Scale(resized, rect, img, img.Bounds(), draw.Over, nil)
+	// ServeContent butuh io.ReadSeeker untuk Range, blob dari s3/memory dibaca ke memory dulu
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			log.Println("Error when reading image:", err)
+			return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		}
+		content = bytes.NewReader(data)
+	}
 
-	return resized
+	// return imagenya
+	http.ServeContent(w, r, "", info.ModTime, content)
+
+	return http.StatusOK, nil
 }
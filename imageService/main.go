@@ -1,9 +1,44 @@
 package main
 
-import "sync"
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/pewe21/library"
+	"github.com/pewe21/userProto"
+	"google.golang.org/grpc/resolver"
+)
+
+// rabbitmq port
+const RABBITMQ_PORT = ":5672"
+
+const GRPC_USER_SERVICE_PORT = ":4002"
+const USER_SCHEME = "user"
+const USER_SERVICE_NAME = "user-service"
+
+// AppImage --> record image di tabel images
 type AppImage struct {
-	Filename string `json:"filename"`
+	Filename  string `json:"filename"`
+	IdUser    string `json:"idUser"`
+	MimeType  string `json:"mimeType"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Size      int64  `json:"size"`
+	RefCount  int    `json:"refCount"`
+	State     string `json:"state"`
+	Private   bool   `json:"private"`
+	CreatedAt int64  `json:"createdAt"`
+
+	// signed url, hanya diisi untuk image private
+	OriginalUrl  string `json:"originalUrl,omitempty"`
+	ThumbnailUrl string `json:"thumbnailUrl,omitempty"`
+	ResizeUrl    string `json:"resizeUrl,omitempty"`
+	UrlExpiresAt int64  `json:"urlExpiresAt,omitempty"`
 }
 
 func main() {
@@ -12,7 +47,36 @@ func main() {
 
 	cfg := InitConfig()
 
-	httpServer := NewAppServer(cfg)
+	postgresStorage := NewPostgresStorage()
+	postgresStorage.Init()
+
+	// set db conn limit
+	postgresStorage.db.SetMaxOpenConns(25)
+	postgresStorage.db.SetMaxIdleConns(25)
+	postgresStorage.db.SetConnMaxLifetime(5 * time.Minute)
+
+	blobs, err := NewBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("Cannot create blob store: %v", err)
+	}
+
+	// dial grpc user service
+	resolver.Register(&UserServiceResolverBuilder{UserServiceHostname: cfg.UserServiceHostname})
+	userServiceGrpcConn, err := generateUserServiceGrpcConn(cfg.UserServiceHostname)
+	if err != nil {
+		log.Fatalf("Cannot connect to user Grpc server: %v", err)
+	}
+
+	// JWTMiddleware tolak token yang dibuat sebelum tokensValidAfter user
+	userGrpcClient := userProto.NewUserClient(userServiceGrpcConn)
+	library.SetTokensValidAfterSource(library.NewTokensValidAfterCache(userServiceTokensValidAfter(userGrpcClient), library.TOKENS_VALID_AFTER_CACHE_TTL))
+
+	// storage yang sama dipakai http dan grpc
+	storage := NewImageStorage(cfg, blobs, postgresStorage)
+
+	httpServer := NewAppServer(cfg, storage)
+
+	grpcServer := NewGrpcServer(cfg, cfg.GrpcPort, storage)
 
 	wg.Add(1)
 	go func() {
@@ -20,5 +84,53 @@ func main() {
 		httpServer.Run()
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		grpcServer.RunGrpc()
+	}()
+
+	// consumer event post/user untuk menghitung referensi image
+	rabbitMq := NewRabbitMQ(cfg, storage)
+	go rabbitMq.Run()
+
+	// hapus image yang tidak dipakai
+	sweeperCtx, sweeperCancel := context.WithCancel(context.Background())
+	sweeper := NewImageSweeper(storage, cfg.GCInterval, cfg.GCGracePeriod)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sweeper.Run(sweeperCtx)
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	<-sigs
+	log.Println("SIGTERM detected, will attempt to graceful shutdown...")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+
+	// shutdown http.server
+	if err := httpServer.Server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Error when trying to shutdown http server:", err)
+	} else {
+		log.Println("http server closed")
+	}
+
+	// shutdown grpc server
+	grpcServer.Server.GracefulStop()
+	log.Println("GRPC server closed")
+
+	// stop sweeper & rabbitmq
+	sweeperCancel()
+	rabbitMq.Close()
+
+	if err := userServiceGrpcConn.Close(); err != nil {
+		log.Println("Error when closing user grpc connection:", err)
+	}
+
 	wg.Wait()
 }
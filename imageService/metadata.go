@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+)
+
+// orientation EXIF (tag 0x0112), 1 berarti image sudah tegak
+// https://www.exif.org/Exif2-2.PDF halaman 18
+const (
+	ORIENTATION_NORMAL        = 1
+	ORIENTATION_FLIP_H        = 2
+	ORIENTATION_ROTATE_180    = 3
+	ORIENTATION_FLIP_V        = 4
+	ORIENTATION_TRANSPOSE     = 5
+	ORIENTATION_ROTATE_90_CW  = 6
+	ORIENTATION_TRANSVERSE    = 7
+	ORIENTATION_ROTATE_270_CW = 8
+)
+
+const (
+	exifOrientationTag   = 0x0112
+	exifHeader           = "Exif\x00\x00"
+	jpegICCProfileHeader = "ICC_PROFILE\x00"
+
+	// flag di byte pertama chunk VP8X webp
+	webpFlagExif byte = 0x08
+	webpFlagXmp  byte = 0x04
+)
+
+// readOrientation --> ambil orientation dari EXIF jpeg (APP1), png (eXIf) atau webp (EXIF chunk).
+// Kalau tidak ada atau tidak valid return ORIENTATION_NORMAL
+func readOrientation(data []byte, contentType string) int {
+	var exif []byte
+
+	switch contentType {
+	case "image/jpeg":
+		walkJpegSegments(data, func(marker byte, payload []byte) bool {
+			if marker == 0xE1 && bytes.HasPrefix(payload, []byte(exifHeader)) {
+				exif = payload[len(exifHeader):]
+				return false
+			}
+			return true
+		})
+	case "image/png":
+		walkPngChunks(data, func(chunkType string, payload []byte) bool {
+			if chunkType == "eXIf" {
+				exif = payload
+				return false
+			}
+			return true
+		})
+	case "image/webp":
+		walkWebpChunks(data, func(fourcc string, payload []byte) bool {
+			if fourcc == "EXIF" {
+				exif = bytes.TrimPrefix(payload, []byte(exifHeader))
+				return false
+			}
+			return true
+		})
+	}
+
+	orientation := parseExifOrientation(exif)
+	if orientation < ORIENTATION_NORMAL || orientation > ORIENTATION_ROTATE_270_CW {
+		return ORIENTATION_NORMAL
+	}
+
+	return orientation
+}
+
+// parseExifOrientation --> baca tag orientation dari IFD0 data TIFF (isi EXIF tanpa header "Exif\0\0")
+func parseExifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return ORIENTATION_NORMAL
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return ORIENTATION_NORMAL
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return ORIENTATION_NORMAL
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+
+		if order.Uint16(tiff[entry:]) == exifOrientationTag {
+			// type SHORT, value disimpan di 2 byte pertama value field
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return ORIENTATION_NORMAL
+}
+
+// applyOrientation --> putar/balik image sesuai orientation supaya tampil tegak tanpa EXIF
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= ORIENTATION_NORMAL || orientation > ORIENTATION_ROTATE_270_CW {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// orientation 5-8 menukar width dan height
+	dstWidth, dstHeight := width, height
+	if orientation >= ORIENTATION_TRANSPOSE {
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+
+			switch orientation {
+			case ORIENTATION_FLIP_H:
+				dx, dy = width-1-x, y
+			case ORIENTATION_ROTATE_180:
+				dx, dy = width-1-x, height-1-y
+			case ORIENTATION_FLIP_V:
+				dx, dy = x, height-1-y
+			case ORIENTATION_TRANSPOSE:
+				dx, dy = y, x
+			case ORIENTATION_ROTATE_90_CW:
+				dx, dy = height-1-y, x
+			case ORIENTATION_TRANSVERSE:
+				dx, dy = height-1-y, width-1-x
+			case ORIENTATION_ROTATE_270_CW:
+				dx, dy = y, width-1-x
+			}
+
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	return dst
+}
+
+// stripMetadata --> hapus EXIF/XMP/IPTC/comment dari file image tanpa encode ulang pixel nya.
+// ICC profile tetap disimpan supaya warna image tidak berubah
+func stripMetadata(data []byte, contentType string) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJpegMetadata(data)
+	case "image/png":
+		return stripPngMetadata(data)
+	case "image/webp":
+		return stripWebpMetadata(data)
+	case "image/gif":
+		return stripGifMetadata(data)
+	default:
+		return nil, fmt.Errorf("unsupported content type: %s", contentType)
+	}
+}
+
+// walkJpegSegments --> panggil fn untuk setiap segment sebelum SOS (start of scan),
+// berhenti kalau fn return false. Return offset SOS, atau -1 kalau file tidak valid
+func walkJpegSegments(data []byte, fn func(marker byte, payload []byte) bool) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return -1
+	}
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return -1
+		}
+
+		marker := data[i+1]
+
+		// fill byte
+		if marker == 0xFF {
+			i++
+			continue
+		}
+
+		if marker == 0xDA {
+			return i
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return -1
+		}
+
+		if !fn(marker, data[i+4:i+2+length]) {
+			return i
+		}
+
+		i += 2 + length
+	}
+
+	return -1
+}
+
+func stripJpegMetadata(data []byte) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("invalid jpeg")
+	}
+
+	out := &bytes.Buffer{}
+	out.Write(data[:2])
+
+	sos := walkJpegSegments(data, func(marker byte, payload []byte) bool {
+		keep := true
+
+		switch {
+		// APP2 bisa berisi ICC profile atau metadata lain (MPF, FlashPix)
+		case marker == 0xE2:
+			keep = bytes.HasPrefix(payload, []byte(jpegICCProfileHeader))
+		// APP0 (JFIF) dan APP14 (Adobe) dibutuhkan decoder untuk warna
+		case marker >= 0xE1 && marker <= 0xEF && marker != 0xEE:
+			keep = false
+		case marker == 0xFE:
+			keep = false
+		}
+
+		if keep {
+			out.Write([]byte{0xFF, marker})
+			binary.Write(out, binary.BigEndian, uint16(len(payload)+2))
+			out.Write(payload)
+		}
+
+		return true
+	})
+	if sos < 0 {
+		return nil, fmt.Errorf("invalid jpeg")
+	}
+
+	out.Write(data[sos:])
+
+	return out.Bytes(), nil
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// walkPngChunks --> panggil fn untuk setiap chunk png, berhenti kalau fn return false.
+// Return false kalau file tidak valid
+func walkPngChunks(data []byte, fn func(chunkType string, payload []byte) bool) bool {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return false
+	}
+
+	i := len(pngSignature)
+	for i < len(data) {
+		if i+8 > len(data) {
+			return false
+		}
+
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return false
+		}
+
+		chunkType := string(data[i+4 : i+8])
+		if !fn(chunkType, data[i+8:i+8+length]) {
+			return true
+		}
+
+		// data setelah IEND diabaikan
+		if chunkType == "IEND" {
+			return true
+		}
+
+		i = end
+	}
+
+	return true
+}
+
+func stripPngMetadata(data []byte) ([]byte, error) {
+	out := &bytes.Buffer{}
+	out.Write(pngSignature)
+
+	i := len(pngSignature)
+	ok := walkPngChunks(data, func(chunkType string, payload []byte) bool {
+		chunkLen := 12 + len(payload)
+
+		switch chunkType {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(data[i : i+chunkLen])
+		}
+
+		i += chunkLen
+		return true
+	})
+	if !ok {
+		return nil, fmt.Errorf("invalid png")
+	}
+
+	return out.Bytes(), nil
+}
+
+// walkWebpChunks --> panggil fn untuk setiap chunk RIFF webp, berhenti kalau fn return false.
+// Return false kalau file tidak valid
+func walkWebpChunks(data []byte, fn func(fourcc string, payload []byte) bool) bool {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return false
+	}
+
+	i := 12
+	for i < len(data) {
+		if i+8 > len(data) {
+			return false
+		}
+
+		length := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if length < 0 || i+8+length > len(data) {
+			return false
+		}
+
+		if !fn(string(data[i:i+4]), data[i+8:i+8+length]) {
+			return true
+		}
+
+		// chunk di padding ke ukuran genap
+		i += 8 + length + length%2
+	}
+
+	return true
+}
+
+func stripWebpMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("invalid webp")
+	}
+
+	out := &bytes.Buffer{}
+	out.Write(data[:12])
+
+	ok := walkWebpChunks(data, func(fourcc string, payload []byte) bool {
+		switch fourcc {
+		case "EXIF", "XMP ":
+			return true
+		case "VP8X":
+			// flag EXIF/XMP di header extended harus dihapus juga
+			payload = bytes.Clone(payload)
+			if len(payload) > 0 {
+				payload[0] &^= webpFlagExif | webpFlagXmp
+			}
+		}
+
+		out.WriteString(fourcc)
+		binary.Write(out, binary.LittleEndian, uint32(len(payload)))
+		out.Write(payload)
+		if len(payload)%2 == 1 {
+			out.WriteByte(0)
+		}
+
+		return true
+	})
+	if !ok {
+		return nil, fmt.Errorf("invalid webp")
+	}
+
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+
+	return result, nil
+}
+
+// application extension gif yang tetap disimpan: loop animasi dan ICC profile
+var gifKeepApplications = map[string]bool{
+	"NETSCAPE2.0": true,
+	"ANIMEXTS1.0": true,
+	"ICCRGBG1012": true,
+}
+
+// skipGifSubBlocks --> return offset setelah block terminator, atau -1 kalau file tidak valid
+func skipGifSubBlocks(data []byte, i int) int {
+	for i < len(data) {
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i
+		}
+		i += size
+	}
+
+	return -1
+}
+
+// gifColorTableSize --> ukuran global/local color table dari packed field
+func gifColorTableSize(packed byte) int {
+	if packed&0x80 == 0 {
+		return 0
+	}
+	return 3 << ((packed & 0x07) + 1)
+}
+
+// stripGifMetadata --> buang comment extension dan application extension selain loop/ICC (misal XMP)
+// langsung dari byte nya. Frame tidak di decode, gif animasi dengan banyak frame tidak memakan memory
+func stripGifMetadata(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, fmt.Errorf("invalid gif")
+	}
+
+	// header, logical screen descriptor dan global color table
+	i := 13 + gifColorTableSize(data[10])
+	if i > len(data) {
+		return nil, fmt.Errorf("invalid gif")
+	}
+
+	out := &bytes.Buffer{}
+	out.Write(data[:i])
+
+	for i < len(data) {
+		start := i
+
+		switch data[i] {
+		case 0x3B:
+			// trailer, data setelahnya diabaikan
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+		case 0x21:
+			if i+2 > len(data) {
+				return nil, fmt.Errorf("invalid gif")
+			}
+
+			label := data[i+1]
+			keep := true
+
+			switch label {
+			case 0xFE:
+				keep = false
+			case 0xFF:
+				// sub-block pertama application extension berisi identifier 8 byte + auth code 3 byte
+				if i+3+11 > len(data) || data[i+2] != 11 {
+					return nil, fmt.Errorf("invalid gif")
+				}
+				keep = gifKeepApplications[string(data[i+3:i+3+11])]
+			}
+
+			i = skipGifSubBlocks(data, i+2)
+			if i < 0 {
+				return nil, fmt.Errorf("invalid gif")
+			}
+
+			if keep {
+				out.Write(data[start:i])
+			}
+		case 0x2C:
+			// image descriptor 10 byte, local color table, lzw minimum code size lalu data frame
+			if i+10 > len(data) {
+				return nil, fmt.Errorf("invalid gif")
+			}
+
+			i += 10 + gifColorTableSize(data[i+9]) + 1
+			if i > len(data) {
+				return nil, fmt.Errorf("invalid gif")
+			}
+
+			i = skipGifSubBlocks(data, i)
+			if i < 0 {
+				return nil, fmt.Errorf("invalid gif")
+			}
+
+			out.Write(data[start:i])
+		default:
+			return nil, fmt.Errorf("invalid gif")
+		}
+	}
+
+	// tanpa trailer
+	return nil, fmt.Errorf("invalid gif")
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func testTiff(order binary.ByteOrder, orientation uint16) []byte {
+	buf := &bytes.Buffer{}
+	if order == binary.LittleEndian {
+		buf.WriteString("II*\x00")
+	} else {
+		buf.WriteString("MM\x00*")
+	}
+	binary.Write(buf, order, uint32(8))
+
+	// IFD0 dengan satu entry: tag, type SHORT, count 1, value
+	binary.Write(buf, order, uint16(1))
+	binary.Write(buf, order, uint16(exifOrientationTag))
+	binary.Write(buf, order, uint16(3))
+	binary.Write(buf, order, uint32(1))
+	binary.Write(buf, order, orientation)
+	buf.Write([]byte{0, 0})
+	binary.Write(buf, order, uint32(0))
+
+	return buf.Bytes()
+}
+
+func testJpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func testJpeg(segments ...[]byte) []byte {
+	data := []byte{0xFF, 0xD8}
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	// SOS lalu data scan dan EOI
+	return append(data, 0xFF, 0xDA, 0x00, 0x02, 0x11, 0x22, 0xFF, 0xD9)
+}
+
+func testPngChunk(chunkType string, payload []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, payload...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func testPng(chunks ...[]byte) []byte {
+	data := bytes.Clone(pngSignature)
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	return data
+}
+
+func testPngIHDR(width, height uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32(nil, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	// bit depth 8, color type RGBA, compression, filter, interlace
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+	return testPngChunk("IHDR", ihdr)
+}
+
+func testWebpChunk(fourcc string, payload []byte) []byte {
+	chunk := append([]byte(fourcc), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func testWebp(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(data, body...)
+}
+
+func TestParseExifOrientation(t *testing.T) {
+	valid := testTiff(binary.LittleEndian, ORIENTATION_ROTATE_90_CW)
+
+	badOffset := bytes.Clone(valid)
+	binary.LittleEndian.PutUint32(badOffset[4:], 0xFFFFFFF0)
+
+	smallOffset := bytes.Clone(valid)
+	binary.LittleEndian.PutUint32(smallOffset[4:], 2)
+
+	manyEntries := bytes.Clone(valid)
+	binary.LittleEndian.PutUint16(manyEntries[8:], 0xFFFF)
+
+	otherTag := bytes.Clone(valid)
+	binary.LittleEndian.PutUint16(otherTag[10:], 0x0100)
+
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"little endian", valid, ORIENTATION_ROTATE_90_CW},
+		{"big endian", testTiff(binary.BigEndian, ORIENTATION_ROTATE_180), ORIENTATION_ROTATE_180},
+		{"empty", nil, ORIENTATION_NORMAL},
+		{"short header", valid[:6], ORIENTATION_NORMAL},
+		{"invalid byte order", append([]byte("XX*\x00"), valid[4:]...), ORIENTATION_NORMAL},
+		{"offset past end", badOffset, ORIENTATION_NORMAL},
+		{"offset inside header", smallOffset, ORIENTATION_NORMAL},
+		{"entry count past end", manyEntries, ORIENTATION_ROTATE_90_CW},
+		{"truncated entry", valid[:16], ORIENTATION_NORMAL},
+		{"no orientation tag", otherTag, ORIENTATION_NORMAL},
+	}
+
+	for _, tt := range tests {
+		if got := parseExifOrientation(tt.tiff); got != tt.want {
+			t.Errorf("%s: parseExifOrientation = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadOrientation(t *testing.T) {
+	exif := append([]byte(exifHeader), testTiff(binary.BigEndian, ORIENTATION_ROTATE_270_CW)...)
+
+	truncatedJpeg := testJpeg(testJpegSegment(0xE1, exif))
+	binary.BigEndian.PutUint16(truncatedJpeg[4:], 0xFFFF)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		want        int
+	}{
+		{"jpeg exif", testJpeg(testJpegSegment(0xE0, []byte("JFIF\x00")), testJpegSegment(0xE1, exif)), "image/jpeg", ORIENTATION_ROTATE_270_CW},
+		{"jpeg without exif", testJpeg(testJpegSegment(0xE0, []byte("JFIF\x00"))), "image/jpeg", ORIENTATION_NORMAL},
+		{"jpeg xmp in app1", testJpeg(testJpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00"))), "image/jpeg", ORIENTATION_NORMAL},
+		{"jpeg segment length past end", truncatedJpeg, "image/jpeg", ORIENTATION_NORMAL},
+		{"jpeg cut inside segment", testJpeg(testJpegSegment(0xE1, exif))[:10], "image/jpeg", ORIENTATION_NORMAL},
+		{"jpeg without soi", testJpeg(testJpegSegment(0xE1, exif))[2:], "image/jpeg", ORIENTATION_NORMAL},
+		{"png exif", testPng(testPngIHDR(1, 1), testPngChunk("eXIf", exif[len(exifHeader):])), "image/png", ORIENTATION_ROTATE_270_CW},
+		{"png truncated chunk", testPng(testPngIHDR(1, 1), testPngChunk("eXIf", exif[len(exifHeader):]))[:40], "image/png", ORIENTATION_NORMAL},
+		{"webp exif", testWebp(testWebpChunk("VP8X", make([]byte, 10)), testWebpChunk("EXIF", exif)), "image/webp", ORIENTATION_ROTATE_270_CW},
+		{"webp exif without header", testWebp(testWebpChunk("EXIF", exif[len(exifHeader):])), "image/webp", ORIENTATION_ROTATE_270_CW},
+		{"webp truncated chunk", testWebp(testWebpChunk("EXIF", exif))[:24], "image/webp", ORIENTATION_NORMAL},
+		{"unknown type", testJpeg(testJpegSegment(0xE1, exif)), "image/gif", ORIENTATION_NORMAL},
+		{"orientation out of range", testJpeg(testJpegSegment(0xE1, append([]byte(exifHeader), testTiff(binary.LittleEndian, 9)...))), "image/jpeg", ORIENTATION_NORMAL},
+	}
+
+	for _, tt := range tests {
+		if got := readOrientation(tt.data, tt.contentType); got != tt.want {
+			t.Errorf("%s: readOrientation = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStripJpegMetadata(t *testing.T) {
+	app0 := testJpegSegment(0xE0, []byte("JFIF\x00"))
+	icc := testJpegSegment(0xE2, []byte(jpegICCProfileHeader+"profile"))
+	adobe := testJpegSegment(0xEE, []byte("Adobe"))
+	dqt := testJpegSegment(0xDB, []byte{0x00, 0x01})
+
+	data := testJpeg(
+		app0,
+		testJpegSegment(0xE1, append([]byte(exifHeader), testTiff(binary.LittleEndian, 6)...)),
+		testJpegSegment(0xE2, []byte("MPF\x00")),
+		icc,
+		testJpegSegment(0xED, []byte("Photoshop 3.0\x00")),
+		adobe,
+		testJpegSegment(0xFE, []byte("comment")),
+		dqt,
+	)
+
+	got, err := stripJpegMetadata(data)
+	if err != nil {
+		t.Fatalf("stripJpegMetadata: %v", err)
+	}
+
+	want := testJpeg(app0, icc, adobe, dqt)
+	if !bytes.Equal(got, want) {
+		t.Errorf("stripJpegMetadata = %x, want %x", got, want)
+	}
+
+	invalid := map[string][]byte{
+		"empty":            nil,
+		"only soi":         {0xFF, 0xD8},
+		"no soi":           data[2:],
+		"truncated":        data[:12],
+		"no sos":           append([]byte{0xFF, 0xD8}, app0...),
+		"zero length":      append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x00}, data[2:]...),
+		"garbage marker":   append([]byte{0xFF, 0xD8, 0x12, 0x34, 0x00, 0x04}, data[2:]...),
+		"length past end":  append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF}, data[2:]...),
+		"segment cut off":  append(append([]byte{0xFF, 0xD8}, app0...), 0xFF, 0xE1, 0x00),
+		"fill bytes only":  {0xFF, 0xD8, 0xFF, 0xFF, 0xFF, 0xFF},
+		"png instead jpeg": testPng(testPngIHDR(1, 1)),
+	}
+
+	for name, data := range invalid {
+		if _, err := stripJpegMetadata(data); err == nil {
+			t.Errorf("%s: stripJpegMetadata error = nil, want error", name)
+		}
+	}
+}
+
+func TestStripPngMetadata(t *testing.T) {
+	ihdr := testPngIHDR(1, 1)
+	iccp := testPngChunk("iCCP", []byte("icc\x00\x00data"))
+	idat := testPngChunk("IDAT", []byte{0x01, 0x02})
+	iend := testPngChunk("IEND", nil)
+
+	data := testPng(
+		ihdr,
+		testPngChunk("eXIf", testTiff(binary.BigEndian, 3)),
+		iccp,
+		testPngChunk("tEXt", []byte("Comment\x00hello")),
+		testPngChunk("zTXt", []byte("Author\x00\x00x")),
+		testPngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<x/>")),
+		testPngChunk("tIME", make([]byte, 7)),
+		idat,
+		iend,
+	)
+
+	got, err := stripPngMetadata(data)
+	if err != nil {
+		t.Fatalf("stripPngMetadata: %v", err)
+	}
+
+	want := testPng(ihdr, iccp, idat, iend)
+	if !bytes.Equal(got, want) {
+		t.Errorf("stripPngMetadata = %x, want %x", got, want)
+	}
+
+	// data setelah IEND dibuang
+	got, err = stripPngMetadata(append(bytes.Clone(want), "trailing"...))
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("stripPngMetadata with trailing data = %x, %v, want %x", got, err, want)
+	}
+
+	hugeLength := bytes.Clone(data)
+	binary.BigEndian.PutUint32(hugeLength[len(pngSignature):], 0xFFFFFFFF)
+
+	invalid := map[string][]byte{
+		"empty":             nil,
+		"no signature":      data[len(pngSignature):],
+		"truncated header":  data[:len(pngSignature)+4],
+		"truncated payload": data[:len(pngSignature)+15],
+		"missing crc":       data[:len(pngSignature)+len(ihdr)-2],
+		"length past end":   hugeLength,
+	}
+
+	for name, data := range invalid {
+		if _, err := stripPngMetadata(data); err == nil {
+			t.Errorf("%s: stripPngMetadata error = nil, want error", name)
+		}
+	}
+}
+
+func TestStripWebpMetadata(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpFlagExif | webpFlagXmp | 0x10
+	iccp := testWebpChunk("ICCP", []byte("icc"))
+	vp8 := testWebpChunk("VP8 ", []byte{0x01, 0x02, 0x03, 0x04})
+
+	data := testWebp(
+		testWebpChunk("VP8X", vp8x),
+		iccp,
+		testWebpChunk("EXIF", append([]byte(exifHeader), testTiff(binary.LittleEndian, 8)...)),
+		testWebpChunk("XMP ", []byte("<x:xmpmeta/>")),
+		vp8,
+	)
+
+	got, err := stripWebpMetadata(data)
+	if err != nil {
+		t.Fatalf("stripWebpMetadata: %v", err)
+	}
+
+	strippedVp8x := make([]byte, 10)
+	strippedVp8x[0] = 0x10
+	want := testWebp(testWebpChunk("VP8X", strippedVp8x), iccp, vp8)
+	if !bytes.Equal(got, want) {
+		t.Errorf("stripWebpMetadata = %x, want %x", got, want)
+	}
+
+	// input tidak boleh ikut berubah
+	if data[20] != vp8x[0] {
+		t.Errorf("stripWebpMetadata modified input VP8X flags")
+	}
+
+	hugeLength := bytes.Clone(data)
+	binary.LittleEndian.PutUint32(hugeLength[16:], 0xFFFFFFF0)
+
+	invalid := map[string][]byte{
+		"empty":             nil,
+		"riff only":         []byte("RIFF"),
+		"not webp":          append([]byte("RIFF\x04\x00\x00\x00WAVE"), vp8...),
+		"truncated header":  data[:16],
+		"truncated payload": data[:24],
+		"length past end":   hugeLength,
+	}
+
+	for name, data := range invalid {
+		if _, err := stripWebpMetadata(data); err == nil {
+			t.Errorf("%s: stripWebpMetadata error = nil, want error", name)
+		}
+	}
+}
+
+func TestStripGifMetadata(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	frames := []*image.Paletted{
+		image.NewPaletted(image.Rect(0, 0, 4, 4), palette),
+		image.NewPaletted(image.Rect(0, 0, 4, 4), palette),
+	}
+	frames[1].SetColorIndex(1, 1, 1)
+
+	encoded := &bytes.Buffer{}
+	if err := gif.EncodeAll(encoded, &gif.GIF{Image: frames, Delay: []int{10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+	want := encoded.Bytes()
+
+	comment := []byte{0x21, 0xFE, 0x05, 'h', 'e', 'l', 'l', 'o', 0x00}
+	xmp := append([]byte{0x21, 0xFF, 0x0B}, "XMP DataXMP"...)
+	xmp = append(xmp, 0x03, '<', 'x', '>', 0x00)
+
+	// comment dan XMP disisipkan setelah global color table
+	gct := 13 + gifColorTableSize(want[10])
+	data := append(append(append(bytes.Clone(want[:gct]), comment...), xmp...), want[gct:]...)
+
+	got, err := stripGifMetadata(data)
+	if err != nil {
+		t.Fatalf("stripGifMetadata: %v", err)
+	}
+
+	// loop (NETSCAPE2.0) dan semua frame tetap ada
+	if !bytes.Equal(got, want) {
+		t.Errorf("stripGifMetadata = %x, want %x", got, want)
+	}
+
+	decoded, err := gif.DecodeAll(bytes.NewReader(got))
+	if err != nil || len(decoded.Image) != 2 {
+		t.Errorf("decoded stripped gif = %v, want 2 frames", err)
+	}
+
+	invalid := map[string][]byte{
+		"empty":              nil,
+		"not gif":            testPng(testPngIHDR(1, 1)),
+		"header only":        want[:6],
+		"truncated gct":      want[:14],
+		"unknown block":      append(bytes.Clone(want[:gct]), 0x99),
+		"truncated frame":    want[:len(want)-4],
+		"no trailer":         want[:len(want)-1],
+		"bad application":    append(append(bytes.Clone(want[:gct]), 0x21, 0xFF, 0x02, 'x', 'y', 0x00), want[gct:]...),
+		"sub-block past end": append(bytes.Clone(want[:gct]), 0x21, 0xFE, 0x7F, 'x'),
+	}
+
+	for name, data := range invalid {
+		if _, err := stripGifMetadata(data); err == nil {
+			t.Errorf("%s: stripGifMetadata error = nil, want error", name)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// image 2x1: kiri merah, kanan biru
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, red)
+	img.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		bounds      image.Point
+		first       color.RGBA
+	}{
+		{ORIENTATION_NORMAL, image.Pt(2, 1), red},
+		{ORIENTATION_FLIP_H, image.Pt(2, 1), blue},
+		{ORIENTATION_ROTATE_180, image.Pt(2, 1), blue},
+		{ORIENTATION_FLIP_V, image.Pt(2, 1), red},
+		{ORIENTATION_TRANSPOSE, image.Pt(1, 2), red},
+		{ORIENTATION_ROTATE_90_CW, image.Pt(1, 2), red},
+		{ORIENTATION_TRANSVERSE, image.Pt(1, 2), blue},
+		{ORIENTATION_ROTATE_270_CW, image.Pt(1, 2), blue},
+	}
+
+	for _, tt := range tests {
+		got := applyOrientation(img, tt.orientation)
+
+		if size := got.Bounds().Size(); size != tt.bounds {
+			t.Errorf("orientation %d: size = %v, want %v", tt.orientation, size, tt.bounds)
+			continue
+		}
+
+		if c := color.RGBAModel.Convert(got.At(0, 0)); c != tt.first {
+			t.Errorf("orientation %d: pixel (0,0) = %v, want %v", tt.orientation, c, tt.first)
+		}
+	}
+}
+
+func TestSaveImageRejectsHugeDimensions(t *testing.T) {
+	storage := &ImageStorage{}
+
+	tests := map[string][]byte{
+		"too many pixels": testPng(testPngIHDR(100000, 100000), testPngChunk("IEND", nil)),
+		"zero width":      testPng(testPngIHDR(0, 10), testPngChunk("IEND", nil)),
+	}
+
+	for name, data := range tests {
+		if _, err := storage.SaveImage(context.Background(), "user", false, data); !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("%s: SaveImage = %v, want ErrInvalidImage", name, err)
+		}
+	}
+
+	if err := checkImagePixels(10000, 5000); err != nil {
+		t.Errorf("checkImagePixels(10000, 5000) = %v, want nil", err)
+	}
+
+	if err := checkImagePixels(10000, 5001); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("checkImagePixels(10000, 5001) = %v, want ErrInvalidImage", err)
+	}
+}
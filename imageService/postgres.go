@@ -0,0 +1,438 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// state image di tabel images
+//
+// pending: baru diupload, belum dipakai post/profile
+// active: dipakai minimal satu post/profile (refCount > 0)
+// orphaned: pernah dipakai tapi sekarang refCount 0
+// deleting: sedang dihapus sweeper/owner, file di BlobStore mungkin masih ada
+// deleted: file sudah dihapus dari BlobStore
+const (
+	IMAGE_STATE_PENDING  = "pending"
+	IMAGE_STATE_ACTIVE   = "active"
+	IMAGE_STATE_ORPHANED = "orphaned"
+	IMAGE_STATE_DELETING = "deleting"
+	IMAGE_STATE_DELETED  = "deleted"
+)
+
+type PostgresStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresStorage() *PostgresStorage {
+	userDB := os.Getenv("POSTGRES_USER")
+	passDB := os.Getenv("POSTGRES_PASSWORD")
+	databaseDB := os.Getenv("POSTGRES_DB")
+	hostDB := os.Getenv("POSTGRES_HOST")
+	connStr := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", userDB, passDB, hostDB, databaseDB)
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatal("Cannot establish connection to database: ", err.Error())
+	}
+
+	if err = db.Ping(); err != nil {
+		log.Fatal("Cannot ping to database: ", err.Error())
+	}
+
+	log.Println("Connected to database")
+
+	return &PostgresStorage{
+		db: db,
+	}
+}
+
+func (s *PostgresStorage) Init() {
+	if err := s.createImageTable(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.createImageVariantTable(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.alterImageTablePrivate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.createImageOwnerTable(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (s *PostgresStorage) createImageTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS images (
+            filename TEXT PRIMARY KEY,
+            idUser TEXT NOT NULL,
+            mimeType TEXT NOT NULL,
+            size BIGINT NOT NULL,
+            width INTEGER NOT NULL,
+            height INTEGER NOT NULL,
+            refCount INTEGER DEFAULT 0 NOT NULL,
+            state TEXT DEFAULT 'pending' NOT NULL,
+
+            createdAt INTEGER NOT NULL,
+            unreferencedAt INTEGER,
+            deletedAt INTEGER
+        )`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS images_unreferenced_idx ON images (unreferencedAt) WHERE refCount = 0`)
+
+	return err
+}
+
+// image_variants --> key BlobStore hasil resize on-demand, supaya ikut terhapus bersama image nya
+func (s *PostgresStorage) createImageVariantTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS image_variants (
+            filename TEXT NOT NULL,
+            key TEXT NOT NULL,
+            PRIMARY KEY (filename, key)
+        )`)
+
+	return err
+}
+
+// private --> image hanya bisa dibuka dengan signed url
+func (s *PostgresStorage) alterImageTablePrivate() error {
+	_, err := s.db.Exec(`
+        ALTER TABLE images ADD COLUMN IF NOT EXISTS private BOOLEAN DEFAULT false NOT NULL`)
+
+	return err
+}
+
+// image_owners --> user yang upload image, satu blob public bisa dimiliki banyak user karena
+// nama file nya hash isi image. images.idUser adalah uploader pertama
+func (s *PostgresStorage) createImageOwnerTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS image_owners (
+            filename TEXT NOT NULL,
+            idUser TEXT NOT NULL,
+            createdAt INTEGER NOT NULL,
+            PRIMARY KEY (filename, idUser)
+        )`)
+	if err != nil {
+		return err
+	}
+
+	// image yang dibuat sebelum ada image_owners
+	_, err = s.db.Exec(`
+        INSERT INTO image_owners (filename, idUser, createdAt)
+        SELECT filename, idUser, createdAt FROM images WHERE state <> 'deleted'
+        ON CONFLICT DO NOTHING`)
+
+	return err
+}
+
+// CreateImage --> simpan record image baru. Image dengan nama yang sama yang sudah dihapus
+// dipakai lagi (upload ulang isi yang sama setelah dihapus sweeper)
+func (s *PostgresStorage) CreateImage(image *AppImage) error {
+	stmt, err := s.db.Prepare(`
+        INSERT INTO images (filename, idUser, mimeType, size, width, height, state, private, createdAt, unreferencedAt)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+        ON CONFLICT (filename) DO UPDATE
+        SET
+            idUser = EXCLUDED.idUser,
+            refCount = 0,
+            state = EXCLUDED.state,
+            private = EXCLUDED.private,
+            createdAt = EXCLUDED.createdAt,
+            unreferencedAt = EXCLUDED.unreferencedAt,
+            deletedAt = NULL
+        WHERE images.state = 'deleted'`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.Exec(image.Filename, image.IdUser, image.MimeType, image.Size, image.Width, image.Height, IMAGE_STATE_PENDING, image.Private, image.CreatedAt)
+
+	return err
+}
+
+func (s *PostgresStorage) GetImageByFilename(filename string, image *AppImage) error {
+	stmt, err := s.db.Prepare(`
+        SELECT filename, idUser, mimeType, size, width, height, refCount, state, private, createdAt
+        FROM images
+        WHERE filename = $1`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	return stmt.QueryRow(filename).Scan(
+		&image.Filename,
+		&image.IdUser,
+		&image.MimeType,
+		&image.Size,
+		&image.Width,
+		&image.Height,
+		&image.RefCount,
+		&image.State,
+		&image.Private,
+		&image.CreatedAt,
+	)
+}
+
+// ChangeImageRefs --> increment refCount image yang mulai dipakai post/profile dan decrement image yang
+// tidak dipakai lagi dalam satu transaksi. Filename kosong dilewati, filename yang tidak ada di tabel
+// (image lama/default) diabaikan. Image yang refCount nya jadi 0 menjadi orphaned dan dihapus sweeper
+// setelah grace period
+func (s *PostgresStorage) ChangeImageRefs(increment, decrement string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if increment != "" {
+		if _, err := tx.Exec(`
+            UPDATE images
+            SET
+                refCount = refCount + 1,
+                state = $1,
+                unreferencedAt = NULL
+            WHERE
+                filename = $2
+                AND state IN ($3, $1, $4)
+            `, IMAGE_STATE_ACTIVE, increment, IMAGE_STATE_PENDING, IMAGE_STATE_ORPHANED); err != nil {
+			return err
+		}
+	}
+
+	if decrement != "" {
+		if _, err := tx.Exec(`
+            UPDATE images
+            SET
+                refCount = GREATEST(refCount - 1, 0),
+                state = CASE WHEN refCount <= 1 THEN $1 ELSE $2 END,
+                unreferencedAt = CASE WHEN refCount <= 1 THEN $3 ELSE unreferencedAt END
+            WHERE
+                filename = $4
+                AND state IN ($2, $1)
+            `, IMAGE_STATE_ORPHANED, IMAGE_STATE_ACTIVE, time.Now().Unix(), decrement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListUnreferencedImages --> image dengan refCount 0 yang tidak dipakai sejak sebelum cutoff.
+// Image yang gagal dihapus (state deleting) ikut diambil lagi
+func (s *PostgresStorage) ListUnreferencedImages(cutoff int64, limit int32, filenames *[]string) error {
+	rows, err := s.db.Query(`
+        SELECT filename
+        FROM images
+        WHERE
+            refCount = 0
+            AND state IN ($1, $2, $3)
+            AND unreferencedAt < $4
+        ORDER BY unreferencedAt ASC
+        LIMIT $5
+        `, IMAGE_STATE_PENDING, IMAGE_STATE_ORPHANED, IMAGE_STATE_DELETING, cutoff, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			return err
+		}
+		*filenames = append(*filenames, filename)
+	}
+
+	return rows.Err()
+}
+
+// ClaimImageForDeletion --> tandai image sedang dihapus. Hanya berhasil kalau refCount masih 0
+// dan tidak dipakai sejak sebelum cutoff, return sql.ErrNoRows kalau tidak. unreferencedAt
+// di reset supaya instance lain tidak menghapus image yang sama, dan kalau penghapusan
+// gagal image dicoba lagi setelah grace period berikutnya
+func (s *PostgresStorage) ClaimImageForDeletion(filename string, cutoff int64) error {
+	var claimed string
+
+	return s.db.QueryRow(`
+        UPDATE images
+        SET
+            state = $1,
+            unreferencedAt = $2
+        WHERE
+            filename = $3
+            AND refCount = 0
+            AND state IN ($4, $5, $1)
+            AND unreferencedAt < $6
+        RETURNING filename
+        `, IMAGE_STATE_DELETING, time.Now().Unix(), filename, IMAGE_STATE_PENDING, IMAGE_STATE_ORPHANED, cutoff).Scan(&claimed)
+}
+
+// AddImageOwner --> tambah owner image. Visibility image tidak diubah, image private tidak pernah
+// dipakai bersama owner lain
+func (s *PostgresStorage) AddImageOwner(filename, idUser string, createdAt int64) error {
+	_, err := s.db.Exec(`
+        INSERT INTO image_owners (filename, idUser, createdAt)
+        VALUES ($1, $2, $3)
+        ON CONFLICT DO NOTHING`, filename, idUser, createdAt)
+
+	return err
+}
+
+// AddOwnerToExistingImage --> tambah owner ke image public yang sudah ada (dedupe), return false kalau
+// image nya private atau sudah diclaim untuk dihapus. Image yang belum dipakai post/profile dianggap
+// baru diupload lagi: state kembali pending dan unreferencedAt di reset, supaya sweeper tidak menghapus
+// blob nya sebelum event post.created dari uploader baru sampai
+func (s *PostgresStorage) AddOwnerToExistingImage(filename, idUser string, createdAt int64) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	var updated string
+	err = tx.QueryRow(`
+        UPDATE images
+        SET
+            state = CASE WHEN refCount > 0 THEN $1 ELSE $2 END,
+            unreferencedAt = CASE WHEN refCount > 0 THEN unreferencedAt ELSE $3 END
+        WHERE
+            filename = $4
+            AND NOT private
+            AND state IN ($1, $2, $5)
+        RETURNING filename
+        `, IMAGE_STATE_ACTIVE, IMAGE_STATE_PENDING, createdAt, filename, IMAGE_STATE_ORPHANED).Scan(&updated)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(`
+        INSERT INTO image_owners (filename, idUser, createdAt)
+        VALUES ($1, $2, $3)
+        ON CONFLICT DO NOTHING`, filename, idUser, createdAt); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (s *PostgresStorage) IsImageOwner(filename, idUser string) (bool, error) {
+	var exists bool
+
+	err := s.db.QueryRow(`
+        SELECT EXISTS (SELECT 1 FROM image_owners WHERE filename = $1 AND idUser = $2)`, filename, idUser).Scan(&exists)
+
+	return exists, err
+}
+
+// RemoveImageOwner --> hapus owner image, return sql.ErrNoRows kalau idUser bukan owner.
+// Return jumlah owner yang tersisa
+func (s *PostgresStorage) RemoveImageOwner(filename, idUser string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var removed string
+	if err := tx.QueryRow(`
+        DELETE FROM image_owners
+        WHERE
+            filename = $1
+            AND idUser = $2
+        RETURNING filename`, filename, idUser).Scan(&removed); err != nil {
+		return 0, err
+	}
+
+	var remaining int
+	if err := tx.QueryRow(`
+        SELECT COUNT(*) FROM image_owners WHERE filename = $1`, filename).Scan(&remaining); err != nil {
+		return 0, err
+	}
+
+	return remaining, tx.Commit()
+}
+
+func (s *PostgresStorage) MarkImageDeleted(filename string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+        UPDATE images
+        SET
+            state = $1,
+            deletedAt = $2
+        WHERE
+            filename = $3
+        `, IMAGE_STATE_DELETED, time.Now().Unix(), filename); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+        DELETE FROM image_variants WHERE filename = $1`, filename); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+        DELETE FROM image_owners WHERE filename = $1`, filename); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) AddImageVariant(filename, key string) error {
+	_, err := s.db.Exec(`
+        INSERT INTO image_variants (filename, key)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING`, filename, key)
+
+	return err
+}
+
+func (s *PostgresStorage) ListImageVariants(filename string, keys *[]string) error {
+	rows, err := s.db.Query(`
+        SELECT key FROM image_variants WHERE filename = $1`, filename)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return err
+		}
+		*keys = append(*keys, key)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	FIT_CONTAIN = "contain"
+	FIT_COVER   = "cover"
+)
+
+// resize --> resize image ke width, height mengikuti rasio image asli
+func resize(img image.Image, width int) image.Image {
+	originWidth := img.Bounds().Dx()
+	originHeight := img.Bounds().Dy()
+	ration := float64(originHeight) / float64(originWidth)
+	newHeight := int(math.Max(1, math.Floor(float64(width)*ration)))
+	rect := image.Rect(0, 0, width, newHeight)
+	resized := image.NewRGBA(rect)
+	draw.CatmullRom.Scale(resized, rect, img, img.Bounds(), draw.Over, nil)
+
+	return resized
+}
+
+// resizeFit --> resize image ke kotak width x height, 0 berarti mengikuti rasio image asli.
+//
+// contain: image diperkecil sampai muat di dalam kotak
+// cover: image diperkecil sampai menutupi kotak, sisanya di crop di tengah
+//
+// image tidak pernah diperbesar dari ukuran aslinya
+func resizeFit(img image.Image, width, height int, fit string) image.Image {
+	bounds := img.Bounds()
+	originWidth := float64(bounds.Dx())
+	originHeight := float64(bounds.Dy())
+
+	scaleX := math.Inf(1)
+	if width > 0 {
+		scaleX = float64(width) / originWidth
+	}
+
+	scaleY := math.Inf(1)
+	if height > 0 {
+		scaleY = float64(height) / originHeight
+	}
+
+	scale := math.Min(scaleX, scaleY)
+	if fit == FIT_COVER && width > 0 && height > 0 {
+		scale = math.Max(scaleX, scaleY)
+	}
+	scale = math.Min(scale, 1)
+
+	newWidth := int(math.Max(1, math.Round(originWidth*scale)))
+	newHeight := int(math.Max(1, math.Round(originHeight*scale)))
+
+	rect := image.Rect(0, 0, newWidth, newHeight)
+	resized := image.NewRGBA(rect)
+	draw.CatmullRom.Scale(resized, rect, img, bounds, draw.Over, nil)
+
+	if fit != FIT_COVER || width == 0 || height == 0 {
+		return resized
+	}
+
+	// crop bagian tengah
+	cropWidth := min(width, newWidth)
+	cropHeight := min(height, newHeight)
+	x := (newWidth - cropWidth) / 2
+	y := (newHeight - cropHeight) / 2
+
+	return resized.SubImage(image.Rect(x, y, x+cropWidth, y+cropHeight))
+}
+
+// encodeImage --> encode image sesuai format aslinya, webp di encode sebagai png
+// karena belum ada encoder webp. Return bytes dan content type hasil encode
+func encodeImage(img image.Image, contentType string) ([]byte, string, error) {
+	buf := &bytes.Buffer{}
+
+	switch contentType {
+	case "image/jpeg":
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, "", err
+		}
+	case "image/gif":
+		if err := gif.Encode(buf, img, nil); err != nil {
+			return nil, "", err
+		}
+	default:
+		if err := png.Encode(buf, img); err != nil {
+			return nil, "", err
+		}
+		contentType = "image/png"
+	}
+
+	return buf.Bytes(), contentType, nil
+}
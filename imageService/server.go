@@ -12,9 +12,9 @@ type AppServer struct {
 	Cfg    AppConfig
 }
 
-func NewAppServer(cfg AppConfig) AppServer {
+func NewAppServer(cfg AppConfig, storage *ImageStorage) AppServer {
 	router := mux.NewRouter().PathPrefix("/v1/image").Subrouter()
-	imageService := NewImageService(cfg)
+	imageService := NewImageService(cfg, storage)
 	imageService.RegisterRoutes(router)
 
 	return AppServer{
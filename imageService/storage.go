@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pewe21/library"
+	_ "golang.org/x/image/webp"
+)
+
+const THUMBNAIL_WIDTH = 512
+
+// MAX_IMAGE_PIXELS --> batas width*height image yang boleh di decode. File kecil bisa berisi
+// dimensi yang sangat besar (decompression bomb), jadi dimensi dicek dari header dulu
+const MAX_IMAGE_PIXELS = 50_000_000
+
+// ErrInvalidImage --> error karena input user (bukan error server), handler balikin 400/InvalidArgument
+var ErrInvalidImage = errors.New("invalid image")
+
+// ImageStorage --> proses dan simpan original dan thumbnail image ke BlobStore,
+// dipakai bersama oleh http handler dan grpc server
+type ImageStorage struct {
+	blobs BlobStore
+	store *PostgresStorage
+
+	// lebar thumbnail yang dibuat saat upload
+	renditions []int
+
+	// ukuran (width/height) yang boleh diminta lewat resize on-demand
+	resizeAllowlist map[int]bool
+
+	// secret dan masa berlaku signed url untuk image private
+	urlSecret string
+	urlTTL    time.Duration
+}
+
+func NewImageStorage(cfg AppConfig, blobs BlobStore, store *PostgresStorage) *ImageStorage {
+	allowlist := map[int]bool{}
+	for _, size := range cfg.ResizeAllowlist {
+		allowlist[size] = true
+	}
+
+	return &ImageStorage{
+		blobs:           blobs,
+		store:           store,
+		renditions:      cfg.ThumbnailSizes,
+		resizeAllowlist: allowlist,
+		urlSecret:       cfg.ImageURLSecret,
+		urlTTL:          cfg.SignedURLTTL,
+	}
+}
+
+// format image yang didukung, key nya hasil http.DetectContentType
+var supportedImageTypes = map[string]struct {
+	ext    string
+	format string
+}{
+	"image/jpeg": {ext: ".jpg", format: "jpeg"},
+	"image/png":  {ext: ".png", format: "png"},
+	"image/gif":  {ext: ".gif", format: "gif"},
+	"image/webp": {ext: ".webp", format: "webp"},
+}
+
+// SaveImage --> validasi, simpan original dan thumbnail, return record image yang dibuat server.
+// Format image dideteksi dari isi file, bukan dari nama file client. Orientation EXIF
+// diterapkan ke pixel image lalu semua metadata EXIF/XMP dihapus dari file yang disimpan.
+// Image baru berstatus pending sampai dipakai post/profile. Image private hanya bisa dibuka
+// lewat signed url yang dikembalikan di record nya
+func (s *ImageStorage) SaveImage(ctx context.Context, idUser string, private bool, imageBytes []byte) (*AppImage, error) {
+	if len(imageBytes) == 0 {
+		return nil, fmt.Errorf("%w: missing image from request", ErrInvalidImage)
+	}
+
+	contentType := http.DetectContentType(imageBytes)
+	imageType, ok := supportedImageTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid file type: %s, only jpeg/png/gif/webp supported", ErrInvalidImage, contentType)
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(imageBytes))
+	if err != nil || format != imageType.format {
+		log.Println("Error when decoding original image config:", format, err)
+		return nil, fmt.Errorf("%w: image is not supported", ErrInvalidImage)
+	}
+
+	if err := checkImagePixels(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+
+	// untuk gif, image.Decode hanya decode frame pertama
+	img, format, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil || format != imageType.format {
+		log.Println("Error when decoding original image file:", format, err)
+		return nil, fmt.Errorf("%w: image is not supported", ErrInvalidImage)
+	}
+
+	var originalBytes []byte
+
+	orientation := readOrientation(imageBytes, contentType)
+	if orientation != ORIENTATION_NORMAL {
+		// pixel harus diputar, encode ulang sekalian membuang metadata.
+		// webp di encode sebagai png
+		if err := checkImagePixels(img.Bounds().Dx(), img.Bounds().Dy()); err != nil {
+			return nil, err
+		}
+		img = applyOrientation(img, orientation)
+
+		originalBytes, contentType, err = encodeImage(img, contentType)
+		if err != nil {
+			return nil, fmt.Errorf("encoding oriented image: %w", err)
+		}
+		imageType = supportedImageTypes[contentType]
+	} else {
+		originalBytes, err = stripMetadata(imageBytes, contentType)
+		if err != nil {
+			log.Println("Error when stripping image metadata:", err)
+			return nil, fmt.Errorf("%w: image is not supported", ErrInvalidImage)
+		}
+	}
+
+	// nama file image public dari hash isi image yang sudah dinormalisasi, upload ulang image
+	// yang sama memakai blob yang sudah ada dan hanya menambah owner. Image private tidak ikut
+	// deduplikasi dan nama file nya acak, supaya keberadaan image private tidak bisa ditebak
+	// dari isi nya dan visibility nya tidak berubah karena upload user lain
+	timestamp := time.Now().Unix()
+	stamp := sha256Hex(originalBytes) + imageType.ext
+
+	if !private {
+		existing, err := s.addOwnerToExistingImage(stamp, idUser, timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			return existing, nil
+		}
+	}
+
+	if private || s.hashFilenameTaken(stamp) {
+		stamp = fmt.Sprintf("%d-%s%s", timestamp, uuid.NewString(), imageType.ext)
+	}
+
+	// save original file
+	if err := s.blobs.Put(ctx, path.Join("original", stamp), originalBytes, contentType); err != nil {
+		return nil, fmt.Errorf("writing original image: %w", err)
+	}
+
+	// thumbnail dengan lebar THUMBNAIL_WIDTH selalu dibuat untuk /thumbnail/{filename}
+	renditions := append([]int{THUMBNAIL_WIDTH}, s.renditions...)
+	done := map[int]bool{}
+
+	for _, width := range renditions {
+		if done[width] {
+			continue
+		}
+		done[width] = true
+
+		thumbBytes, thumbContentType, err := rendition(img, originalBytes, contentType, width)
+		if err != nil {
+			return nil, fmt.Errorf("encoding thumbnail: %w", err)
+		}
+
+		if err := s.blobs.Put(ctx, renditionKey(width, stamp), thumbBytes, thumbContentType); err != nil {
+			return nil, fmt.Errorf("writing thumbnail image: %w", err)
+		}
+	}
+
+	image := &AppImage{
+		Filename:  stamp,
+		IdUser:    idUser,
+		MimeType:  contentType,
+		Width:     img.Bounds().Dx(),
+		Height:    img.Bounds().Dy(),
+		Size:      int64(len(originalBytes)),
+		State:     IMAGE_STATE_PENDING,
+		Private:   private,
+		CreatedAt: timestamp,
+	}
+
+	// file yang sudah ditulis tanpa record akan tertinggal, sama seperti upload yang gagal ditengah
+	if err := s.store.CreateImage(image); err != nil {
+		return nil, fmt.Errorf("creating image record: %w", err)
+	}
+
+	if err := s.store.AddImageOwner(stamp, idUser, timestamp); err != nil {
+		return nil, fmt.Errorf("adding image owner: %w", err)
+	}
+
+	if private {
+		s.SignImageURLs(image)
+	}
+
+	return image, nil
+}
+
+// addOwnerToExistingImage --> kalau image public dengan isi yang sama sudah ada, tambahkan idUser
+// sebagai owner dan return record nya. Return nil kalau image belum ada, sedang/sudah dihapus atau
+// private (image private lama yang masih memakai nama hash isi)
+func (s *ImageStorage) addOwnerToExistingImage(filename, idUser string, createdAt int64) (*AppImage, error) {
+	image := &AppImage{}
+
+	if err := s.store.GetImageByFilename(filename, image); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting image record: %w", err)
+	}
+
+	if image.Private || image.State == IMAGE_STATE_DELETING || image.State == IMAGE_STATE_DELETED {
+		return nil, nil
+	}
+
+	// state dicek ulang didalam transaksi, sweeper bisa saja claim image nya setelah record diatas dibaca
+	added, err := s.store.AddOwnerToExistingImage(filename, idUser, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("adding image owner: %w", err)
+	}
+
+	if !added {
+		return nil, nil
+	}
+
+	image.IdUser = idUser
+
+	return image, nil
+}
+
+// hashFilenameTaken --> cek apakah nama hash isi tidak bisa dipakai untuk upload baru: dipakai image
+// private lama, atau image nya sedang dihapus (blob nya bisa terhapus setelah ditulis ulang)
+func (s *ImageStorage) hashFilenameTaken(filename string) bool {
+	image := &AppImage{}
+
+	if err := s.store.GetImageByFilename(filename, image); err != nil {
+		return false
+	}
+
+	if image.State == IMAGE_STATE_DELETING {
+		return true
+	}
+
+	return image.Private && image.State != IMAGE_STATE_DELETED
+}
+
+// IsImageOwner --> cek apakah idUser pernah upload image ini
+func (s *ImageStorage) IsImageOwner(filename, idUser string) (bool, error) {
+	return s.store.IsImageOwner(filename, idUser)
+}
+
+// RemoveImageOwner --> hapus image milik idUser. Blob hanya dihapus kalau tidak ada owner lain
+// dan tidak dipakai post/profile, kalau masih dipakai blob dihapus sweeper setelah tidak dipakai
+func (s *ImageStorage) RemoveImageOwner(ctx context.Context, filename, idUser string) error {
+	remaining, err := s.store.RemoveImageOwner(filename, idUser)
+	if err != nil {
+		return err
+	}
+
+	if remaining > 0 {
+		return nil
+	}
+
+	// owner terakhir tidak perlu menunggu grace period
+	if err := s.DeleteImage(ctx, filename, time.Now().Add(time.Second)); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	return nil
+}
+
+// SignImageURLs --> isi OriginalUrl, ThumbnailUrl dan ResizeUrl dengan signed url yang berlaku selama urlTTL.
+// Signature ResizeUrl hanya untuk path nya, client menambah query w, h dan fit sendiri
+func (s *ImageStorage) SignImageURLs(image *AppImage) {
+	expiry := time.Now().Add(s.urlTTL)
+
+	image.OriginalUrl = library.SignURL(path.Join("/v1/image/original", image.Filename), s.urlSecret, expiry)
+	image.ThumbnailUrl = library.SignURL(path.Join("/v1/image/thumbnail", image.Filename), s.urlSecret, expiry)
+	image.ResizeUrl = library.SignURL(path.Join("/v1/image", image.Filename), s.urlSecret, expiry)
+	image.UrlExpiresAt = expiry.Unix()
+}
+
+// AuthorizeRequest --> image private harus dibuka dengan signed url yang valid,
+// image public dan image lama yang belum punya record selalu boleh. Return record image,
+// nil untuk image lama. Image yang sudah dihapus return ErrBlobNotFound
+func (s *ImageStorage) AuthorizeRequest(r *http.Request, filename string) (*AppImage, error) {
+	image := &AppImage{}
+
+	if err := s.store.GetImageByFilename(filename, image); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if image.State == IMAGE_STATE_DELETING || image.State == IMAGE_STATE_DELETED {
+		return nil, ErrBlobNotFound
+	}
+
+	if !image.Private {
+		return image, nil
+	}
+
+	return image, library.ValidateSignedURL(r, s.urlSecret)
+}
+
+// DeleteImage --> hapus semua file image (original, thumbnail, rendition, cache resize) dari BlobStore.
+// Image hanya dihapus kalau refCount 0 sejak sebelum cutoff, return sql.ErrNoRows kalau tidak
+func (s *ImageStorage) DeleteImage(ctx context.Context, filename string, cutoff time.Time) error {
+	if err := validateFilename(filename); err != nil {
+		return err
+	}
+
+	if err := s.store.ClaimImageForDeletion(filename, cutoff.Unix()); err != nil {
+		return err
+	}
+
+	keys := []string{path.Join("original", filename)}
+	for _, width := range append([]int{THUMBNAIL_WIDTH}, s.renditions...) {
+		keys = append(keys, renditionKey(width, filename))
+	}
+
+	if err := s.store.ListImageVariants(filename, &keys); err != nil {
+		return fmt.Errorf("listing image variants: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := s.blobs.Delete(ctx, key); err != nil && !errors.Is(err, ErrBlobNotFound) {
+			return fmt.Errorf("deleting %s: %w", key, err)
+		}
+	}
+
+	return s.store.MarkImageDeleted(filename)
+}
+
+// GetImage --> record image dari tabel images
+func (s *ImageStorage) GetImage(filename string, image *AppImage) error {
+	return s.store.GetImageByFilename(filename, image)
+}
+
+// rendition --> resize ke width kalau image lebih besar, dan encode ulang sesuai format aslinya.
+// gif selalu di encode ulang supaya thumbnail nya hanya frame pertama
+func rendition(img image.Image, imageBytes []byte, contentType string, width int) ([]byte, string, error) {
+	resized := img.Bounds().Dx() > width
+	if resized {
+		img = resize(img, width)
+	}
+
+	if !resized && contentType != "image/gif" && contentType != "image/webp" {
+		return imageBytes, contentType, nil
+	}
+
+	return encodeImage(img, contentType)
+}
+
+// renditionKey --> thumbnail default tetap di "thumbnail/<filename>", ukuran lain di "rendition/<width>/<filename>"
+func renditionKey(width int, filename string) string {
+	if width == THUMBNAIL_WIDTH {
+		return path.Join("thumbnail", filename)
+	}
+
+	return path.Join("rendition", strconv.Itoa(width), filename)
+}
+
+// OpenResizedImage --> buka image dengan ukuran width x height. Rendition yang sudah dibuat saat upload
+// langsung dipakai, ukuran lain dibuat dari original saat pertama kali diminta lalu disimpan di "cache/".
+// width/height harus ada di resize allowlist (0 berarti mengikuti rasio) supaya client tidak bisa
+// membuat variant tanpa batas
+func (s *ImageStorage) OpenResizedImage(ctx context.Context, filename string, width, height int, fit string) (io.ReadCloser, *BlobInfo, error) {
+	if err := validateFilename(filename); err != nil {
+		return nil, nil, err
+	}
+
+	if fit == "" {
+		fit = FIT_CONTAIN
+	}
+
+	if fit != FIT_CONTAIN && fit != FIT_COVER {
+		return nil, nil, fmt.Errorf("%w: fit must be cover or contain", ErrInvalidImage)
+	}
+
+	if width == 0 && height == 0 {
+		return s.OpenImage(ctx, "original", filename)
+	}
+
+	for _, size := range []int{width, height} {
+		if size != 0 && !s.resizeAllowlist[size] {
+			return nil, nil, fmt.Errorf("%w: size %d is not allowed", ErrInvalidImage, size)
+		}
+	}
+
+	if height == 0 {
+		for _, rendition := range append([]int{THUMBNAIL_WIDTH}, s.renditions...) {
+			if rendition == width {
+				return s.blobs.Get(ctx, renditionKey(width, filename))
+			}
+		}
+	}
+
+	key := path.Join("cache", fmt.Sprintf("%dx%d-%s", width, height, fit), filename)
+
+	file, info, err := s.blobs.Get(ctx, key)
+	if err == nil || !errors.Is(err, ErrBlobNotFound) {
+		return file, info, err
+	}
+
+	original, originalInfo, err := s.OpenImage(ctx, "original", filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer original.Close()
+
+	originalBytes, err := io.ReadAll(original)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading original image: %w", err)
+	}
+
+	// original yang diupload sebelum ada batas pixel belum pernah dicek
+	config, _, err := image.DecodeConfig(bytes.NewReader(originalBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding original image config: %w", err)
+	}
+
+	if err := checkImagePixels(config.Width, config.Height); err != nil {
+		return nil, nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(originalBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding original image: %w", err)
+	}
+
+	variantBytes, variantContentType, err := encodeImage(resizeFit(img, width, height, fit), originalInfo.ContentType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding resized image: %w", err)
+	}
+
+	if err := s.blobs.Put(ctx, key, variantBytes, variantContentType); err != nil {
+		return nil, nil, fmt.Errorf("writing resized image: %w", err)
+	}
+
+	// dicatat supaya ikut terhapus saat image dihapus
+	if err := s.store.AddImageVariant(filename, key); err != nil {
+		log.Println("Error when recording image variant:", err)
+	}
+
+	info = &BlobInfo{
+		ContentType: variantContentType,
+		Size:        int64(len(variantBytes)),
+		ModTime:     time.Now(),
+		ETag:        `"` + sha256Hex(variantBytes) + `"`,
+	}
+
+	return io.NopCloser(bytes.NewReader(variantBytes)), info, nil
+}
+
+// OpenImage --> buka image berdasarkan imageType ("original" atau "thumbnail")
+func (s *ImageStorage) OpenImage(ctx context.Context, imageType, filename string) (io.ReadCloser, *BlobInfo, error) {
+	if err := validateFilename(filename); err != nil {
+		return nil, nil, err
+	}
+
+	return s.blobs.Get(ctx, path.Join(imageType, filename))
+}
+
+// checkImagePixels --> tolak image dengan dimensi kosong atau lebih dari MAX_IMAGE_PIXELS
+func checkImagePixels(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("%w: invalid image dimensions %dx%d", ErrInvalidImage, width, height)
+	}
+
+	if int64(width)*int64(height) > MAX_IMAGE_PIXELS {
+		return fmt.Errorf("%w: image dimensions %dx%d exceed %d pixels", ErrInvalidImage, width, height, MAX_IMAGE_PIXELS)
+	}
+
+	return nil
+}
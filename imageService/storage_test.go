@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pewe21/library"
+)
+
+func TestSignImageURLs(t *testing.T) {
+	storage := NewImageStorage(AppConfig{ImageURLSecret: "url-secret", SignedURLTTL: time.Hour}, nil, nil)
+
+	image := &AppImage{Filename: testHashFilename, Private: true}
+	storage.SignImageURLs(image)
+
+	valid := []string{
+		image.OriginalUrl,
+		image.ThumbnailUrl,
+		image.ResizeUrl,
+		// query resize tidak ikut di sign
+		image.ResizeUrl + "&w=64&h=64&fit=cover",
+	}
+
+	for _, target := range valid {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if err := library.ValidateSignedURL(req, "url-secret"); err != nil {
+			t.Errorf("ValidateSignedURL(%q) = %v, want nil", target, err)
+		}
+	}
+
+	invalid := []struct {
+		target string
+		want   error
+	}{
+		{"/v1/image/" + testHashFilename + "?w=64", library.ErrMissingSignature},
+		// signature original tidak berlaku untuk path resize
+		{"/v1/image/" + testHashFilename + image.OriginalUrl[len("/v1/image/original/"+testHashFilename):], library.ErrInvalidSignature},
+	}
+
+	for _, tt := range invalid {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if err := library.ValidateSignedURL(req, "url-secret"); !errors.Is(err, tt.want) {
+			t.Errorf("ValidateSignedURL(%q) = %v, want %v", tt.target, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// jumlah image yang dihapus setiap sweep
+const SWEEP_BATCH_SIZE = 100
+
+// ImageSweeper --> hapus image yang tidak dipakai post/profile manapun setelah grace period.
+// Grace period memberi waktu event post.created/user.detail.change sampai setelah upload
+type ImageSweeper struct {
+	Storage     *ImageStorage
+	Interval    time.Duration
+	GracePeriod time.Duration
+}
+
+func NewImageSweeper(storage *ImageStorage, interval, gracePeriod time.Duration) *ImageSweeper {
+	return &ImageSweeper{
+		Storage:     storage,
+		Interval:    interval,
+		GracePeriod: gracePeriod,
+	}
+}
+
+func (s *ImageSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("image sweeper stopped")
+			return
+		case <-ticker.C:
+			s.Sweep(ctx)
+		}
+	}
+}
+
+func (s *ImageSweeper) Sweep(ctx context.Context) {
+	cutoff := time.Now().Add(-s.GracePeriod)
+	filenames := []string{}
+
+	if err := s.Storage.store.ListUnreferencedImages(cutoff.Unix(), SWEEP_BATCH_SIZE, &filenames); err != nil {
+		log.Println("Error when listing unreferenced images:", err)
+		return
+	}
+
+	deleted := 0
+	for _, filename := range filenames {
+		if err := s.Storage.DeleteImage(ctx, filename, cutoff); err != nil {
+			// sudah dipakai lagi atau sedang dihapus instance lain
+			if err == sql.ErrNoRows {
+				continue
+			}
+
+			log.Println("Error when deleting unreferenced image:", filename, err)
+			continue
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		log.Println("image sweeper deleted images:", deleted)
+	}
+}
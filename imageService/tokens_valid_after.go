@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/pewe21/library"
+	"github.com/pewe21/userProto"
+)
+
+// userServiceTokensValidAfter --> library.TokensValidAfterSource lewat grpc userService
+func userServiceTokensValidAfter(client userProto.UserClient) library.TokensValidAfterFunc {
+	return func(ctx context.Context, userId string) (time.Time, error) {
+		resp, err := client.GetTokensValidAfter(ctx, &userProto.GetUserByIdReq{Id: userId})
+		if err != nil {
+			if library.FromGrpcError(err).Code == library.ERR_NOT_FOUND {
+				return time.Time{}, library.ErrUnknownTokenSubject
+			}
+			return time.Time{}, err
+		}
+
+		return time.UnixMilli(resp.GetTokensValidAfter()), nil
+	}
+}
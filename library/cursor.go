@@ -0,0 +1,50 @@
+package library
+
+import (
+	"strconv"
+	"strings"
+)
+
+const PAGE_LIMIT_DEFAULT = 10
+const PAGE_LIMIT_MAX = 50
+
+// KeysetCursor --> posisi halaman (createdAt, id). createdAt saja tidak cukup karena timestamp nya
+// per detik, row lain di detik yang sama dengan row terakhir bisa terlewat
+type KeysetCursor struct {
+	CreatedAt int64
+	Id        string
+}
+
+// ParseKeysetCursor --> format cursor "createdAt_id", cursor lama yang cuma createdAt tetap diterima
+// dengan Id kosong. Cursor kosong/tidak valid --> halaman pertama (zero value)
+func ParseKeysetCursor(raw string) KeysetCursor {
+	createdAtStr, id, _ := strings.Cut(raw, "_")
+
+	createdAt, err := strconv.ParseInt(createdAtStr, 10, 64)
+	if err != nil || createdAt < 0 {
+		return KeysetCursor{}
+	}
+
+	return KeysetCursor{CreatedAt: createdAt, Id: id}
+}
+
+func (c KeysetCursor) IsZero() bool {
+	return c.CreatedAt == 0 && c.Id == ""
+}
+
+func (c KeysetCursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(c.CreatedAt, 10) + "_" + c.Id
+}
+
+// ParsePageLimit --> limit dari query string, dibatasi 1..PAGE_LIMIT_MAX
+func ParsePageLimit(raw string) int32 {
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		return PAGE_LIMIT_DEFAULT
+	}
+
+	return int32(min(limit, PAGE_LIMIT_MAX))
+}
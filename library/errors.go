@@ -0,0 +1,210 @@
+package library
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// kode error yang stabil, client membedakan error dari code bukan dari message
+const (
+	ERR_INVALID_INPUT     = "invalid_input"
+	ERR_UNAUTHORIZED      = "unauthorized"
+	ERR_FORBIDDEN         = "forbidden"
+	ERR_ACCOUNT_SUSPENDED = "account_suspended"
+	ERR_NOT_FOUND         = "not_found"
+	ERR_CONFLICT          = "conflict"
+	ERR_USERNAME_TAKEN    = "username_taken"
+	ERR_PRECONDITION      = "failed_precondition"
+	ERR_RATE_LIMITED      = "rate_limited"
+	ERR_UNAVAILABLE       = "unavailable"
+	ERR_INTERNAL          = "internal"
+)
+
+// ERROR_DOMAIN --> domain ErrorInfo di status grpc, supaya detail dari service lain bisa dikenali
+const ERROR_DOMAIN = "gomicroservice"
+
+// AppError --> error dengan code yang stabil, status http dan code grpc nya.
+// Bisa di return dari handler http (CreateHandler) maupun handler grpc (lewat GRPCStatus)
+type AppError struct {
+	Code       string
+	Message    string
+	HttpStatus int
+	GrpcCode   codes.Code
+	Details    map[string]string
+
+	// Err --> penyebab asli, hanya untuk log dan errors.Is, tidak dikirim ke client
+	Err error
+}
+
+func NewAppError(code string, httpStatus int, grpcCode codes.Code, message string) *AppError {
+	return &AppError{
+		Code:       code,
+		Message:    message,
+		HttpStatus: httpStatus,
+		GrpcCode:   grpcCode,
+	}
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap --> copy AppError dengan penyebab asli, AppError yang dipakai bersama tidak ikut berubah
+func (e *AppError) Wrap(err error) *AppError {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// WithDetail --> copy AppError dengan tambahan detail key/value
+func (e *AppError) WithDetail(key, value string) *AppError {
+	withDetail := *e
+	withDetail.Details = map[string]string{}
+	for k, v := range e.Details {
+		withDetail.Details[k] = v
+	}
+	withDetail.Details[key] = value
+	return &withDetail
+}
+
+// GRPCStatus --> dipakai status.FromError/status.Convert, code dan details dikirim sebagai ErrorInfo
+func (e *AppError) GRPCStatus() *status.Status {
+	st := status.New(e.GrpcCode, e.Message)
+
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   e.Code,
+		Domain:   ERROR_DOMAIN,
+		Metadata: e.Details,
+	})
+	if err != nil {
+		return st
+	}
+
+	return withDetails
+}
+
+func InvalidInput(message string) *AppError {
+	return NewAppError(ERR_INVALID_INPUT, http.StatusBadRequest, codes.InvalidArgument, message)
+}
+
+func Unauthorized(message string) *AppError {
+	return NewAppError(ERR_UNAUTHORIZED, http.StatusUnauthorized, codes.Unauthenticated, message)
+}
+
+func Forbidden(message string) *AppError {
+	return NewAppError(ERR_FORBIDDEN, http.StatusForbidden, codes.PermissionDenied, message)
+}
+
+func NotFound(message string) *AppError {
+	return NewAppError(ERR_NOT_FOUND, http.StatusNotFound, codes.NotFound, message)
+}
+
+func Conflict(code, message string) *AppError {
+	return NewAppError(code, http.StatusConflict, codes.AlreadyExists, message)
+}
+
+func FailedPrecondition(message string) *AppError {
+	return NewAppError(ERR_PRECONDITION, http.StatusConflict, codes.FailedPrecondition, message)
+}
+
+// Internal --> message nya selalu "something went wrong", penyebab asli hanya masuk log
+func Internal(err error) *AppError {
+	return NewAppError(ERR_INTERNAL, http.StatusInternalServerError, codes.Internal, "something went wrong").Wrap(err)
+}
+
+var ErrUsernameTaken = Conflict(ERR_USERNAME_TAKEN, "username already used").WithDetail("field", "username")
+var ErrAccountSuspended = NewAppError(ERR_ACCOUNT_SUSPENDED, http.StatusForbidden, codes.PermissionDenied, "account suspended")
+
+// grpcCodeHttpStatus --> status http untuk code grpc dari service lain
+func grpcCodeHttpStatus(code codes.Code) (string, int) {
+	switch code {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return ERR_INVALID_INPUT, http.StatusBadRequest
+	case codes.Unauthenticated:
+		return ERR_UNAUTHORIZED, http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return ERR_FORBIDDEN, http.StatusForbidden
+	case codes.NotFound:
+		return ERR_NOT_FOUND, http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return ERR_CONFLICT, http.StatusConflict
+	case codes.FailedPrecondition:
+		return ERR_PRECONDITION, http.StatusConflict
+	case codes.ResourceExhausted:
+		return ERR_RATE_LIMITED, http.StatusTooManyRequests
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return ERR_UNAVAILABLE, http.StatusServiceUnavailable
+	default:
+		return ERR_INTERNAL, http.StatusInternalServerError
+	}
+}
+
+// FromGrpcError --> ubah error dari grpc client jadi AppError. Code dan details diambil dari ErrorInfo
+// kalau service tujuan mengirim AppError, kalau tidak code nya diturunkan dari code grpc.
+// Message error internal tidak diteruskan ke client
+func FromGrpcError(err error) *AppError {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	st := status.Convert(err)
+	code, httpStatus := grpcCodeHttpStatus(st.Code())
+
+	if code == ERR_INTERNAL || code == ERR_UNAVAILABLE {
+		return NewAppError(code, httpStatus, st.Code(), "something went wrong").Wrap(err)
+	}
+
+	fromGrpc := NewAppError(code, httpStatus, st.Code(), st.Message()).Wrap(err)
+
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.GetDomain() != ERROR_DOMAIN {
+			continue
+		}
+
+		if info.GetReason() != "" {
+			fromGrpc.Code = info.GetReason()
+		}
+		fromGrpc.Details = info.GetMetadata()
+	}
+
+	return fromGrpc
+}
+
+// statusErrorCode --> code default untuk error handler http yang bukan AppError
+func statusErrorCode(httpStatus int) string {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return ERR_INVALID_INPUT
+	case http.StatusUnauthorized:
+		return ERR_UNAUTHORIZED
+	case http.StatusForbidden:
+		return ERR_FORBIDDEN
+	case http.StatusNotFound:
+		return ERR_NOT_FOUND
+	case http.StatusConflict:
+		return ERR_CONFLICT
+	case http.StatusTooManyRequests:
+		return ERR_RATE_LIMITED
+	case http.StatusServiceUnavailable:
+		return ERR_UNAVAILABLE
+	default:
+		return ERR_INTERNAL
+	}
+}
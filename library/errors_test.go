@@ -0,0 +1,60 @@
+package library
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// overTheWire --> error seperti yang diterima grpc client, AppError aslinya sudah tidak ada
+func overTheWire(err error) error {
+	return status.FromProto(status.Convert(err).Proto()).Err()
+}
+
+func TestFromGrpcError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		code       string
+		httpStatus int
+		message    string
+	}{
+		{"not found", status.Error(codes.NotFound, "user not found"), ERR_NOT_FOUND, http.StatusNotFound, "user not found"},
+		{"invalid argument", status.Error(codes.InvalidArgument, "image too large"), ERR_INVALID_INPUT, http.StatusBadRequest, "image too large"},
+		{"failed precondition", status.Error(codes.FailedPrecondition, "already enabled"), ERR_PRECONDITION, http.StatusConflict, "already enabled"},
+		{"internal", status.Error(codes.Internal, "pq: connection refused"), ERR_INTERNAL, http.StatusInternalServerError, "something went wrong"},
+		{"unavailable", status.Error(codes.Unavailable, "connection refused"), ERR_UNAVAILABLE, http.StatusServiceUnavailable, "something went wrong"},
+		{"not a grpc error", errors.New("read: connection reset"), ERR_INTERNAL, http.StatusInternalServerError, "something went wrong"},
+		{"app error", overTheWire(ErrUsernameTaken), ERR_USERNAME_TAKEN, http.StatusConflict, "username already used"},
+		{"internal app error", overTheWire(Internal(errors.New("pq: deadlock"))), ERR_INTERNAL, http.StatusInternalServerError, "something went wrong"},
+	}
+
+	for _, tt := range tests {
+		appErr := FromGrpcError(tt.err)
+		if appErr.Code != tt.code || appErr.HttpStatus != tt.httpStatus || appErr.Message != tt.message {
+			t.Errorf("%s: FromGrpcError = %s %d %q, want %s %d %q", tt.name, appErr.Code, appErr.HttpStatus, appErr.Message, tt.code, tt.httpStatus, tt.message)
+		}
+
+		// penyebab asli tetap ada untuk log
+		if !errors.Is(appErr, tt.err) {
+			t.Errorf("%s: FromGrpcError does not wrap the original error", tt.name)
+		}
+	}
+
+	if appErr := FromGrpcError(nil); appErr != nil {
+		t.Errorf("FromGrpcError(nil) = %v, want nil", appErr)
+	}
+
+	// details dari service lain ikut diteruskan
+	if appErr := FromGrpcError(overTheWire(ErrUsernameTaken)); appErr.Details["field"] != "username" {
+		t.Errorf("Details = %v, want field username", appErr.Details)
+	}
+
+	// AppError di proses yang sama tidak diubah
+	if appErr := FromGrpcError(ErrAccountSuspended); appErr != ErrAccountSuspended {
+		t.Errorf("FromGrpcError(ErrAccountSuspended) = %v, want the same AppError", appErr)
+	}
+}
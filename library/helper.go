@@ -2,6 +2,7 @@ package library
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -23,6 +24,7 @@ func CreateHandler(f AppHandler) func(http.ResponseWriter, *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -35,12 +37,37 @@ func CreateHandler(f AppHandler) func(http.ResponseWriter, *http.Request) {
 		status, err := f(w, r)
 		if err != nil {
 			log.Println("Error:", err.Error())
-			resp := NewResp(err.Error(), nil)
-			WriteJson(w, status, resp)
+			WriteError(w, status, err)
 		}
 	}
 }
 
+// WriteError --> response error selalu {code, message, details}. Status dan code diambil dari AppError
+// kalau ada, kalau tidak code nya diturunkan dari status yang di return handler
+func WriteError(w http.ResponseWriter, status int, err error) error {
+	resp := NewResp(err.Error(), nil)
+	resp.Code = statusErrorCode(status)
+
+	var validationErr *ValidationError
+	var appErr *AppError
+
+	switch {
+	case errors.As(err, &validationErr):
+		resp.Code = ERR_INVALID_INPUT
+		resp.Message = validationErr.Error()
+		resp.Details = validationErr.Fields
+	case errors.As(err, &appErr):
+		status = appErr.HttpStatus
+		resp.Code = appErr.Code
+		resp.Message = appErr.Message
+		if len(appErr.Details) > 0 {
+			resp.Details = appErr.Details
+		}
+	}
+
+	return WriteJson(w, status, resp)
+}
+
 func WriteJson(w http.ResponseWriter, status int, data interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,323 @@
+package library
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrUnknownKid --> kid di header token tidak ada di JWKS
+var ErrUnknownKid = errors.New("unknown key id")
+
+// JWKS_CACHE_TTL --> lama JWKS disimpan sebelum diambil ulang dari authService
+const JWKS_CACHE_TTL = 5 * time.Minute
+
+// kid yang tidak dikenal memicu fetch ulang (rotasi key), paling sering sekali per interval ini
+const jwksMinRefreshInterval = 10 * time.Second
+
+// JWK --> public key di JWKS (RFC 7517), hanya RSA dan Ed25519 (OKP) yang didukung
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK --> JWK dari public key RSA/Ed25519
+func NewJWK(kid string, pub crypto.PublicKey) (JWK, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type: %T", pub)
+	}
+}
+
+// PublicKey --> public key dari JWK
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 || exponent.Int64() < 3 {
+			return nil, fmt.Errorf("invalid rsa exponent")
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+// SigningKey --> private key untuk sign token, kid dikirim di header token
+type SigningKey struct {
+	Kid string
+	Key crypto.Signer
+}
+
+// Method --> RS256 untuk key RSA, EdDSA untuk key Ed25519
+func (k *SigningKey) Method() jwt.SigningMethod {
+	if _, ok := k.Key.(*rsa.PrivateKey); ok {
+		return jwt.SigningMethodRS256
+	}
+
+	return jwt.SigningMethodEdDSA
+}
+
+// ParseSigningKeyPEM --> private key RSA (PKCS1/PKCS8) atau Ed25519 (PKCS8) dalam format PEM
+func ParseSigningKeyPEM(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return &SigningKey{Kid: kid, Key: key}, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{Kid: kid, Key: key}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{Kid: kid, Key: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %T", key)
+	}
+}
+
+// KeySource --> public key untuk verifikasi token berdasarkan kid
+type KeySource interface {
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+var (
+	keySourceMu   sync.RWMutex
+	keySource     KeySource
+	keySourceOnce sync.Once
+)
+
+// SetKeySource --> pasang KeySource yang dipakai ValidateJWT. Default nya JWKSCache dari env JWKS_URL,
+// authService memakai key nya sendiri supaya tidak fetch JWKS ke dirinya sendiri
+func SetKeySource(source KeySource) {
+	keySourceMu.Lock()
+	defer keySourceMu.Unlock()
+
+	keySource = source
+}
+
+func getKeySource() KeySource {
+	keySourceOnce.Do(func() {
+		keySourceMu.Lock()
+		defer keySourceMu.Unlock()
+
+		if keySource != nil {
+			return
+		}
+
+		jwksURL := os.Getenv("JWKS_URL")
+		if jwksURL == "" {
+			log.Println("JWKS_URL env key is missing, only legacy HS256 tokens can be validated")
+			return
+		}
+
+		keySource = NewJWKSCache(jwksURL, JWKS_CACHE_TTL)
+	})
+
+	keySourceMu.RLock()
+	defer keySourceMu.RUnlock()
+
+	return keySource
+}
+
+// JWKSCache --> KeySource yang mengambil JWKS dari authService lewat http dan menyimpannya selama ttl.
+// Kid yang belum dikenal (key baru hasil rotasi) memicu fetch ulang sebelum ttl habis.
+// Fetch dilakukan tanpa memegang lock, request lain yang butuh fetch menunggu fetch yang sedang jalan
+type JWKSCache struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	lastFetch time.Time
+	fetching  chan struct{}
+}
+
+func NewJWKSCache(url string, ttl time.Duration) *JWKSCache {
+	return &JWKSCache{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: 5 * time.Second},
+		keys:   map[string]crypto.PublicKey{},
+	}
+}
+
+func (c *JWKSCache) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+
+	key, ok := c.keys[kid]
+	fresh := time.Since(c.fetchedAt) < c.ttl
+
+	if ok && fresh {
+		c.mu.Unlock()
+		return key, nil
+	}
+
+	done := c.fetching
+	if done == nil && time.Since(c.lastFetch) >= jwksMinRefreshInterval {
+		c.lastFetch = time.Now()
+		c.fetching = make(chan struct{})
+		c.mu.Unlock()
+
+		c.refresh(ctx)
+	} else {
+		c.mu.Unlock()
+
+		// fetch lain sedang jalan --> tunggu hasilnya
+		if done != nil {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	c.mu.Lock()
+	key, ok = c.keys[kid]
+	c.mu.Unlock()
+
+	if !ok {
+		return nil, ErrUnknownKid
+	}
+
+	return key, nil
+}
+
+// refresh --> fetch JWKS lalu ganti map keys, request yang menunggu dibangunkan setelah selesai.
+// Context request tidak ikut membatalkan fetch karena hasilnya dipakai request lain
+func (c *JWKSCache) refresh(ctx context.Context) {
+	keys, err := c.fetch(context.WithoutCancel(ctx))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		// authService tidak bisa dihubungi, pakai key lama kalau ada
+		log.Println("Error when fetching JWKS:", err)
+	} else {
+		c.keys = keys
+		c.fetchedAt = c.lastFetch
+	}
+
+	close(c.fetching)
+	c.fetching = nil
+}
+
+func (c *JWKSCache) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected JWKS status: %d", res.StatusCode)
+	}
+
+	jwks := &JWKS{}
+	if err := json.NewDecoder(res.Body).Decode(jwks); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			log.Printf("Skipping JWK %s: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
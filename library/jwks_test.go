@@ -0,0 +1,92 @@
+package library
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJWKSCacheConcurrentFetch(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwk, err := NewJWK("key-1", pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(JWKS{Keys: []JWK{jwk}})
+	}))
+	defer server.Close()
+
+	cache := NewJWKSCache(server.URL, time.Hour)
+	cache.keys["cached"] = pub
+	cache.fetchedAt = time.Now()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+
+	// kid baru dari banyak request sekaligus --> hanya satu fetch
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.PublicKey(context.Background(), "key-1")
+			errs <- err
+		}()
+	}
+
+	// kid yang sudah ada di cache tidak menunggu fetch yang sedang jalan
+	for fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := cache.PublicKey(context.Background(), "cached"); err != nil {
+		t.Errorf("cached PublicKey during fetch = %v, want nil", err)
+	}
+
+	// request yang dibatalkan berhenti menunggu
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.PublicKey(ctx, "key-1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled PublicKey during fetch = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("PublicKey = %v, want nil", err)
+		}
+	}
+
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+
+	// kid tidak dikenal sebelum jwksMinRefreshInterval tidak fetch ulang
+	if _, err := cache.PublicKey(context.Background(), "unknown"); !errors.Is(err, ErrUnknownKid) {
+		t.Errorf("unknown kid = %v, want ErrUnknownKid", err)
+	}
+
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("fetches after unknown kid = %d, want 1", got)
+	}
+}
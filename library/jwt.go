@@ -1,6 +1,10 @@
 package library
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,20 +14,32 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// JWTMiddleware --> validasi access token sekali dan simpan Principal nya di request context,
+// handler ambil user yang login dengan PrincipalFrom(r.Context())
 func JWTMiddleware(f AppHandler) AppHandler {
 	return func(w http.ResponseWriter, r *http.Request) (int, error) {
-		secret := os.Getenv("JWT_SECRET")
-		log.Println(secret)
-
 		//get Authorization header
 		jwtToken := GetTokenFromRequest(r)
 
 		//validate token
-		token, err := ValidateJWT(jwtToken, secret)
-		if err != nil || !token.Valid {
+		claims, err := ValidateJWT(jwtToken)
+		if err != nil {
 			return http.StatusUnauthorized, fmt.Errorf("invalid token")
 		}
 
+		principal := claims.Principal()
+
+		// token yang dibuat sebelum user ganti password/dihapus/logout-all ditolak
+		if err := CheckTokensValidAfter(r.Context(), principal.UserId, principal.IssuedAt); err != nil {
+			if errors.Is(err, ErrTokenRevoked) || errors.Is(err, ErrUnknownTokenSubject) {
+				return http.StatusUnauthorized, fmt.Errorf("invalid token")
+			}
+			log.Println("Error when checking tokensValidAfter:", err)
+			return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		}
+
+		r = r.WithContext(WithPrincipal(r.Context(), principal))
+
 		// call appHandler func
 		if status, err := f(w, r); err != nil {
 
@@ -36,24 +52,15 @@ func JWTMiddleware(f AppHandler) AppHandler {
 
 // subject berisi userId
 //
-// issuer "gomicroservice"
-// expiration time default 12 hours, as time after the token issued + 12 hours
+// issuer "gomicroservice", audience "gomicroservice-api"
 //
 // not before: The "nbf" (not before) claim identifies the time before which the JWT
 //
 // issued At: The "iat" (issued at) claim identifies the time at which the JWT was issued.  This claim can be used to determine the age of the JWT.MUST NOT be accepted for processing
-// time.Now().Add(time.Hour * 12)
-func CreateJWT(userId, secret string, expiry time.Time) (string, error) {
-	exp := jwt.NewNumericDate(expiry)
-	nbf := jwt.NewNumericDate(time.Now())
-	iat := jwt.NewNumericDate(time.Now())
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Subject:   userId,
-		Issuer:    "gomicroservice",
-		ExpiresAt: exp,
-		NotBefore: nbf,
-		IssuedAt:  iat,
-	})
+//
+// CreateJWT --> access token HS256, hanya dipakai di legacy mode
+func CreateJWT(p *Principal, secret string, expiry time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, NewAccessClaims(p, expiry))
 
 	accessToken, err := token.SignedString([]byte(secret))
 	if err != nil {
@@ -64,27 +71,98 @@ func CreateJWT(userId, secret string, expiry time.Time) (string, error) {
 	return accessToken, nil
 }
 
-// hanya panggil fungsi ini di route yang sudah ada dijaga oleh middleware JWTMiddleware
-func GetUserIdFromJWT(r *http.Request) string {
-	secret := os.Getenv("JWT_SECRET")
-	token := GetTokenFromRequest(r)
-	jwtToken, err := ValidateJWT(token, secret)
+// CreateSignedJWT --> sama dengan CreateJWT tapi di sign dengan private key RS256/EdDSA,
+// kid key nya dikirim di header supaya service lain bisa cari public key nya di JWKS
+func CreateSignedJWT(p *Principal, key *SigningKey, expiry time.Time) (string, error) {
+	token := jwt.NewWithClaims(key.Method(), NewAccessClaims(p, expiry))
+	token.Header["kid"] = key.Kid
 
+	accessToken, err := token.SignedString(key.Key)
 	if err != nil {
-		return ""
+		log.Printf("Error when signing accessToken %+v", err.Error())
+		return "", err
 	}
 
-	return jwtToken.Claims.(jwt.MapClaims)["sub"].(string)
+	return accessToken, nil
 }
 
-func ValidateJWT(token, secret string) (*jwt.Token, error) {
+// JWTLegacyHS256Enabled --> JWT_LEGACY_HS256=true, token HS256 dengan JWT_SECRET masih diterima
+// (dan dibuat authService) selama migrasi ke RS256/EdDSA
+func JWTLegacyHS256Enabled() bool {
+	return os.Getenv("JWT_LEGACY_HS256") == "true"
+}
+
+// ValidateJWT --> token RS256/EdDSA diverifikasi dengan public key kid nya dari KeySource (JWKS),
+// token HS256 hanya diterima di legacy mode. iss, aud dan sub juga harus valid
+func ValidateJWT(token string) (*AccessClaims, error) {
+	methods := []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+	if JWTLegacyHS256Enabled() {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	claims := &AccessClaims{}
+
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			secret := os.Getenv("JWT_SECRET")
+			if secret == "" {
+				return nil, fmt.Errorf("JWT_SECRET is not set")
+			}
+			return []byte(secret), nil
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("missing kid")
+		}
 
-	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		source := getKeySource()
+		if source == nil {
+			return nil, ErrUnknownKid
+		}
+
+		key, err := source.PublicKey(context.Background(), kid)
+		if err != nil {
+			return nil, err
+		}
+
+		// key harus cocok dengan alg di header, supaya token tidak bisa memilih cara verifikasi nya sendiri
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA:
+			if _, ok := key.(*rsa.PublicKey); !ok {
+				return nil, fmt.Errorf("key %s is not an rsa key", kid)
+			}
+		case *jwt.SigningMethodEd25519:
+			if _, ok := key.(ed25519.PublicKey); !ok {
+				return nil, fmt.Errorf("key %s is not an ed25519 key", kid)
+			}
+		default:
 			return nil, fmt.Errorf("unexpected signing method: %+v", t.Header["alg"])
 		}
-		return []byte(secret), nil
-	})
+
+		return key, nil
+	}, jwt.WithValidMethods(methods))
+	if err != nil {
+		return nil, err
+	}
+
+	if !parsed.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if !claims.VerifyIssuer(JWT_ISSUER, true) {
+		return nil, fmt.Errorf("invalid issuer")
+	}
+
+	if !claims.VerifyAudience(JWT_AUDIENCE, true) {
+		return nil, fmt.Errorf("invalid audience")
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("missing subject")
+	}
+
+	return claims, nil
 }
 
 func GetTokenFromRequest(r *http.Request) string {
@@ -0,0 +1,39 @@
+package library
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const PG_UNIQUE_VIOLATION = "23505"
+const PG_FOREIGN_KEY_VIOLATION = "23503"
+
+func IsNotFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
+
+// IsUniqueViolation --> insert/update ditolak karena constraint UNIQUE atau PRIMARY KEY
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == PG_UNIQUE_VIOLATION
+}
+
+// ClassifyPgError --> ubah error postgres (err tidak nil) jadi AppError. sql.ErrNoRows jadi notFound,
+// unique violation jadi conflict, selain itu Internal. Error asli tetap bisa dicek dengan errors.Is
+func ClassifyPgError(err error, notFound, conflict *AppError) *AppError {
+	var appErr *AppError
+
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case IsNotFound(err) && notFound != nil:
+		return notFound.Wrap(err)
+	case IsUniqueViolation(err) && conflict != nil:
+		return conflict.Wrap(err)
+	default:
+		return Internal(err)
+	}
+}
@@ -0,0 +1,114 @@
+package library
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// iss dan aud access token, token dengan iss/aud lain ditolak JWTMiddleware
+const JWT_ISSUER = "gomicroservice"
+const JWT_AUDIENCE = "gomicroservice-api"
+
+// iat/nbf/exp presisi milidetik supaya token yang dibuat di detik yang sama setelah revoke
+// (login ulang setelah ganti password/logout-all) tidak ikut ditolak CheckTokensValidAfter
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
+// Principal --> user yang sedang login, diisi JWTMiddleware dari claim access token
+type Principal struct {
+	UserId    string
+	Username  string
+	Roles     []string
+	TokenId   string
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// AccessClaims --> claim access token, scope dipisah spasi (RFC 8693)
+type AccessClaims struct {
+	Username string   `json:"username,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+	Scope    string   `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// NewAccessClaims --> claim access token untuk principal, jti dibuat random kalau TokenId kosong
+func NewAccessClaims(p *Principal, expiry time.Time) *AccessClaims {
+	tokenId := p.TokenId
+	if tokenId == "" {
+		tokenId = newTokenId()
+	}
+
+	now := time.Now()
+
+	return &AccessClaims{
+		Username: p.Username,
+		Roles:    p.Roles,
+		Scope:    strings.Join(p.Scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenId,
+			Subject:   p.UserId,
+			Issuer:    JWT_ISSUER,
+			Audience:  jwt.ClaimStrings{JWT_AUDIENCE},
+			ExpiresAt: jwt.NewNumericDate(expiry),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+// Principal --> principal dari claim yang sudah divalidasi
+func (c *AccessClaims) Principal() *Principal {
+	p := &Principal{
+		UserId:   c.Subject,
+		Username: c.Username,
+		Roles:    c.Roles,
+		TokenId:  c.ID,
+		Scopes:   strings.Fields(c.Scope),
+	}
+
+	if c.IssuedAt != nil {
+		p.IssuedAt = c.IssuedAt.Time
+	}
+
+	if c.ExpiresAt != nil {
+		p.ExpiresAt = c.ExpiresAt.Time
+	}
+
+	return p
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom --> principal yang diisi JWTMiddleware, ok false kalau route tidak dijaga JWTMiddleware
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+func newTokenId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
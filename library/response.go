@@ -3,6 +3,10 @@ package library
 type AppResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
+
+	// Code/Details --> hanya diisi untuk response error, lihat WriteError
+	Code    string      `json:"code,omitempty"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 func NewResp(message string, data interface{}) *AppResponse {
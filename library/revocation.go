@@ -0,0 +1,160 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked --> token dibuat sebelum tokensValidAfter user (ganti password, hapus akun, logout-all)
+var ErrTokenRevoked = errors.New("token revoked")
+
+// ErrUnknownTokenSubject --> user di claim sub tidak ada, token nya ditolak
+var ErrUnknownTokenSubject = errors.New("unknown token subject")
+
+// TOKENS_VALID_AFTER_CACHE_TTL --> lama tokensValidAfter disimpan di cache lokal,
+// revoke baru berlaku di service lain paling lambat setelah TTL ini
+const TOKENS_VALID_AFTER_CACHE_TTL = 30 * time.Second
+
+// batas jumlah entry cache, kalau penuh entry expired dibersihkan lalu entry paling lama dibuang
+const tokensValidAfterCacheSize = 10000
+
+// TokensValidAfterSource --> sumber tokensValidAfter per user (waktu revoke, presisi milidetik),
+// token dengan iat sebelum waktu ini ditolak. User yang belum pernah revoke return zero time,
+// user yang tidak ada return ErrUnknownTokenSubject
+type TokensValidAfterSource interface {
+	TokensValidAfter(ctx context.Context, userId string) (time.Time, error)
+}
+
+// TokensValidAfterFunc --> adapter fungsi biasa jadi TokensValidAfterSource
+type TokensValidAfterFunc func(ctx context.Context, userId string) (time.Time, error)
+
+func (f TokensValidAfterFunc) TokensValidAfter(ctx context.Context, userId string) (time.Time, error) {
+	return f(ctx, userId)
+}
+
+var (
+	tokensValidAfterMu     sync.RWMutex
+	tokensValidAfterSource TokensValidAfterSource
+)
+
+// SetTokensValidAfterSource --> pasang source yang dipakai JWTMiddleware.
+// Kalau tidak dipasang, middleware hanya cek signature dan expiry
+func SetTokensValidAfterSource(source TokensValidAfterSource) {
+	tokensValidAfterMu.Lock()
+	defer tokensValidAfterMu.Unlock()
+
+	tokensValidAfterSource = source
+}
+
+// CheckTokensValidAfter --> return ErrTokenRevoked kalau token user dibuat sebelum tokensValidAfter nya.
+// Token lama dengan iat presisi detik tetap dibandingkan dengan benar
+func CheckTokensValidAfter(ctx context.Context, userId string, issuedAt time.Time) error {
+	tokensValidAfterMu.RLock()
+	source := tokensValidAfterSource
+	tokensValidAfterMu.RUnlock()
+
+	if source == nil {
+		return nil
+	}
+
+	validAfter, err := source.TokensValidAfter(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if issuedAt.Before(validAfter) {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// TokensValidAfterCache --> cache lokal di depan TokensValidAfterSource supaya tidak setiap request
+// memanggil userService. Kalau source error, nilai lama di cache tetap dipakai
+type TokensValidAfterCache struct {
+	source TokensValidAfterSource
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]tokensValidAfterEntry
+}
+
+type tokensValidAfterEntry struct {
+	validAfter time.Time
+	fetchedAt  time.Time
+}
+
+func NewTokensValidAfterCache(source TokensValidAfterSource, ttl time.Duration) *TokensValidAfterCache {
+	return &TokensValidAfterCache{
+		source:  source,
+		ttl:     ttl,
+		entries: map[string]tokensValidAfterEntry{},
+	}
+}
+
+func (c *TokensValidAfterCache) TokensValidAfter(ctx context.Context, userId string) (time.Time, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[userId]
+	c.mu.Unlock()
+
+	if ok && now.Sub(entry.fetchedAt) < c.ttl {
+		return entry.validAfter, nil
+	}
+
+	validAfter, err := c.source.TokensValidAfter(ctx, userId)
+	if err != nil {
+		if ok && !errors.Is(err, ErrUnknownTokenSubject) {
+			log.Println("Error when fetching tokensValidAfter, using cached value:", err)
+			return entry.validAfter, nil
+		}
+		return time.Time{}, err
+	}
+
+	c.Set(userId, validAfter)
+
+	return validAfter, nil
+}
+
+// Set --> update cache langsung, dipakai service yang baru saja revoke token user
+func (c *TokensValidAfterCache) Set(userId string, validAfter time.Time) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[userId]; !ok && len(c.entries) >= tokensValidAfterCacheSize {
+		c.evict(now)
+	}
+
+	c.entries[userId] = tokensValidAfterEntry{
+		validAfter: validAfter,
+		fetchedAt:  now,
+	}
+}
+
+// evict --> buang entry expired, kalau semua masih berlaku buang entry yang paling lama di fetch
+// supaya ukuran cache tidak lebih dari tokensValidAfterCacheSize
+func (c *TokensValidAfterCache) evict(now time.Time) {
+	var oldestId string
+	var oldest time.Time
+
+	for id, entry := range c.entries {
+		if now.Sub(entry.fetchedAt) >= c.ttl {
+			delete(c.entries, id)
+			continue
+		}
+
+		if oldestId == "" || entry.fetchedAt.Before(oldest) {
+			oldestId, oldest = id, entry.fetchedAt
+		}
+	}
+
+	if len(c.entries) >= tokensValidAfterCacheSize {
+		delete(c.entries, oldestId)
+	}
+}
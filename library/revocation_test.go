@@ -0,0 +1,109 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCheckTokensValidAfter(t *testing.T) {
+	revokedAt := time.UnixMilli(1700000000500)
+
+	SetTokensValidAfterSource(TokensValidAfterFunc(func(ctx context.Context, userId string) (time.Time, error) {
+		if userId == "unknown" {
+			return time.Time{}, ErrUnknownTokenSubject
+		}
+		return revokedAt, nil
+	}))
+	t.Cleanup(func() { SetTokensValidAfterSource(nil) })
+
+	tests := []struct {
+		name     string
+		userId   string
+		issuedAt time.Time
+		want     error
+	}{
+		{"before revoke", "user-1", revokedAt.Add(-time.Second), ErrTokenRevoked},
+		{"same second before revoke", "user-1", revokedAt.Add(-time.Millisecond), ErrTokenRevoked},
+		{"legacy second iat before revoke", "user-1", time.Unix(1700000000, 0), ErrTokenRevoked},
+		{"at revoke", "user-1", revokedAt, nil},
+		{"same second after revoke", "user-1", revokedAt.Add(time.Millisecond), nil},
+		{"after revoke", "user-1", revokedAt.Add(time.Second), nil},
+		{"unknown user", "unknown", revokedAt.Add(time.Hour), ErrUnknownTokenSubject},
+	}
+
+	for _, tt := range tests {
+		if err := CheckTokensValidAfter(context.Background(), tt.userId, tt.issuedAt); !errors.Is(err, tt.want) {
+			t.Errorf("%s: CheckTokensValidAfter = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestTokensValidAfterCache(t *testing.T) {
+	calls := 0
+	fail := false
+	source := TokensValidAfterFunc(func(ctx context.Context, userId string) (time.Time, error) {
+		calls++
+		if fail {
+			return time.Time{}, errors.New("user service down")
+		}
+		return time.Unix(int64(calls), 0), nil
+	})
+
+	cache := NewTokensValidAfterCache(source, time.Hour)
+	ctx := context.Background()
+
+	first, err := cache.TokensValidAfter(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached, _ := cache.TokensValidAfter(ctx, "user-1"); !cached.Equal(first) || calls != 1 {
+		t.Errorf("cached TokensValidAfter = %v after %d calls, want %v after 1 call", cached, calls, first)
+	}
+
+	// nilai yang di Set langsung dipakai tanpa memanggil source
+	cache.Set("user-1", time.Unix(100, 0))
+	if got, _ := cache.TokensValidAfter(ctx, "user-1"); got.Unix() != 100 || calls != 1 {
+		t.Errorf("TokensValidAfter after Set = %v, want 100", got.Unix())
+	}
+
+	// source error --> nilai lama tetap dipakai
+	expired := NewTokensValidAfterCache(source, 0)
+	expired.Set("user-1", time.Unix(200, 0))
+	fail = true
+
+	if got, err := expired.TokensValidAfter(ctx, "user-1"); err != nil || got.Unix() != 200 {
+		t.Errorf("TokensValidAfter with failing source = %v, %v, want 200, nil", got.Unix(), err)
+	}
+
+	if _, err := expired.TokensValidAfter(ctx, "user-2"); err == nil {
+		t.Errorf("TokensValidAfter uncached with failing source error = nil, want error")
+	}
+}
+
+func TestTokensValidAfterCacheSize(t *testing.T) {
+	cache := NewTokensValidAfterCache(nil, time.Hour)
+
+	for i := 0; i < tokensValidAfterCacheSize+100; i++ {
+		cache.Set("user-"+strconv.Itoa(i), time.Unix(int64(i), 0))
+	}
+
+	if len(cache.entries) != tokensValidAfterCacheSize {
+		t.Errorf("cache size = %d, want %d", len(cache.entries), tokensValidAfterCacheSize)
+	}
+
+	// entry terbaru selalu ada
+	last := "user-" + strconv.Itoa(tokensValidAfterCacheSize+99)
+	if _, ok := cache.entries[last]; !ok {
+		t.Errorf("latest entry %s was evicted", last)
+	}
+
+	// update entry yang sudah ada tidak membuang entry lain
+	cache.Set(last, time.Unix(1, 0))
+	if len(cache.entries) != tokensValidAfterCacheSize {
+		t.Errorf("cache size after update = %d, want %d", len(cache.entries), tokensValidAfterCacheSize)
+	}
+}
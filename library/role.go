@@ -0,0 +1,90 @@
+package library
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// role user disimpan di kolom users.role dan dibawa di claim roles access token
+const ROLE_USER = "user"
+const ROLE_ADMIN = "admin"
+
+// RequireRole --> tolak request kalau principal tidak punya role, dipasang didalam JWTMiddleware:
+//
+//	library.JWTMiddleware(library.RequireRole(library.ROLE_ADMIN, s.handleX))
+func RequireRole(role string, f AppHandler) AppHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		principal, ok := PrincipalFrom(r.Context())
+		if !ok {
+			return http.StatusUnauthorized, fmt.Errorf("invalid token")
+		}
+
+		if !principal.HasRole(role) {
+			return http.StatusForbidden, fmt.Errorf("Forbidden")
+		}
+
+		return f(w, r)
+	}
+}
+
+// ModerationLog --> satu entry audit log untuk setiap aksi admin, tiap service simpan di database nya sendiri
+type ModerationLog struct {
+	Id         string `json:"id"`
+	ActorId    string `json:"actorId"`
+	Action     string `json:"action"`
+	TargetType string `json:"targetType"`
+	TargetId   string `json:"targetId"`
+	Reason     string `json:"reason"`
+
+	CreatedAt int64 `json:"createdAt"`
+}
+
+const MODERATION_DELETE_POST = "post.delete"
+const MODERATION_SUSPEND_USER = "user.suspend"
+const MODERATION_RESTORE_USER = "user.restore"
+
+// panjang maksimal alasan moderasi
+const MODERATION_REASON_MAX_LENGTH = 500
+
+// MODERATION_BODY_MAX_BYTES --> ukuran maksimal body request moderasi, cukup untuk alasan
+// MODERATION_REASON_MAX_LENGTH karakter yang di escape \uXXXX
+const MODERATION_BODY_MAX_BYTES = 8 * 1024
+
+// ReadModerationReason --> baca {"reason": "..."} dari body request admin, body kosong berarti tanpa alasan
+func ReadModerationReason(r *http.Request) (string, error) {
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, MODERATION_BODY_MAX_BYTES+1))
+	if err != nil {
+		return "", err
+	}
+
+	if len(body) > MODERATION_BODY_MAX_BYTES {
+		return "", fmt.Errorf("request body is too large")
+	}
+
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return "", nil
+	}
+
+	req := struct {
+		Reason string `json:"reason"`
+	}{}
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", fmt.Errorf("invalid moderation reason")
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+
+	v := NewValidator()
+	v.Field("reason", reason, ModerationReasonRules()...)
+	if err := v.Err(); err != nil {
+		return "", err
+	}
+
+	return reason, nil
+}
@@ -0,0 +1,47 @@
+package library
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadModerationReason(t *testing.T) {
+	valid := map[string]string{
+		``:                             "",
+		`   `:                          "",
+		`{}`:                           "",
+		`{"reason": " spam "}`:         "spam",
+		`{"reason": "line 1\nline 2"}`: "line 1\nline 2",
+		`{"reason": "` + strings.Repeat("é", MODERATION_REASON_MAX_LENGTH) + `"}`: strings.Repeat("é", MODERATION_REASON_MAX_LENGTH),
+	}
+
+	for body, want := range valid {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		if reason, err := ReadModerationReason(r); err != nil || reason != want {
+			t.Errorf("ReadModerationReason(%.20q) = %.20q, %v, want %.20q", body, reason, err, want)
+		}
+	}
+
+	invalid := map[string]string{
+		"not json":       `reason`,
+		"too long":       `{"reason": "` + strings.Repeat("x", MODERATION_REASON_MAX_LENGTH+1) + `"}`,
+		"control":        `{"reason": "spam\u001b[31m"}`,
+		"body too large": `{"reason": "spam"}` + strings.Repeat(" ", MODERATION_BODY_MAX_BYTES),
+	}
+
+	for name, body := range invalid {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		if _, err := ReadModerationReason(r); err == nil {
+			t.Errorf("%s: ReadModerationReason error = nil, want error", name)
+		}
+	}
+
+	// reason yang terlalu panjang dikirim sebagai field error
+	r := httptest.NewRequest("POST", "/", strings.NewReader(invalid["too long"]))
+	var validationErr *ValidationError
+	if _, err := ReadModerationReason(r); !errors.As(err, &validationErr) || validationErr.Fields[0].Field != "reason" {
+		t.Errorf("ReadModerationReason(too long) = %v, want reason field error", err)
+	}
+}
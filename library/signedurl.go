@@ -0,0 +1,66 @@
+package library
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpiredSignature = errors.New("signature expired")
+)
+
+// SignURL --> tambah query expires & signature ke path, signature nya HMAC-SHA256 dari
+// path dan expires dengan secret. Query lain tidak ikut di sign
+//
+// /v1/image/original/a.jpg --> /v1/image/original/a.jpg?expires=1700000000&signature=...
+func SignURL(path, secret string, expiry time.Time) string {
+	expires := strconv.FormatInt(expiry.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", urlSignature(path, expires, secret))
+
+	return path + "?" + query.Encode()
+}
+
+// ValidateSignedURL --> cek signature dan expires dari request yang dibuat dengan SignURL
+func ValidateSignedURL(r *http.Request, secret string) error {
+	query := r.URL.Query()
+	expires := query.Get("expires")
+	signature := query.Get("signature")
+
+	if expires == "" || signature == "" {
+		return ErrMissingSignature
+	}
+
+	expected := urlSignature(r.URL.Path, expires, secret)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return ErrExpiredSignature
+	}
+
+	return nil
+}
+
+func urlSignature(path, expires, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(path + "\n" + expires))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,237 @@
+package library
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const USERNAME_MIN_LENGTH = 3
+const USERNAME_MAX_LENGTH = 30
+
+const NAME_MAX_LENGTH = 50
+
+// bcrypt hanya memakai 72 byte pertama password
+const PASSWORD_MIN_LENGTH = 8
+const PASSWORD_MAX_BYTES = 72
+
+const POST_BODY_MAX_LENGTH = 500
+
+// username hanya huruf, angka dan underscore, diawali huruf
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// RESERVED_USERNAMES --> tidak bisa dipakai user, supaya tidak bisa menyamar jadi akun sistem/route
+var RESERVED_USERNAMES = []string{
+	"admin", "administrator", "root", "system", "support", "moderator",
+	"api", "auth", "login", "logout", "register", "me", "settings", "user", "username",
+}
+
+// password yang terlalu umum ditolak walaupun panjang dan karakternya sudah memenuhi policy
+var commonPasswords = []string{
+	"password", "password1", "password123", "12345678", "123456789", "1234567890",
+	"qwerty123", "qwertyuiop", "iloveyou1", "abc12345", "admin123", "welcome1",
+}
+
+// FieldError --> error validasi satu field, field nya nama field di request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError --> dikembalikan handler dengan status 400, CreateHandler mengirim
+// field error nya di details dengan code invalid_input
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid input"
+}
+
+// Rule --> satu aturan validasi, return pesan error atau "" kalau value valid
+type Rule func(value string) string
+
+// Validator --> kumpulkan error semua field lalu ambil hasilnya dengan Err:
+//
+//	v := library.NewValidator()
+//	v.Field("username", user.Username, library.UsernameRules()...)
+//	if err := v.Err(); err != nil {
+//		return http.StatusBadRequest, err
+//	}
+type Validator struct {
+	fields []FieldError
+}
+
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+// Field --> jalankan rule berurutan, hanya error pertama tiap field yang dicatat
+func (v *Validator) Field(field, value string, rules ...Rule) *Validator {
+	for _, rule := range rules {
+		if message := rule(value); message != "" {
+			v.fields = append(v.fields, FieldError{Field: field, Message: message})
+			break
+		}
+	}
+
+	return v
+}
+
+// Err --> nil kalau semua field valid, *ValidationError kalau ada yang tidak valid
+func (v *Validator) Err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Fields: v.fields}
+}
+
+func Required() Rule {
+	return func(value string) string {
+		if strings.TrimSpace(value) == "" {
+			return "is required"
+		}
+		return ""
+	}
+}
+
+// MinLength/MaxLength --> panjang dalam karakter (rune), bukan byte
+func MinLength(n int) Rule {
+	return func(value string) string {
+		if utf8.RuneCountInString(value) < n {
+			return fmt.Sprintf("must be at least %d characters", n)
+		}
+		return ""
+	}
+}
+
+func MaxLength(n int) Rule {
+	return func(value string) string {
+		if utf8.RuneCountInString(value) > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+		return ""
+	}
+}
+
+func MaxBytes(n int) Rule {
+	return func(value string) string {
+		if len(value) > n {
+			return fmt.Sprintf("must be at most %d bytes", n)
+		}
+		return ""
+	}
+}
+
+// Matches --> value harus cocok dengan pattern, message dipakai kalau tidak cocok
+func Matches(pattern *regexp.Regexp, message string) Rule {
+	return func(value string) string {
+		if !pattern.MatchString(value) {
+			return message
+		}
+		return ""
+	}
+}
+
+// NotIn --> value tidak boleh salah satu dari values, huruf besar/kecil diabaikan
+func NotIn(values []string, message string) Rule {
+	return func(value string) string {
+		if slices.Contains(values, strings.ToLower(value)) {
+			return message
+		}
+		return ""
+	}
+}
+
+// Printable --> tolak karakter kontrol (newline dibolehkan kalau allowNewline)
+func Printable(allowNewline bool) Rule {
+	return func(value string) string {
+		for _, r := range value {
+			if allowNewline && (r == '\n' || r == '\r' || r == '\t') {
+				continue
+			}
+			if unicode.IsControl(r) {
+				return "contains invalid characters"
+			}
+		}
+		return ""
+	}
+}
+
+// StrongPassword --> minimal ada huruf dan angka, bukan password umum dan tidak mengandung username
+func StrongPassword(username string) Rule {
+	return func(value string) string {
+		var hasLetter, hasDigit bool
+		for _, r := range value {
+			switch {
+			case unicode.IsLetter(r):
+				hasLetter = true
+			case unicode.IsDigit(r):
+				hasDigit = true
+			}
+		}
+
+		if !hasLetter || !hasDigit {
+			return "must contain at least one letter and one number"
+		}
+
+		if slices.Contains(commonPasswords, strings.ToLower(value)) {
+			return "is too common"
+		}
+
+		if username != "" && strings.Contains(strings.ToLower(value), strings.ToLower(username)) {
+			return "must not contain the username"
+		}
+
+		return ""
+	}
+}
+
+func UsernameRules() []Rule {
+	return []Rule{
+		Required(),
+		MinLength(USERNAME_MIN_LENGTH),
+		MaxLength(USERNAME_MAX_LENGTH),
+		Matches(usernamePattern, "must start with a letter and contain only letters, numbers and underscores"),
+		NotIn(RESERVED_USERNAMES, "is reserved"),
+	}
+}
+
+func NameRules() []Rule {
+	return []Rule{
+		Required(),
+		MaxLength(NAME_MAX_LENGTH),
+		Printable(false),
+	}
+}
+
+// PasswordRules --> policy password baru (register/ganti password), login tidak memakai ini
+// supaya password lama yang dibuat sebelum policy tetap bisa dipakai
+func PasswordRules(username string) []Rule {
+	return []Rule{
+		Required(),
+		MinLength(PASSWORD_MIN_LENGTH),
+		MaxBytes(PASSWORD_MAX_BYTES),
+		StrongPassword(username),
+	}
+}
+
+// ModerationReasonRules --> alasan moderasi boleh kosong
+func ModerationReasonRules() []Rule {
+	return []Rule{
+		MaxLength(MODERATION_REASON_MAX_LENGTH),
+		Printable(true),
+	}
+}
+
+func PostBodyRules() []Rule {
+	return []Rule{
+		Required(),
+		MaxLength(POST_BODY_MAX_LENGTH),
+		Printable(true),
+	}
+}
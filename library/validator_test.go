@@ -0,0 +1,146 @@
+package library
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// validate --> pesan error pertama dari rules, "" kalau valid
+func validate(value string, rules ...Rule) string {
+	err := NewValidator().Field("field", value, rules...).Err()
+	if err == nil {
+		return ""
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 {
+		return "unexpected error: " + err.Error()
+	}
+
+	return validationErr.Fields[0].Message
+}
+
+func TestUsernameRules(t *testing.T) {
+	valid := []string{"bob", "alice_01", "A23", "abcdefghijklmnopqrstuvwxyz0123", "Admins"}
+
+	for _, username := range valid {
+		if message := validate(username, UsernameRules()...); message != "" {
+			t.Errorf("username %q = %q, want valid", username, message)
+		}
+	}
+
+	invalid := map[string]string{
+		"":                                "is required",
+		"   ":                             "is required",
+		"ab":                              "must be at least 3 characters",
+		"abcdefghijklmnopqrstuvwxyz01234": "must be at most 30 characters",
+		"1bob":                            "must start with a letter and contain only letters, numbers and underscores",
+		"_bob":                            "must start with a letter and contain only letters, numbers and underscores",
+		"bob smith":                       "must start with a letter and contain only letters, numbers and underscores",
+		"bob-smith":                       "must start with a letter and contain only letters, numbers and underscores",
+		"bób":                             "must start with a letter and contain only letters, numbers and underscores",
+		"admin":                           "is reserved",
+		"Admin":                           "is reserved",
+		"ROOT":                            "is reserved",
+	}
+
+	for username, want := range invalid {
+		if message := validate(username, UsernameRules()...); message != want {
+			t.Errorf("username %q = %q, want %q", username, message, want)
+		}
+	}
+}
+
+func TestPasswordRules(t *testing.T) {
+	valid := []string{"correct horse 1", "s3cretpass", "pässwörd9", strings.Repeat("a1", 36)}
+
+	for _, password := range valid {
+		if message := validate(password, PasswordRules("bob")...); message != "" {
+			t.Errorf("password %q = %q, want valid", password, message)
+		}
+	}
+
+	invalid := map[string]string{
+		"":                       "is required",
+		"abc123":                 "must be at least 8 characters",
+		strings.Repeat("a1", 37): "must be at most 72 bytes",
+		// 50 karakter tapi 75 byte
+		strings.Repeat("é1", 25): "must be at most 72 bytes",
+		"onlyletters":            "must contain at least one letter and one number",
+		"1234567890123":          "must contain at least one letter and one number",
+		"Password123":            "is too common",
+		"QWERTY123":              "is too common",
+		"myBOBpassword1":         "must not contain the username",
+	}
+
+	for password, want := range invalid {
+		if message := validate(password, PasswordRules("bob")...); message != want {
+			t.Errorf("password %q = %q, want %q", password, message, want)
+		}
+	}
+
+	// tanpa username (ganti password tanpa tahu username) rule username dilewati
+	if message := validate("bobpassword1", PasswordRules("")...); message != "" {
+		t.Errorf("password without username = %q, want valid", message)
+	}
+}
+
+func TestNameAndPostBodyRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		rules []Rule
+		want  string
+	}{
+		{"name", "Bob Smith", NameRules(), ""},
+		{"name unicode", strings.Repeat("é", NAME_MAX_LENGTH), NameRules(), ""},
+		{"name too long", strings.Repeat("é", NAME_MAX_LENGTH+1), NameRules(), "must be at most 50 characters"},
+		{"name newline", "Bob\nSmith", NameRules(), "contains invalid characters"},
+		{"name null byte", "Bob\x00", NameRules(), "contains invalid characters"},
+		{"name blank", " \t ", NameRules(), "is required"},
+		{"body", "hello\nworld\t!", PostBodyRules(), ""},
+		{"body max", strings.Repeat("x", POST_BODY_MAX_LENGTH), PostBodyRules(), ""},
+		{"body too long", strings.Repeat("x", POST_BODY_MAX_LENGTH+1), PostBodyRules(), "must be at most 500 characters"},
+		{"body control", "hello\x1b[31m", PostBodyRules(), "contains invalid characters"},
+		{"body blank", "\n\n", PostBodyRules(), "is required"},
+	}
+
+	for _, tt := range tests {
+		if message := validate(tt.value, tt.rules...); message != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, message, tt.want)
+		}
+	}
+}
+
+func TestValidatorCollectsFirstErrorPerField(t *testing.T) {
+	err := NewValidator().
+		Field("username", "", UsernameRules()...).
+		Field("name", "Bob", NameRules()...).
+		Field("password", "short", PasswordRules("")...).
+		Err()
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Err() = %v, want *ValidationError", err)
+	}
+
+	want := []FieldError{
+		{Field: "username", Message: "is required"},
+		{Field: "password", Message: "must be at least 8 characters"},
+	}
+
+	if len(validationErr.Fields) != len(want) {
+		t.Fatalf("Fields = %+v, want %+v", validationErr.Fields, want)
+	}
+
+	for i := range want {
+		if validationErr.Fields[i] != want[i] {
+			t.Errorf("Fields[%d] = %+v, want %+v", i, validationErr.Fields[i], want[i])
+		}
+	}
+
+	if err := NewValidator().Field("name", "Bob", NameRules()...).Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
@@ -3,12 +3,17 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
 )
 
 type AppConfig struct {
 	UserServiceHostName  string
 	ImageServiceHostName string
 	RabbitMQHostname     string
+
+	// user dengan follower lebih dari FanoutThreshold tidak di fan-out ke timeline follower,
+	// post nya diambil langsung saat feed dibaca (fan-out-on-read)
+	FanoutThreshold int64
 }
 
 func InitConfig() AppConfig {
@@ -31,9 +36,16 @@ func InitConfig() AppConfig {
 		rabbitMQHostname = "localhost"
 	}
 
+	fanoutThreshold, err := strconv.ParseInt(os.Getenv("FANOUT_THRESHOLD"), 10, 64)
+	if err != nil || fanoutThreshold < 0 {
+		log.Println("FANOUT_THRESHOLD is not found/invalid, fallback to 1000")
+		fanoutThreshold = 1000
+	}
+
 	return AppConfig{
 		UserServiceHostName:  userServiceHostName,
 		ImageServiceHostName: imageServiceHostName,
 		RabbitMQHostname:     rabbitMQHostname,
+		FanoutThreshold:      fanoutThreshold,
 	}
 }
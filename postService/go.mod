@@ -9,6 +9,7 @@ replace github.com/pewe21/userProto => ../userProto
 replace github.com/pewe21/imageProto => ../imageProto
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
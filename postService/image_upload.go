@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+
+	"github.com/pewe21/imageProto"
+	"github.com/pewe21/library"
+)
+
+// ukuran chunk yang dikirim ke imageService, jauh dibawah batas 4MB message grpc
+const IMAGE_UPLOAD_CHUNK_SIZE = 64 * 1024
+
+// uploadImage --> stream file multipart ke imageService lewat UploadImage grpc
+// tanpa membaca seluruh file ke memory, return nama file yang disimpan imageService.
+// idUser dicatat sebagai pemilik image
+func uploadImage(ctx context.Context, client imageProto.UserClient, idUser string, file multipart.File, handler *multipart.FileHeader) (string, error) {
+	stream, err := client.UploadImage(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	header := &imageProto.UploadImageReq{
+		Data: &imageProto.UploadImageReq_Header{
+			Header: &imageProto.UploadImageHeader{
+				Filename:    handler.Filename,
+				Size:        handler.Size,
+				ContentType: handler.Header.Get("Content-Type"),
+				IdUser:      idUser,
+			},
+		},
+	}
+
+	if err := stream.Send(header); err != nil {
+		// error sebenarnya didapat dari CloseAndRecv
+		_, err = stream.CloseAndRecv()
+		return "", err
+	}
+
+	buf := make([]byte, IMAGE_UPLOAD_CHUNK_SIZE)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			chunk := &imageProto.UploadImageReq{
+				Data: &imageProto.UploadImageReq_Chunk{
+					Chunk: buf[:n],
+				},
+			}
+
+			if err := stream.Send(chunk); err != nil {
+				// server menutup stream lebih awal, misal karena file terlalu besar
+				_, err = stream.CloseAndRecv()
+				return "", err
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return "", err
+	}
+
+	return resp.GetFilename(), nil
+}
+
+// imageUploadError --> error dari uploadImage jadi AppError, image yang ditolak imageService
+// dikirim ke client sebagai "invalid image: ..."
+func imageUploadError(err error) *library.AppError {
+	appErr := library.FromGrpcError(err)
+	if appErr.Code != library.ERR_INVALID_INPUT {
+		return appErr
+	}
+
+	return library.InvalidInput("invalid image: " + appErr.Message).Wrap(err)
+}
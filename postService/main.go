@@ -43,8 +43,11 @@ func main() {
 	postgresStorage.db.SetMaxIdleConns(25)
 	postgresStorage.db.SetConnMaxLifetime(5 * time.Minute)
 
+	// rabbitmq connection, dipakai consumer dan publisher event post
+	rabbitMq := NewRabbitMQ(cfg, postgresStorage)
+
 	// http server
-	s := NewServer(PORT, postgresStorage, cfg)
+	s := NewServer(PORT, postgresStorage, cfg, rabbitMq.AmqpConn)
 
 	wg.Add(1)
 	go func() {
@@ -52,9 +55,8 @@ func main() {
 		s.Run()
 	}()
 
-	// rabbitmq consumer
-	rabbitMq := NewRabbitMQ(cfg, postgresStorage)
-	go rabbitMq.Run()
+	// rabbitmq consumer & timeline worker
+	go rabbitMq.Run(s.UserServiceGrpcClient)
 
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pewe21/library"
+)
+
+// handleAdminDeletePost --> admin soft delete post siapa saja, dicatat di moderation_logs
+func (s *PostService) handleAdminDeletePost(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle admin delete post")
+
+	vars := mux.Vars(r)
+	postId := vars["id"]
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+
+	if err := uuid.Validate(postId); err != nil {
+		return http.StatusNotFound, fmt.Errorf("post didnot exists")
+	}
+
+	reason, err := library.ReadModerationReason(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	post := &Post{}
+
+	if err := s.Store.GetPostById(postId, principal.UserId, post); err != nil {
+		log.Println("getPostById err:", err)
+		appErr := library.ClassifyPgError(err, ErrPostNotFound, nil)
+		return appErr.HttpStatus, appErr
+	}
+
+	if post.DeletedAt != nil {
+		return http.StatusNotFound, fmt.Errorf("post didnot exists")
+	}
+
+	entry := &library.ModerationLog{
+		Id:         uuid.NewString(),
+		ActorId:    principal.UserId,
+		Action:     library.MODERATION_DELETE_POST,
+		TargetType: "post",
+		TargetId:   postId,
+		Reason:     reason,
+	}
+
+	if err := s.Store.AdminDeletePostById(postId, entry); err != nil {
+		log.Println("Error when admin deleting post by id:", err)
+		appErr := library.ClassifyPgError(err, ErrPostNotFound, nil)
+		return appErr.HttpStatus, appErr
+	}
+
+	event := PostDeletedEvent{
+		Id:     postId,
+		IdUser: post.IdUser,
+		Image:  post.Image,
+	}
+
+	if err := s.publishEvent(r.Context(), "post.deleted", event); err != nil {
+		log.Println("Error when publishing post.deleted event:", err)
+	}
+
+	resp := library.NewResp("Post deleted successfully", map[string]interface{}{"moderation": entry})
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+// handleAdminListDeletedPosts --> list post yang sudah di soft delete, cursor nya "deletedAt_id"
+func (s *PostService) handleAdminListDeletedPosts(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle admin list deleted posts")
+
+	urlQuery := r.URL.Query()
+	cursor := library.ParseKeysetCursor(urlQuery.Get("cursor"))
+	limit := library.ParsePageLimit(urlQuery.Get("limit"))
+
+	posts := &[]Post{}
+
+	if err := s.Store.ListDeletedPosts(cursor, limit, posts); err != nil {
+		log.Println("Error when listing deleted posts:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	meta := struct {
+		Cursor string `json:"cursor"`
+	}{}
+
+	if len(*posts) > 0 {
+		last := (*posts)[len(*posts)-1]
+		if deletedAt, ok := last.DeletedAt.(int64); ok {
+			meta.Cursor = library.KeysetCursor{CreatedAt: deletedAt, Id: last.Id}.String()
+		}
+	}
+
+	resp := library.NewResp("success", map[string]interface{}{
+		"posts": posts,
+		"meta":  meta,
+	})
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
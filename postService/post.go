@@ -1,7 +1,13 @@
 package main
 
+import "github.com/pewe21/library"
+
+var ErrPostNotFound = library.NotFound("post not found")
+
 type Post struct {
 	Id           string `json:"id"`
+	ParentId     string `json:"parentId"`
+	RootId       string `json:"rootId"`
 	Image        string `json:"image"`
 	Body         string `json:"body"`
 	IdUser       string `json:"idUser"`
@@ -10,8 +16,18 @@ type Post struct {
 	Profile      string `json:"profile"`
 	TotalLikes   int64  `json:"totalLikes"`
 	TotalReplies int64  `json:"totalReplies"`
+	LikedByMe    bool   `json:"likedByMe"`
 
 	CreatedAt int64       `json:"createdAt"`
 	UpdatedAt int64       `json:"updatedAt"`
 	DeletedAt interface{} `json:"deletedAt"`
 }
+
+type Liker struct {
+	IdUser   string `json:"idUser"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Profile  string `json:"profile"`
+
+	LikedAt int64 `json:"likedAt"`
+}
@@ -1,32 +1,38 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/pewe21/imageProto"
 	"github.com/pewe21/library"
 	"github.com/pewe21/userProto"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 type PostService struct {
 	Store                  *PostgresStorage
 	UserServiceGrpcClient  userProto.UserClient
 	ImageServiceGrpcClient imageProto.UserClient
+	RabbitMQ               *library.RabbitMq
+	FanoutThreshold        int64
 }
 
-func NewUserService(store *PostgresStorage, userGrpcClient userProto.UserClient, imageGrpcClient imageProto.UserClient) *PostService {
+func NewUserService(store *PostgresStorage, userGrpcClient userProto.UserClient, imageGrpcClient imageProto.UserClient, producer *library.RabbitMq, fanoutThreshold int64) *PostService {
 	return &PostService{
 		Store:                  store,
 		UserServiceGrpcClient:  userGrpcClient,
 		ImageServiceGrpcClient: imageGrpcClient,
+		RabbitMQ:               producer,
+		FanoutThreshold:        fanoutThreshold,
 	}
 }
 
@@ -34,6 +40,12 @@ func (s *PostService) RegisterRoutes(r *mux.Router) {
 	// v1/post/create --> bikin post
 	r.HandleFunc("/", library.CreateHandler(library.JWTMiddleware(s.handleCreatePost))).Methods(http.MethodPost, http.MethodOptions)
 
+	// v1/post/admin/deleted?cursor=&limit= --> list post yang sudah di soft delete, khusus admin
+	r.HandleFunc("/admin/deleted", library.CreateHandler(library.JWTMiddleware(library.RequireRole(library.ROLE_ADMIN, s.handleAdminListDeletedPosts)))).Methods(http.MethodGet, http.MethodOptions)
+
+	// v1/post/admin/{id} --> admin delete post siapa saja, dicatat di moderation_logs
+	r.HandleFunc("/admin/{id}", library.CreateHandler(library.JWTMiddleware(library.RequireRole(library.ROLE_ADMIN, s.handleAdminDeletePost)))).Methods(http.MethodDelete, http.MethodOptions)
+
 	// v1/post/ --> delete post (soft delete, deletedAt nya diisi unixepoch) !Penting nanti di cek dulu apakah idUser dari jwt sama dengan idUser yang ada didalam post
 	r.HandleFunc("/{id}", library.CreateHandler(library.JWTMiddleware(s.handleDeletePost))).Methods(http.MethodDelete, http.MethodOptions)
 
@@ -43,11 +55,126 @@ func (s *PostService) RegisterRoutes(r *mux.Router) {
 	// v1/post --> list post
 	r.HandleFunc("/", library.CreateHandler(library.JWTMiddleware(s.handleListPost))).Methods(http.MethodGet, http.MethodOptions)
 
+	// v1/post/feed --> list post dari user yang di follow (harus didaftarkan sebelum /{id})
+	r.HandleFunc("/feed", library.CreateHandler(library.JWTMiddleware(s.handleListFeed))).Methods(http.MethodGet, http.MethodOptions)
+
 	// v1/post/user/{idUser} --> list post by user
 	r.HandleFunc("/user/{idUser}", library.CreateHandler(library.JWTMiddleware(s.handleListPostByUser))).Methods(http.MethodGet, http.MethodOptions)
 
 	// v1/post/{id} --> get post by id
 	r.HandleFunc("/{id}", library.CreateHandler(library.JWTMiddleware(s.handleGetPostById))).Methods(http.MethodGet, http.MethodOptions)
+
+	// v1/post/{id}/like --> like/unlike post sebagai user dari jwt
+	r.HandleFunc("/{id}/like", library.CreateHandler(library.JWTMiddleware(s.handleLikePost))).Methods(http.MethodPost, http.MethodOptions)
+	r.HandleFunc("/{id}/like", library.CreateHandler(library.JWTMiddleware(s.handleUnlikePost))).Methods(http.MethodDelete, http.MethodOptions)
+
+	// v1/post/{id}/likes?cursor=&limit= --> list user yang like post
+	r.HandleFunc("/{id}/likes", library.CreateHandler(library.JWTMiddleware(s.handleListLikers))).Methods(http.MethodGet, http.MethodOptions)
+
+	// v1/post/{id}/reply --> bikin reply ke post {id}
+	r.HandleFunc("/{id}/reply", library.CreateHandler(library.JWTMiddleware(s.handleCreateReply))).Methods(http.MethodPost, http.MethodOptions)
+
+	// v1/post/{id}/replies?cursor=&limit= --> list reply langsung dari post {id}
+	r.HandleFunc("/{id}/replies", library.CreateHandler(library.JWTMiddleware(s.handleListReplies))).Methods(http.MethodGet, http.MethodOptions)
+}
+
+func (s *PostService) handleLikePost(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle like post")
+
+	vars := mux.Vars(r)
+	postId := vars["id"]
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	idUser := principal.UserId
+
+	if err := uuid.Validate(postId); err != nil {
+		log.Println("Invalid post uuid url")
+		return http.StatusNotFound, fmt.Errorf("Post didnot exists")
+	}
+
+	userIn := &userProto.GetUserByIdReq{
+		Id: idUser,
+	}
+
+	userGrpcResp, err := s.UserServiceGrpcClient.GetUserById(r.Context(), userIn)
+	if err != nil {
+		log.Println("Error when dialing grpc client with getUserById method:", err)
+		appErr := library.FromGrpcError(err)
+		return appErr.HttpStatus, appErr
+	}
+
+	if err := s.Store.LikePost(postId, idUser, userGrpcResp.GetUsername(), userGrpcResp.GetName(), userGrpcResp.GetProfile()); err != nil {
+		log.Println("Error when liking post:", err)
+		appErr := library.ClassifyPgError(err, ErrPostNotFound, nil)
+		return appErr.HttpStatus, appErr
+	}
+
+	resp := library.NewResp("Post liked!", nil)
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+func (s *PostService) handleUnlikePost(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle unlike post")
+
+	vars := mux.Vars(r)
+	postId := vars["id"]
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	idUser := principal.UserId
+
+	if err := s.Store.UnlikePost(postId, idUser); err != nil {
+		log.Println("Error when unliking post:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	resp := library.NewResp("Post unliked!", nil)
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+func (s *PostService) handleListLikers(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle list likers")
+
+	urlQuery := r.URL.Query()
+	cursor := library.ParseKeysetCursor(urlQuery.Get("cursor"))
+	limit := library.ParsePageLimit(urlQuery.Get("limit"))
+	vars := mux.Vars(r)
+	postId := vars["id"]
+
+	likers := &[]Liker{}
+
+	if err := s.Store.ListLikers(cursor, postId, limit, likers); err != nil {
+		log.Println("Error when getting listLikers:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	// cursor halaman berikutnya "likedAt_idUser" dari liker terakhir
+	meta := struct {
+		Cursor string `json:"cursor"`
+	}{}
+
+	if len(*likers) > 0 {
+		last := (*likers)[len(*likers)-1]
+		meta.Cursor = library.KeysetCursor{CreatedAt: last.LikedAt, Id: last.IdUser}.String()
+	}
+
+	resp := library.NewResp("success", map[string]interface{}{
+		"likers": likers,
+		"meta":   meta,
+	})
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
 }
 
 func (s *PostService) handleGetPostById(w http.ResponseWriter, r *http.Request) (int, error) {
@@ -61,14 +188,16 @@ func (s *PostService) handleGetPostById(w http.ResponseWriter, r *http.Request)
 	}
 
 	post := &Post{}
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userId := principal.UserId
 
-	if err := s.Store.GetPostById(postId, post); err != nil {
+	if err := s.Store.GetPostById(postId, userId, post); err != nil {
 		log.Println("getPostById err:", err)
-		if err == sql.ErrNoRows {
-			return http.StatusNotFound, fmt.Errorf("Post didnot exists")
-		}
-
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		appErr := library.ClassifyPgError(err, ErrPostNotFound, nil)
+		return appErr.HttpStatus, appErr
 	}
 
 	resp := library.NewResp("Success", map[string]interface{}{"post": post})
@@ -86,6 +215,11 @@ func (s *PostService) handleListPostByUser(w http.ResponseWriter, r *http.Reques
 	cursor := urlQuery.Get("cursor")
 	vars := mux.Vars(r)
 	profileId := vars["idUser"]
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userId := principal.UserId
 
 	if limit == "" {
 		limit = "10"
@@ -107,7 +241,7 @@ func (s *PostService) handleListPostByUser(w http.ResponseWriter, r *http.Reques
 
 	posts := &[]Post{}
 
-	if err := s.Store.ListPostByUser(int64(intCursor), profileId, int32(intLimit), posts); err != nil {
+	if err := s.Store.ListPostByUser(int64(intCursor), profileId, int32(intLimit), userId, posts); err != nil {
 
 		log.Println("Error when getting listPost:", err)
 		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
@@ -115,8 +249,10 @@ func (s *PostService) handleListPostByUser(w http.ResponseWriter, r *http.Reques
 
 	meta := struct {
 		Cursor int64 `json:"cursor"`
-	}{
-		Cursor: (*posts)[len(*posts)-1].CreatedAt,
+	}{}
+
+	if len(*posts) > 0 {
+		meta.Cursor = (*posts)[len(*posts)-1].CreatedAt
 	}
 
 	resp := library.NewResp("success", map[string]interface{}{
@@ -135,6 +271,11 @@ func (s *PostService) handleListPost(w http.ResponseWriter, r *http.Request) (in
 	urlQuery := r.URL.Query()
 	cursor := urlQuery.Get("cursor")
 	limit := urlQuery.Get("limit")
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userId := principal.UserId
 
 	if cursor == "" {
 		cursor = "0"
@@ -155,15 +296,78 @@ func (s *PostService) handleListPost(w http.ResponseWriter, r *http.Request) (in
 
 	posts := &[]Post{}
 
-	if err := s.Store.ListPost(int64(intCursor), int32(intLimit), posts); err != nil {
+	if err := s.Store.ListPost(int64(intCursor), int32(intLimit), userId, posts); err != nil {
 		log.Println("Error when getting listPost:", err)
 		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
 	}
 
 	meta := struct {
 		Cursor int64 `json:"cursor"`
-	}{
-		Cursor: (*posts)[len(*posts)-1].CreatedAt,
+	}{}
+
+	if len(*posts) > 0 {
+		meta.Cursor = (*posts)[len(*posts)-1].CreatedAt
+	}
+
+	resp := library.NewResp("success", map[string]interface{}{
+		"posts": posts,
+		"meta":  meta,
+	})
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+func (s *PostService) handleListFeed(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle list feed")
+
+	urlQuery := r.URL.Query()
+	cursor := library.ParseKeysetCursor(urlQuery.Get("cursor"))
+	limit := library.ParsePageLimit(urlQuery.Get("limit"))
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userId := principal.UserId
+
+	// list following diambil sekali per request dari userService
+	followingIn := &userProto.ListFollowingReq{
+		Id: userId,
+	}
+
+	followingGrpcResp, err := s.UserServiceGrpcClient.ListFollowingById(r.Context(), followingIn)
+	if err != nil {
+		log.Println("Error when dialing grpc client with ListFollowingById method:", err)
+		appErr := library.FromGrpcError(err)
+		return appErr.HttpStatus, appErr
+	}
+
+	// user dengan follower diatas threshold (status sekarang) tidak di fan-out ke timeline, post nya dibaca langsung
+	followingIds := []string{}
+	pullIds := []string{}
+	for _, following := range followingGrpcResp.GetUsers() {
+		followingIds = append(followingIds, following.GetId())
+		if following.GetTotalFollower() > s.FanoutThreshold {
+			pullIds = append(pullIds, following.GetId())
+		}
+	}
+
+	posts := &[]Post{}
+
+	if err := s.Store.ListFeed(cursor, limit, userId, followingIds, pullIds, posts); err != nil {
+		log.Println("Error when getting listFeed:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	// cursor halaman berikutnya "createdAt_id" dari post terakhir
+	meta := struct {
+		Cursor string `json:"cursor"`
+	}{}
+
+	if len(*posts) > 0 {
+		last := (*posts)[len(*posts)-1]
+		meta.Cursor = library.KeysetCursor{CreatedAt: last.CreatedAt, Id: last.Id}.String()
 	}
 
 	resp := library.NewResp("success", map[string]interface{}{
@@ -181,7 +385,11 @@ func (s *PostService) handleUpdatePost(w http.ResponseWriter, r *http.Request) (
 
 	vars := mux.Vars(r)
 	postId := vars["id"]
-	userId := library.GetUserIdFromJWT(r)
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userId := principal.UserId
 	post := &Post{}
 
 	body, err := io.ReadAll(r.Body)
@@ -196,14 +404,21 @@ func (s *PostService) handleUpdatePost(w http.ResponseWriter, r *http.Request) (
 		return http.StatusBadRequest, fmt.Errorf("invalid post detail")
 	}
 
+	v := library.NewValidator()
+	v.Field("body", post.Body, library.PostBodyRules()...)
+	if err := v.Err(); err != nil {
+		return http.StatusBadRequest, err
+	}
+
 	fetchPost := &Post{}
-	if err := s.Store.GetPostById(postId, fetchPost); err != nil {
+	if err := s.Store.GetPostById(postId, userId, fetchPost); err != nil {
 		log.Println("getPostById err:", err)
-		if err == sql.ErrNoRows {
-			return http.StatusNotFound, fmt.Errorf("Post didnot exists")
-		}
+		appErr := library.ClassifyPgError(err, ErrPostNotFound, nil)
+		return appErr.HttpStatus, appErr
+	}
 
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	if fetchPost.DeletedAt != nil {
+		return http.StatusNotFound, fmt.Errorf("Post didnot exists")
 	}
 
 	if fetchPost.IdUser != userId {
@@ -211,10 +426,6 @@ func (s *PostService) handleUpdatePost(w http.ResponseWriter, r *http.Request) (
 		return http.StatusUnauthorized, fmt.Errorf("unauthorized")
 	}
 
-	/////////////////////////////
-	//TODO validasi input user//
-	///////////////////////////
-
 	if err := s.Store.UpdatePostBody(postId, post.Body, userId); err != nil {
 		log.Println("Error when updating post body:", err)
 		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
@@ -232,17 +443,22 @@ func (s *PostService) handleDeletePost(w http.ResponseWriter, r *http.Request) (
 
 	vars := mux.Vars(r)
 	postId := vars["id"]
-	userId := library.GetUserIdFromJWT(r)
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userId := principal.UserId
 	post := &Post{}
 
-	err := s.Store.GetPostById(postId, post)
+	err := s.Store.GetPostById(postId, userId, post)
 	if err != nil {
 		log.Println("getPostById err:", err)
-		if err == sql.ErrNoRows {
-			return http.StatusNotFound, fmt.Errorf("post didnot exists")
-		}
+		appErr := library.ClassifyPgError(err, ErrPostNotFound, nil)
+		return appErr.HttpStatus, appErr
+	}
 
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	if post.DeletedAt != nil {
+		return http.StatusNotFound, fmt.Errorf("post didnot exists")
 	}
 
 	if userId != post.IdUser {
@@ -252,7 +468,18 @@ func (s *PostService) handleDeletePost(w http.ResponseWriter, r *http.Request) (
 
 	if err := s.Store.DeletePostById(postId, userId); err != nil {
 		log.Println("Error when deleting post by id:", err)
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		appErr := library.ClassifyPgError(err, ErrPostNotFound, nil)
+		return appErr.HttpStatus, appErr
+	}
+
+	event := PostDeletedEvent{
+		Id:     postId,
+		IdUser: post.IdUser,
+		Image:  post.Image,
+	}
+
+	if err := s.publishEvent(r.Context(), "post.deleted", event); err != nil {
+		log.Println("Error when publishing post.deleted event:", err)
 	}
 
 	resp := library.NewResp("Post deleted successfully", nil)
@@ -265,7 +492,128 @@ func (s *PostService) handleDeletePost(w http.ResponseWriter, r *http.Request) (
 func (s *PostService) handleCreatePost(w http.ResponseWriter, r *http.Request) (int, error) {
 	log.Println("hit handle create post")
 
-	idUser := library.GetUserIdFromJWT(r)
+	post, status, err := s.newPostFromForm(r)
+	if err != nil {
+		return status, err
+	}
+
+	var createdAt int64
+
+	if err := s.Store.CreatePost(post.Id, post.Image, post.Body, post.IdUser, post.Username, post.Name, post.Profile, &createdAt); err != nil {
+		log.Println("Error when creating post:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+
+	}
+
+	// post sudah tersimpan, kalau publish gagal post tetap dibuat tapi tidak masuk timeline follower.
+	// createdAt harus sama dengan posts.createdAt karena timeline diurutkan dan di page dengan nilai ini
+	event := PostCreatedEvent{
+		Id:        post.Id,
+		IdUser:    post.IdUser,
+		Image:     post.Image,
+		CreatedAt: createdAt,
+	}
+
+	if err := s.publishEvent(r.Context(), "post.created", event); err != nil {
+		log.Println("Error when publishing post.created event:", err)
+	}
+
+	resp := library.NewResp("post created!", nil)
+	library.WriteJson(w, http.StatusCreated, resp)
+
+	return http.StatusCreated, nil
+}
+
+func (s *PostService) handleCreateReply(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle create reply")
+
+	vars := mux.Vars(r)
+	parentId := vars["id"]
+
+	if err := uuid.Validate(parentId); err != nil {
+		log.Println("Invalid post uuid url")
+		return http.StatusNotFound, fmt.Errorf("Post didnot exists")
+	}
+
+	post, status, err := s.newPostFromForm(r)
+	if err != nil {
+		return status, err
+	}
+
+	if err := s.Store.CreateReply(parentId, post.Id, post.Image, post.Body, post.IdUser, post.Username, post.Name, post.Profile); err != nil {
+		log.Println("Error when creating reply:", err)
+		appErr := library.ClassifyPgError(err, ErrPostNotFound, nil)
+		return appErr.HttpStatus, appErr
+	}
+
+	// reply tidak masuk timeline, event nya hanya dipakai imageService
+	event := PostCreatedEvent{
+		Id:        post.Id,
+		IdUser:    post.IdUser,
+		ParentId:  parentId,
+		Image:     post.Image,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	if err := s.publishEvent(r.Context(), "post.reply.created", event); err != nil {
+		log.Println("Error when publishing post.reply.created event:", err)
+	}
+
+	resp := library.NewResp("reply created!", map[string]interface{}{"id": post.Id})
+	library.WriteJson(w, http.StatusCreated, resp)
+
+	return http.StatusCreated, nil
+}
+
+func (s *PostService) handleListReplies(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle list replies")
+
+	urlQuery := r.URL.Query()
+	cursor := library.ParseKeysetCursor(urlQuery.Get("cursor"))
+	limit := library.ParsePageLimit(urlQuery.Get("limit"))
+	vars := mux.Vars(r)
+	postId := vars["id"]
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userId := principal.UserId
+
+	posts := &[]Post{}
+
+	if err := s.Store.ListReplies(cursor, postId, limit, userId, posts); err != nil {
+		log.Println("Error when getting listReplies:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	// reply diurutkan dari yang lama, cursor nya "createdAt_id" reply terakhir
+	meta := struct {
+		Cursor string `json:"cursor"`
+	}{}
+
+	if len(*posts) > 0 {
+		last := (*posts)[len(*posts)-1]
+		meta.Cursor = library.KeysetCursor{CreatedAt: last.CreatedAt, Id: last.Id}.String()
+	}
+
+	resp := library.NewResp("success", map[string]interface{}{
+		"posts": posts,
+		"meta":  meta,
+	})
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+// newPostFromForm --> baca formdata reqBody & reqImage, upload image lewat grpc,
+// dan isi data user dari userService. Dipakai create post dan create reply
+func (s *PostService) newPostFromForm(r *http.Request) (*Post, int, error) {
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return nil, http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	idUser := principal.UserId
 	postImage := ""
 	userIn := &userProto.GetUserByIdReq{
 		Id: idUser,
@@ -276,13 +624,16 @@ func (s *PostService) handleCreatePost(w http.ResponseWriter, r *http.Request) (
 	err := r.ParseMultipartForm(2 * 1024 * 1024)
 	if err != nil {
 		log.Println("Error when parsing request formdata:", err)
-		return http.StatusBadRequest, fmt.Errorf("invalid formdata, or missing the required field")
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid formdata, or missing the required field")
 	}
 
 	reqBody := r.FormValue("reqBody")
-	if reqBody == "" {
-		log.Println("Error when getting reqBody, invalid/missing form data")
-		return http.StatusBadRequest, fmt.Errorf("invalid/missing form data")
+
+	// divalidasi sebelum upload image supaya image tidak terupload untuk post yang ditolak
+	v := library.NewValidator()
+	v.Field("reqBody", reqBody, library.PostBodyRules()...)
+	if err := v.Err(); err != nil {
+		return nil, http.StatusBadRequest, err
 	}
 
 	file, handler, err := r.FormFile("reqImage")
@@ -290,29 +641,20 @@ func (s *PostService) handleCreatePost(w http.ResponseWriter, r *http.Request) (
 		log.Println("Error when creating file handler:", err)
 	} else {
 		defer file.Close()
-		bytesFile, err := io.ReadAll(file)
-		if err != nil {
-			log.Println("Error when reading file from form data:", err)
-			return http.StatusBadRequest, fmt.Errorf("invalid post detail")
-		}
 
-		imageIn := &imageProto.CreateImageReq{
-			ImageFile: bytesFile,
-			FileName:  handler.Filename,
-		}
-
-		imageGrpcResp, err := s.ImageServiceGrpcClient.CreateImage(r.Context(), imageIn)
+		postImage, err = uploadImage(r.Context(), s.ImageServiceGrpcClient, idUser, file, handler)
 		if err != nil {
-			log.Println("Error when dialing image grpc client with CreateImage method:", err)
-			return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+			log.Println("Error when dialing image grpc client with UploadImage method:", err)
+			appErr := imageUploadError(err)
+			return nil, appErr.HttpStatus, appErr
 		}
-		postImage = imageGrpcResp.GetFilename()
 	}
 
 	userGrpcResp, err := s.UserServiceGrpcClient.GetUserById(r.Context(), userIn)
 	if err != nil {
 		log.Println("Error when dialing grpc client with getUserById method:", err)
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+		appErr := library.FromGrpcError(err)
+		return nil, appErr.HttpStatus, appErr
 	}
 
 	post := &Post{
@@ -325,18 +667,45 @@ func (s *PostService) handleCreatePost(w http.ResponseWriter, r *http.Request) (
 		Body:     reqBody,
 	}
 
-	/////////////////////////////
-	//TODO validasi input user//
-	///////////////////////////
-
-	if err := s.Store.CreatePost(post.Id, post.Image, post.Body, post.IdUser, post.Username, post.Name, post.Profile); err != nil {
-		log.Println("Error when creating post:", err)
-		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	return post, http.StatusOK, nil
+}
 
+// publishEvent --> publish event ke postServiceExchange dengan routingKey
+func (s *PostService) publishEvent(ctx context.Context, routingKey string, event interface{}) error {
+	ch, err := s.RabbitMQ.Conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	err = ch.ExchangeDeclare(
+		POST_SERVICE_EXCHANGE,
+		"topic",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
 	}
 
-	resp := library.NewResp("post created!", nil)
-	library.WriteJson(w, http.StatusCreated, resp)
+	publishBody, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
 
-	return http.StatusCreated, nil
+	return ch.PublishWithContext(
+		ctx,
+		POST_SERVICE_EXCHANGE,
+		routingKey,
+		false,
+		false,
+		amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			ContentType:  "text/plain",
+			Body:         publishBody,
+		},
+	)
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/pewe21/library"
+	"github.com/pewe21/userProto"
+	"google.golang.org/grpc"
+)
+
+// fakeUserClient --> userService dengan data user dan follow tetap, method lain tidak dipakai test
+type fakeUserClient struct {
+	userProto.UserClient
+	users     map[string]*userProto.UserResp
+	followers map[string][]string
+	following []*userProto.FollowingUser
+}
+
+func (c *fakeUserClient) GetUserById(ctx context.Context, in *userProto.GetUserByIdReq, opts ...grpc.CallOption) (*userProto.UserResp, error) {
+	user, ok := c.users[in.Id]
+	if !ok {
+		return nil, library.NotFound("user not found")
+	}
+	return user, nil
+}
+
+func (c *fakeUserClient) ListFollowerById(ctx context.Context, in *userProto.ListFollowerReq, opts ...grpc.CallOption) (*userProto.ListFollowerResp, error) {
+	return &userProto.ListFollowerResp{Ids: c.followers[in.Id]}, nil
+}
+
+func (c *fakeUserClient) ListFollowingById(ctx context.Context, in *userProto.ListFollowingReq, opts ...grpc.CallOption) (*userProto.ListFollowingResp, error) {
+	resp := &userProto.ListFollowingResp{Users: c.following}
+	for _, user := range c.following {
+		resp.Ids = append(resp.Ids, user.Id)
+	}
+	return resp, nil
+}
+
+func TestHandleListFeed(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		createdAt int64
+		id        string
+	}{
+		{"first page", "?limit=2", 922337203685477, ""},
+		{"next page", "?limit=2&cursor=1700000100_post-9", 1700000100, "post-9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, mock := newMockStorage(t)
+
+			s := &PostService{
+				Store: store,
+				UserServiceGrpcClient: &fakeUserClient{following: []*userProto.FollowingUser{
+					{Id: "alice", TotalFollower: 10},
+					{Id: "celebrity", TotalFollower: 5000},
+				}},
+				FanoutThreshold: 1000,
+			}
+
+			// semua yang di follow untuk filter unfollow, hanya yang diatas threshold dibaca langsung
+			mock.ExpectPrepare(`WITH feed AS`).
+				ExpectQuery().
+				WithArgs(tt.createdAt, tt.id, "viewer", int32(2), pq.Array([]string{"alice", "celebrity"}), pq.Array([]string{"celebrity"})).
+				WillReturnRows(sqlmock.NewRows([]string{
+					"id", "parentId", "rootId", "image", "body", "idUser", "username", "name", "profile",
+					"totalLikes", "totalReplies", "likedByMe", "createdAt", "updatedAt",
+				}).
+					AddRow("post-3", "", "", "", "from celebrity", "celebrity", "celebrity", "Celebrity", "", int64(0), int64(0), false, int64(1700000050), int64(1700000050)).
+					AddRow("post-2", "", "", "", "from alice", "alice", "alice", "Alice", "", int64(1), int64(0), true, int64(1700000040), int64(1700000040)))
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/post/feed"+tt.query, nil)
+			r = r.WithContext(library.WithPrincipal(r.Context(), &library.Principal{UserId: "viewer"}))
+			w := httptest.NewRecorder()
+
+			status, err := s.handleListFeed(w, r)
+			if err != nil || status != http.StatusOK {
+				t.Fatalf("handleListFeed = %d, %v, want 200", status, err)
+			}
+
+			resp := struct {
+				Data struct {
+					Posts []Post `json:"posts"`
+					Meta  struct {
+						Cursor string `json:"cursor"`
+					} `json:"meta"`
+				} `json:"data"`
+			}{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Data.Posts) != 2 || resp.Data.Posts[0].Id != "post-3" {
+				t.Errorf("posts = %+v, want post-3 then post-2", resp.Data.Posts)
+			}
+
+			// cursor halaman berikutnya dari post terakhir
+			if resp.Data.Meta.Cursor != "1700000040_post-2" {
+				t.Errorf("meta.cursor = %q, want 1700000040_post-2", resp.Data.Meta.Cursor)
+			}
+		})
+	}
+}
+
+func TestHandleListFeedWithoutPrincipal(t *testing.T) {
+	s := &PostService{UserServiceGrpcClient: &fakeUserClient{}}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/post/feed", nil)
+	if status, err := s.handleListFeed(httptest.NewRecorder(), r); status != http.StatusUnauthorized || err == nil {
+		t.Errorf("handleListFeed without principal = %d, %v, want 401", status, err)
+	}
+}
@@ -7,7 +7,8 @@ import (
 	"os"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/pewe21/library"
 )
 
 type PostgresStorage struct {
@@ -42,6 +43,26 @@ func (s *PostgresStorage) Init() {
 	if err := s.createPostTable(); err != nil {
 		log.Fatal(err)
 	}
+
+	if err := s.createPostLikeTable(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.alterPostTableReplies(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.createTimelineTable(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.alterPostTablePullOnly(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.createModerationLogTable(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func (s *PostgresStorage) createPostTable() error {
@@ -68,6 +89,128 @@ func (s *PostgresStorage) createPostTable() error {
 	return nil
 }
 
+// reply adalah post biasa dengan parentId (post yang dibalas) dan rootId (post paling atas di thread)
+func (s *PostgresStorage) alterPostTableReplies() error {
+	_, err := s.db.Exec(`
+        ALTER TABLE posts
+            ADD COLUMN IF NOT EXISTS parentId TEXT REFERENCES posts(id),
+            ADD COLUMN IF NOT EXISTS rootId TEXT REFERENCES posts(id)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS posts_parent_idx
+        ON posts (parentId, createdAt)`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// moderation_logs --> audit log aksi admin terhadap post
+func (s *PostgresStorage) createModerationLogTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS moderation_logs (
+            id TEXT PRIMARY KEY,
+            actorId TEXT NOT NULL,
+            action TEXT NOT NULL,
+            targetType TEXT NOT NULL,
+            targetId TEXT NOT NULL,
+            reason TEXT NOT NULL,
+
+            createdAt INTEGER NOT NULL
+        )`)
+
+	return err
+}
+
+// timeline --> hasil fan-out-on-write, satu baris per (follower, post)
+func (s *PostgresStorage) createTimelineTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS timeline (
+            idUser TEXT NOT NULL,
+            postId TEXT NOT NULL REFERENCES posts(id),
+
+            createdAt INTEGER NOT NULL,
+            PRIMARY KEY (idUser, postId)
+        )`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS timeline_user_idx
+        ON timeline (idUser, createdAt)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS timeline_post_idx
+        ON timeline (postId)`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// pullOnly --> post yang tidak di fan-out karena follower author nya diatas FANOUT_THRESHOLD saat post dibuat.
+// Post ini selalu dibaca langsung di feed, walaupun follower author nya sekarang sudah dibawah threshold
+func (s *PostgresStorage) alterPostTablePullOnly() error {
+	_, err := s.db.Exec(`
+        ALTER TABLE posts
+            ADD COLUMN IF NOT EXISTS pullOnly BOOLEAN DEFAULT FALSE NOT NULL`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS posts_user_idx
+        ON posts (idUser, createdAt, id)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS posts_pull_only_idx
+        ON posts (idUser, createdAt, id) WHERE pullOnly`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// post_likes --> satu baris per (postId, userId), data user disimpan juga seperti di posts
+func (s *PostgresStorage) createPostLikeTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS post_likes (
+            postId TEXT NOT NULL REFERENCES posts(id),
+            idUser TEXT NOT NULL,
+            username TEXT NOT NULL,
+            name TEXT NOT NULL,
+            profile TEXT NOT NULL,
+
+            createdAt INTEGER NOT NULL,
+            PRIMARY KEY (postId, idUser)
+        )`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS post_likes_post_idx
+        ON post_likes (postId, createdAt)`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *PostgresStorage) UpdatePostBody(id, body, userid string) error {
 	// psql use $1, $2, $3, etc. instead of ? as placeholder
 	// http://go-database-sql.org/prepared.html#parameter-placeholder-syntax
@@ -96,10 +239,14 @@ func (s *PostgresStorage) UpdatePostBody(id, body, userid string) error {
 }
 
 // listPostByUser --> nampilin list post yang dibuat oleh user
-func (s *PostgresStorage) ListPostByUser(cursor int64, userId string, limit int32, posts *[]Post) error {
+//
+// likedByMe diisi berdasarkan viewerId (user dari jwt)
+func (s *PostgresStorage) ListPostByUser(cursor int64, userId string, limit int32, viewerId string, posts *[]Post) error {
 	queryStr := `
         SELECT
             id,
+            COALESCE(parentId, ''),
+            COALESCE(rootId, ''),
             image,
             body,
             idUser,
@@ -108,6 +255,10 @@ func (s *PostgresStorage) ListPostByUser(cursor int64, userId string, limit int3
             profile,
             totalLikes,
             totalReplies,
+            EXISTS (
+                SELECT 1 FROM post_likes
+                WHERE post_likes.postId = posts.id AND post_likes.idUser = $4
+            ),
             createdAt,
             updatedAt
         FROM
@@ -132,7 +283,7 @@ func (s *PostgresStorage) ListPostByUser(cursor int64, userId string, limit int3
 		cursor = 922337203685477
 	}
 
-	rows, err := stmt.Query(userId, cursor, limit)
+	rows, err := stmt.Query(userId, cursor, limit, viewerId)
 	if err != nil {
 		return err
 	}
@@ -143,6 +294,8 @@ func (s *PostgresStorage) ListPostByUser(cursor int64, userId string, limit int3
 		var post Post
 		err := rows.Scan(
 			&post.Id,
+			&post.ParentId,
+			&post.RootId,
 			&post.Image,
 			&post.Body,
 			&post.IdUser,
@@ -151,6 +304,7 @@ func (s *PostgresStorage) ListPostByUser(cursor int64, userId string, limit int3
 			&post.Profile,
 			&post.TotalLikes,
 			&post.TotalReplies,
+			&post.LikedByMe,
 			&post.CreatedAt,
 			&post.UpdatedAt,
 		)
@@ -165,10 +319,12 @@ func (s *PostgresStorage) ListPostByUser(cursor int64, userId string, limit int3
 }
 
 // listPosts --> nampilin list post
-func (s *PostgresStorage) ListPost(cursor int64, limit int32, posts *[]Post) error {
+func (s *PostgresStorage) ListPost(cursor int64, limit int32, viewerId string, posts *[]Post) error {
 	queryStr := `
         SELECT
             id,
+            COALESCE(parentId, ''),
+            COALESCE(rootId, ''),
             image,
             body,
             idUser,
@@ -177,12 +333,17 @@ func (s *PostgresStorage) ListPost(cursor int64, limit int32, posts *[]Post) err
             profile,
             totalLikes,
             totalReplies,
+            EXISTS (
+                SELECT 1 FROM post_likes
+                WHERE post_likes.postId = posts.id AND post_likes.idUser = $3
+            ),
             createdAt,
             updatedAt
         FROM
             posts 
         WHERE
             deletedAt IS NULL
+            AND parentId IS NULL
             AND createdAt < $1
         ORDER BY
             createdAt DESC
@@ -198,7 +359,7 @@ func (s *PostgresStorage) ListPost(cursor int64, limit int32, posts *[]Post) err
 		cursor = 922337203685477
 	}
 
-	rows, err := stmt.Query(cursor, limit)
+	rows, err := stmt.Query(cursor, limit, viewerId)
 	if err != nil {
 		return err
 	}
@@ -209,6 +370,8 @@ func (s *PostgresStorage) ListPost(cursor int64, limit int32, posts *[]Post) err
 		var post Post
 		err := rows.Scan(
 			&post.Id,
+			&post.ParentId,
+			&post.RootId,
 			&post.Image,
 			&post.Body,
 			&post.IdUser,
@@ -217,6 +380,7 @@ func (s *PostgresStorage) ListPost(cursor int64, limit int32, posts *[]Post) err
 			&post.Profile,
 			&post.TotalLikes,
 			&post.TotalReplies,
+			&post.LikedByMe,
 			&post.CreatedAt,
 			&post.UpdatedAt,
 		)
@@ -230,11 +394,222 @@ func (s *PostgresStorage) ListPost(cursor int64, limit int32, posts *[]Post) err
 	return nil
 }
 
+// ListFeed --> nampilin post untuk feed viewerId, keyset cursor (createdAt, id).
+//
+// post diambil dari:
+//   - timeline viewerId (fan-out-on-write, plus TIMELINE_BACKFILL_LIMIT post terakhir saat mulai follow)
+//     selama authornya masih di follow
+//   - post viewerId sendiri
+//   - post langsung dari pullIds, user yang followernya sekarang lebih dari fanout threshold
+//   - post pullOnly dari user yang di follow, dibuat saat followernya diatas threshold jadi tidak ada di timeline
+//
+// tiap sumber dibatasi limit dulu supaya tidak scan semua post, lalu digabung dengan UNION
+func (s *PostgresStorage) ListFeed(cursor library.KeysetCursor, limit int32, viewerId string, followingIds, pullIds []string, posts *[]Post) error {
+	stmt, err := s.db.Prepare(`
+        WITH feed AS (
+            (
+                SELECT timeline.postId AS id
+                FROM timeline
+                WHERE
+                    timeline.idUser = $3
+                    AND (timeline.createdAt, timeline.postId) < ($1, $2)
+                ORDER BY timeline.createdAt DESC, timeline.postId DESC
+                LIMIT $4
+            )
+            UNION
+            (
+                SELECT id
+                FROM posts
+                WHERE
+                    idUser = $3
+                    AND parentId IS NULL
+                    AND (createdAt, id) < ($1, $2)
+                ORDER BY createdAt DESC, id DESC
+                LIMIT $4
+            )
+            UNION
+            (
+                SELECT id
+                FROM posts
+                WHERE
+                    idUser = ANY($6)
+                    AND parentId IS NULL
+                    AND (createdAt, id) < ($1, $2)
+                ORDER BY createdAt DESC, id DESC
+                LIMIT $4
+            )
+            UNION
+            (
+                SELECT id
+                FROM posts
+                WHERE
+                    pullOnly
+                    AND idUser = ANY($5)
+                    AND parentId IS NULL
+                    AND (createdAt, id) < ($1, $2)
+                ORDER BY createdAt DESC, id DESC
+                LIMIT $4
+            )
+        )
+        SELECT
+            posts.id,
+            COALESCE(posts.parentId, ''),
+            COALESCE(posts.rootId, ''),
+            posts.image,
+            posts.body,
+            posts.idUser,
+            posts.username,
+            posts.name,
+            posts.profile,
+            posts.totalLikes,
+            posts.totalReplies,
+            EXISTS (
+                SELECT 1 FROM post_likes
+                WHERE post_likes.postId = posts.id AND post_likes.idUser = $3
+            ),
+            posts.createdAt,
+            posts.updatedAt
+        FROM
+            feed
+            JOIN posts ON posts.id = feed.id
+        WHERE
+            posts.deletedAt IS NULL
+            -- timeline lama dari author yang sudah di unfollow tidak ditampilkan
+            AND (posts.idUser = $3 OR posts.idUser = ANY($5))
+        ORDER BY
+            posts.createdAt DESC,
+            posts.id DESC
+        LIMIT $4`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	if cursor.IsZero() {
+		cursor.CreatedAt = 922337203685477
+	}
+
+	rows, err := stmt.Query(cursor.CreatedAt, cursor.Id, viewerId, limit, pq.Array(followingIds), pq.Array(pullIds))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(
+			&post.Id,
+			&post.ParentId,
+			&post.RootId,
+			&post.Image,
+			&post.Body,
+			&post.IdUser,
+			&post.Username,
+			&post.Name,
+			&post.Profile,
+			&post.TotalLikes,
+			&post.TotalReplies,
+			&post.LikedByMe,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		*posts = append(*posts, post)
+	}
+
+	return rows.Err()
+}
+
+// MarkPostPullOnly --> post tidak di fan-out, dibaca langsung di feed follower
+func (s *PostgresStorage) MarkPostPullOnly(postId string) error {
+	stmt, err := s.db.Prepare(`
+        UPDATE posts
+        SET
+            pullOnly = TRUE
+        WHERE
+            id = $1`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(postId); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertTimelineEntries --> fan-out post ke timeline setiap userIds,
+// post yang sudah dihapus tidak dimasukkan
+func (s *PostgresStorage) InsertTimelineEntries(postId string, userIds []string) error {
+	_, err := s.db.Exec(`
+        INSERT INTO timeline (
+            idUser,
+            postId,
+            createdAt
+        )
+        SELECT
+            follower.idUser,
+            posts.id,
+            posts.createdAt
+        FROM
+            posts,
+            UNNEST($2::TEXT[]) AS follower(idUser)
+        WHERE
+            posts.id = $1
+            AND posts.deletedAt IS NULL
+        ON CONFLICT (idUser, postId) DO NOTHING`, postId, pq.Array(userIds))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// BackfillTimeline --> masukkan limit post terakhir authorId ke timeline idUser, dipakai saat idUser
+// mulai follow authorId. Reply dan post yang sudah dihapus tidak dimasukkan
+func (s *PostgresStorage) BackfillTimeline(idUser, authorId string, limit int32) error {
+	_, err := s.db.Exec(`
+        INSERT INTO timeline (
+            idUser,
+            postId,
+            createdAt
+        )
+        SELECT
+            $1,
+            id,
+            createdAt
+        FROM
+            posts
+        WHERE
+            idUser = $2
+            AND parentId IS NULL
+            AND deletedAt IS NULL
+        ORDER BY
+            createdAt DESC,
+            id DESC
+        LIMIT $3
+        ON CONFLICT (idUser, postId) DO NOTHING`, idUser, authorId, limit)
+
+	return err
+}
+
 // getPostById --> nampilin satu post
-func (s *PostgresStorage) GetPostById(id string, post *Post) error {
+//
+// post yang sudah dihapus tapi punya reply tetap dikembalikan sebagai placeholder
+// (body dan image dikosongkan, deletedAt terisi) supaya thread nya tidak putus
+func (s *PostgresStorage) GetPostById(id, viewerId string, post *Post) error {
 	stmt, err := s.db.Prepare(`
         SELECT
             id,
+            COALESCE(parentId, ''),
+            COALESCE(rootId, ''),
             image,
             body,
             idUser,
@@ -243,13 +618,21 @@ func (s *PostgresStorage) GetPostById(id string, post *Post) error {
             profile,
             totalLikes,
             totalReplies,
+            EXISTS (
+                SELECT 1 FROM post_likes
+                WHERE post_likes.postId = posts.id AND post_likes.idUser = $2
+            ),
             createdAt,
-            updatedAt
+            updatedAt,
+            deletedAt
         FROM
             posts 
         WHERE
             id = $1
-            AND deletedAt IS NULL
+            AND (
+                deletedAt IS NULL
+                OR EXISTS (SELECT 1 FROM posts replies WHERE replies.parentId = posts.id)
+            )
         LIMIT 1
         `)
 	if err != nil {
@@ -258,8 +641,12 @@ func (s *PostgresStorage) GetPostById(id string, post *Post) error {
 
 	defer stmt.Close()
 
-	if err := stmt.QueryRow(id).Scan(
+	var deletedAt sql.NullInt64
+
+	if err := stmt.QueryRow(id, viewerId).Scan(
 		&post.Id,
+		&post.ParentId,
+		&post.RootId,
 		&post.Image,
 		&post.Body,
 		&post.IdUser,
@@ -268,17 +655,121 @@ func (s *PostgresStorage) GetPostById(id string, post *Post) error {
 		&post.Profile,
 		&post.TotalLikes,
 		&post.TotalReplies,
+		&post.LikedByMe,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&deletedAt,
 	); err != nil {
 		return err
 	}
 
+	setDeletedPlaceholder(post, deletedAt)
+
 	return nil
 }
 
-func (s *PostgresStorage) DeletePostById(id, userId string) error {
+// ListReplies --> nampilin reply langsung dari parentId, yang lama duluan
+func (s *PostgresStorage) ListReplies(cursor library.KeysetCursor, parentId string, limit int32, viewerId string, posts *[]Post) error {
 	stmt, err := s.db.Prepare(`
+        SELECT
+            id,
+            COALESCE(parentId, ''),
+            COALESCE(rootId, ''),
+            image,
+            body,
+            idUser,
+            username,
+            name,
+            profile,
+            totalLikes,
+            totalReplies,
+            EXISTS (
+                SELECT 1 FROM post_likes
+                WHERE post_likes.postId = posts.id AND post_likes.idUser = $5
+            ),
+            createdAt,
+            updatedAt,
+            deletedAt
+        FROM
+            posts
+        WHERE
+            parentId = $1
+            AND (createdAt, id) > ($2, $3)
+            AND (
+                deletedAt IS NULL
+                OR EXISTS (SELECT 1 FROM posts replies WHERE replies.parentId = posts.id)
+            )
+        ORDER BY
+            createdAt ASC,
+            id ASC
+        LIMIT $4`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(parentId, cursor.CreatedAt, cursor.Id, limit, viewerId)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var post Post
+		var deletedAt sql.NullInt64
+		if err := rows.Scan(
+			&post.Id,
+			&post.ParentId,
+			&post.RootId,
+			&post.Image,
+			&post.Body,
+			&post.IdUser,
+			&post.Username,
+			&post.Name,
+			&post.Profile,
+			&post.TotalLikes,
+			&post.TotalReplies,
+			&post.LikedByMe,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&deletedAt,
+		); err != nil {
+			return err
+		}
+
+		setDeletedPlaceholder(&post, deletedAt)
+		*posts = append(*posts, post)
+	}
+
+	return rows.Err()
+}
+
+func setDeletedPlaceholder(post *Post, deletedAt sql.NullInt64) {
+	if !deletedAt.Valid {
+		return
+	}
+
+	post.DeletedAt = deletedAt.Int64
+	post.Body = ""
+	post.Image = ""
+}
+
+// DeletePostById --> soft delete post dan hapus entry timeline nya, kalau post nya reply
+// totalReplies parent dikurangi dalam transaksi yang sama
+func (s *PostgresStorage) DeletePostById(id, userId string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	unixEpoch := time.Now().Unix()
+
+	var parentId sql.NullString
+	if err := tx.QueryRow(`
         UPDATE 
             posts
         SET 
@@ -287,22 +778,166 @@ func (s *PostgresStorage) DeletePostById(id, userId string) error {
             id = $2
             AND idUser = $3
             AND deletedAt IS NULL
-        `)
+        RETURNING parentId
+        `, unixEpoch, id, userId).Scan(&parentId); err != nil {
+		return err
+	}
+
+	if err := cleanupDeletedPost(tx, id, parentId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AdminDeletePostById --> sama dengan DeletePostById tapi tanpa cek pemilik post,
+// audit log moderasi ditulis dalam transaksi yang sama
+func (s *PostgresStorage) AdminDeletePostById(id string, entry *library.ModerationLog) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	defer tx.Rollback()
 
 	unixEpoch := time.Now().Unix()
 
-	if _, err := stmt.Exec(unixEpoch, id, userId); err != nil {
+	var parentId sql.NullString
+	if err := tx.QueryRow(`
+        UPDATE 
+            posts
+        SET 
+            deletedAt = $1
+        WHERE 
+            id = $2
+            AND deletedAt IS NULL
+        RETURNING parentId
+        `, unixEpoch, id).Scan(&parentId); err != nil {
+		return err
+	}
+
+	if err := cleanupDeletedPost(tx, id, parentId); err != nil {
+		return err
+	}
+
+	if err := insertModerationLog(tx, entry, unixEpoch); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertModerationLog(tx *sql.Tx, entry *library.ModerationLog, createdAt int64) error {
+	entry.CreatedAt = createdAt
+
+	_, err := tx.Exec(`
+        INSERT INTO moderation_logs (id, actorId, action, targetType, targetId, reason, createdAt)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.Id,
+		entry.ActorId,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetId,
+		entry.Reason,
+		entry.CreatedAt)
+
+	return err
+}
+
+// cleanupDeletedPost --> tarik post dari timeline follower dan kurangi totalReplies parent nya
+func cleanupDeletedPost(tx *sql.Tx, id string, parentId sql.NullString) error {
+	if _, err := tx.Exec(`
+        DELETE FROM timeline WHERE postId = $1`, id); err != nil {
 		return err
 	}
 
+	if parentId.Valid {
+		if _, err := tx.Exec(`
+            UPDATE posts
+            SET
+                totalReplies = totalReplies - 1
+            WHERE
+                id = $1`, parentId.String); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// ListDeletedPosts --> post yang sudah di soft delete (body dan image asli), urut dari deletedAt terbaru.
+// Keyset pagination (deletedAt, id), CreatedAt di cursor berisi deletedAt
+func (s *PostgresStorage) ListDeletedPosts(cursor library.KeysetCursor, limit int32, posts *[]Post) error {
+	stmt, err := s.db.Prepare(`
+        SELECT
+            id,
+            COALESCE(parentId, ''),
+            COALESCE(rootId, ''),
+            image,
+            body,
+            idUser,
+            username,
+            name,
+            profile,
+            totalLikes,
+            totalReplies,
+            createdAt,
+            updatedAt,
+            deletedAt
+        FROM
+            posts
+        WHERE
+            deletedAt IS NOT NULL
+            AND (deletedAt, id) < ($1, $2)
+        ORDER BY
+            deletedAt DESC,
+            id DESC
+        LIMIT $3`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	if cursor.IsZero() {
+		cursor.CreatedAt = 922337203685477
+	}
+
+	rows, err := stmt.Query(cursor.CreatedAt, cursor.Id, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var post Post
+		var deletedAt int64
+		if err := rows.Scan(
+			&post.Id,
+			&post.ParentId,
+			&post.RootId,
+			&post.Image,
+			&post.Body,
+			&post.IdUser,
+			&post.Username,
+			&post.Name,
+			&post.Profile,
+			&post.TotalLikes,
+			&post.TotalReplies,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&deletedAt,
+		); err != nil {
+			return err
+		}
+		post.DeletedAt = deletedAt
+		*posts = append(*posts, post)
+	}
+
+	return rows.Err()
+}
+
 func (s *PostgresStorage) UpdateUserDetail(idUser, profile, name string) error {
 	log.Println(idUser, profile, name)
 	stmt, err := s.db.Prepare(`
@@ -327,12 +962,24 @@ func (s *PostgresStorage) UpdateUserDetail(idUser, profile, name string) error {
 		return err
 	}
 
+	if _, err := s.db.Exec(`
+        UPDATE post_likes
+        SET
+            name = $1,
+            profile = $2
+        WHERE
+            idUser = $3
+        `, name, profile, idUser); err != nil {
+		return err
+	}
+
 	log.Println("done updating user detail in post service")
 
 	return nil
 }
 
-func (s *PostgresStorage) CreatePost(id, image, body, idUser, username, name, profile string) error {
+// CreatePost --> simpan post baru, createdAt diisi waktu yang disimpan di posts.createdAt
+func (s *PostgresStorage) CreatePost(id, image, body, idUser, username, name, profile string, createdAt *int64) error {
 	stmt, err := s.db.Prepare(`
         INSERT INTO posts (
             id,
@@ -367,5 +1014,220 @@ func (s *PostgresStorage) CreatePost(id, image, body, idUser, username, name, pr
 		return err
 	}
 
+	*createdAt = unixEpoch
+
 	return nil
 }
+
+// LikePost --> like post, totalLikes diupdate dalam transaksi yang sama.
+// Like ulang oleh user yang sama tidak menambah totalLikes
+func (s *PostgresStorage) LikePost(postId, idUser, username, name, profile string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	// row post di lock supaya like tidak menambah totalLikes post yang sedang dihapus
+	var exists int
+	if err := tx.QueryRow(`
+        SELECT 1 FROM posts WHERE id = $1 AND deletedAt IS NULL
+        FOR UPDATE`, postId).Scan(&exists); err != nil {
+		return err
+	}
+
+	unixEpoch := time.Now().Unix()
+
+	res, err := tx.Exec(`
+        INSERT INTO post_likes (
+            postId,
+            idUser,
+            username,
+            name,
+            profile,
+            createdAt
+        ) VALUES ($1,$2,$3,$4,$5,$6)
+        ON CONFLICT (postId, idUser) DO NOTHING`, postId, idUser, username, name, profile, unixEpoch)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if inserted == 0 {
+		return tx.Commit()
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE posts
+        SET
+            totalLikes = totalLikes + 1
+        WHERE
+            id = $1`, postId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnlikePost --> kebalikan LikePost
+func (s *PostgresStorage) UnlikePost(postId, idUser string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+        DELETE FROM post_likes
+        WHERE
+            postId = $1
+            AND idUser = $2`, postId, idUser)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return tx.Commit()
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE posts
+        SET
+            totalLikes = totalLikes - 1
+        WHERE
+            id = $1`, postId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListLikers --> list user yang like post, terbaru duluan
+func (s *PostgresStorage) ListLikers(cursor library.KeysetCursor, postId string, limit int32, likers *[]Liker) error {
+	stmt, err := s.db.Prepare(`
+        SELECT
+            idUser,
+            username,
+            name,
+            profile,
+            createdAt
+        FROM
+            post_likes
+        WHERE
+            postId = $1
+            AND (createdAt, idUser) < ($2, $3)
+        ORDER BY
+            createdAt DESC,
+            idUser DESC
+        LIMIT $4`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	if cursor.IsZero() {
+		cursor.CreatedAt = 922337203685477
+	}
+
+	rows, err := stmt.Query(postId, cursor.CreatedAt, cursor.Id, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var liker Liker
+		if err := rows.Scan(
+			&liker.IdUser,
+			&liker.Username,
+			&liker.Name,
+			&liker.Profile,
+			&liker.LikedAt,
+		); err != nil {
+			return err
+		}
+		*likers = append(*likers, liker)
+	}
+
+	return rows.Err()
+}
+
+// CreateReply --> bikin reply ke parentId, totalReplies parent ditambah dalam transaksi yang sama
+func (s *PostgresStorage) CreateReply(parentId, id, image, body, idUser, username, name, profile string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	// rootId reply = rootId parent, atau parent itu sendiri kalau parent nya post paling atas
+	var rootId string
+	if err := tx.QueryRow(`
+        SELECT
+            COALESCE(rootId, id)
+        FROM
+            posts
+        WHERE
+            id = $1
+            AND deletedAt IS NULL
+        FOR UPDATE`, parentId).Scan(&rootId); err != nil {
+		return err
+	}
+
+	unixEpoch := time.Now().Unix()
+
+	if _, err := tx.Exec(`
+        INSERT INTO posts (
+            id,
+            parentId,
+            rootId,
+            image,
+            body,
+            idUser,
+            username,
+            name,
+            profile,
+
+            createdAt,
+            updatedAt
+        ) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+        `,
+		id,
+		parentId,
+		rootId,
+		image,
+		body,
+		idUser,
+		username,
+		name,
+		profile,
+		unixEpoch,
+		unixEpoch); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE posts
+        SET
+            totalReplies = totalReplies + 1
+        WHERE
+            id = $1`, parentId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
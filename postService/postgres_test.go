@@ -0,0 +1,322 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/pewe21/library"
+)
+
+// newMockStorage --> PostgresStorage dengan sqlmock, query dicocokkan dengan regex
+func newMockStorage(t *testing.T) (*PostgresStorage, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return &PostgresStorage{db: db}, mock
+}
+
+func TestLikePost(t *testing.T) {
+	t.Run("first like increments totalLikes", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT 1 FROM posts WHERE id = \$1 AND deletedAt IS NULL\s+FOR UPDATE`).
+			WithArgs("post-1").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO post_likes .* ON CONFLICT \(postId, idUser\) DO NOTHING`).
+			WithArgs("post-1", "alice", "alice", "Alice", "", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`totalLikes = totalLikes \+ 1`).
+			WithArgs("post-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := s.LikePost("post-1", "alice", "alice", "Alice", ""); err != nil {
+			t.Fatalf("LikePost = %v, want nil", err)
+		}
+	})
+
+	t.Run("like again is a no-op", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT 1 FROM posts`).
+			WithArgs("post-1").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO post_likes`).
+			WithArgs("post-1", "alice", "alice", "Alice", "", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		if err := s.LikePost("post-1", "alice", "alice", "Alice", ""); err != nil {
+			t.Fatalf("LikePost = %v, want nil", err)
+		}
+	})
+
+	t.Run("deleted post", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT 1 FROM posts`).
+			WithArgs("post-1").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		if err := s.LikePost("post-1", "alice", "alice", "Alice", ""); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("LikePost = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestUnlikePost(t *testing.T) {
+	t.Run("unlike decrements totalLikes", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM post_likes`).
+			WithArgs("post-1", "alice").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`totalLikes = totalLikes - 1`).
+			WithArgs("post-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := s.UnlikePost("post-1", "alice"); err != nil {
+			t.Fatalf("UnlikePost = %v, want nil", err)
+		}
+	})
+
+	t.Run("unlike without like is a no-op", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM post_likes`).
+			WithArgs("post-1", "alice").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		if err := s.UnlikePost("post-1", "alice"); err != nil {
+			t.Fatalf("UnlikePost = %v, want nil", err)
+		}
+	})
+}
+
+func TestListLikersKeyset(t *testing.T) {
+	tests := []struct {
+		name      string
+		cursor    library.KeysetCursor
+		createdAt int64
+		id        string
+	}{
+		{"first page", library.KeysetCursor{}, 922337203685477, ""},
+		{"next page", library.KeysetCursor{CreatedAt: 1700000000, Id: "carol"}, 1700000000, "carol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockStorage(t)
+
+			mock.ExpectPrepare(`\(createdAt, idUser\) < \(\$2, \$3\)`).
+				ExpectQuery().
+				WithArgs("post-1", tt.createdAt, tt.id, int32(2)).
+				WillReturnRows(sqlmock.NewRows([]string{"idUser", "username", "name", "profile", "createdAt"}).
+					AddRow("dave", "dave", "Dave", "", int64(1699999999)).
+					AddRow("alice", "alice", "Alice", "", int64(1699999998)))
+
+			likers := []Liker{}
+			if err := s.ListLikers(tt.cursor, "post-1", 2, &likers); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(likers) != 2 || likers[0].IdUser != "dave" || likers[1].LikedAt != 1699999998 {
+				t.Errorf("ListLikers = %+v, want dave then alice", likers)
+			}
+		})
+	}
+}
+
+// postColumns --> kolom hasil GetPostById/ListReplies
+var postColumns = []string{
+	"id", "parentId", "rootId", "image", "body", "idUser", "username", "name", "profile",
+	"totalLikes", "totalReplies", "likedByMe", "createdAt", "updatedAt", "deletedAt",
+}
+
+func TestCreateReply(t *testing.T) {
+	t.Run("reply inherits rootId and increments parent totalReplies", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT\s+COALESCE\(rootId, id\)\s+FROM\s+posts\s+WHERE\s+id = \$1\s+AND deletedAt IS NULL\s+FOR UPDATE`).
+			WithArgs("reply-1").
+			WillReturnRows(sqlmock.NewRows([]string{"rootId"}).AddRow("post-1"))
+		mock.ExpectExec(`INSERT INTO posts`).
+			WithArgs("reply-2", "reply-1", "post-1", "", "hi", "alice", "alice", "Alice", "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`totalReplies = totalReplies \+ 1`).
+			WithArgs("reply-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := s.CreateReply("reply-1", "reply-2", "", "hi", "alice", "alice", "Alice", ""); err != nil {
+			t.Fatalf("CreateReply = %v, want nil", err)
+		}
+	})
+
+	t.Run("deleted parent", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`COALESCE\(rootId, id\)`).
+			WithArgs("post-1").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		if err := s.CreateReply("post-1", "reply-1", "", "hi", "alice", "alice", "Alice", ""); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("CreateReply = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestDeletePostById(t *testing.T) {
+	t.Run("deleting a reply decrements parent totalReplies", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SET\s+deletedAt = \$1\s+WHERE\s+id = \$2\s+AND idUser = \$3\s+AND deletedAt IS NULL\s+RETURNING parentId`).
+			WithArgs(sqlmock.AnyArg(), "reply-1", "alice").
+			WillReturnRows(sqlmock.NewRows([]string{"parentId"}).AddRow("post-1"))
+		mock.ExpectExec(`DELETE FROM timeline WHERE postId = \$1`).
+			WithArgs("reply-1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`totalReplies = totalReplies - 1`).
+			WithArgs("post-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := s.DeletePostById("reply-1", "alice"); err != nil {
+			t.Fatalf("DeletePostById = %v, want nil", err)
+		}
+	})
+
+	t.Run("deleting a top-level post only cleans the timeline", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`RETURNING parentId`).
+			WithArgs(sqlmock.AnyArg(), "post-1", "alice").
+			WillReturnRows(sqlmock.NewRows([]string{"parentId"}).AddRow(nil))
+		mock.ExpectExec(`DELETE FROM timeline`).
+			WithArgs("post-1").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		if err := s.DeletePostById("post-1", "alice"); err != nil {
+			t.Fatalf("DeletePostById = %v, want nil", err)
+		}
+	})
+
+	t.Run("already deleted or not the owner", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`RETURNING parentId`).
+			WithArgs(sqlmock.AnyArg(), "post-1", "bob").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		if err := s.DeletePostById("post-1", "bob"); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("DeletePostById = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestGetPostByIdDeletedPlaceholder(t *testing.T) {
+	s, mock := newMockStorage(t)
+
+	// post yang dihapus hanya dikembalikan kalau masih punya reply
+	mock.ExpectPrepare(`deletedAt IS NULL\s+OR EXISTS \(SELECT 1 FROM posts replies WHERE replies.parentId = posts.id\)`).
+		ExpectQuery().
+		WithArgs("post-1", "bob").
+		WillReturnRows(sqlmock.NewRows(postColumns).
+			AddRow("post-1", "", "", "secret.png", "secret body", "alice", "alice", "Alice", "", int64(3), int64(2), false, int64(1700000000), int64(1700000000), int64(1700000100)))
+
+	post := &Post{}
+	if err := s.GetPostById("post-1", "bob", post); err != nil {
+		t.Fatal(err)
+	}
+
+	if post.Body != "" || post.Image != "" || post.DeletedAt != int64(1700000100) {
+		t.Errorf("GetPostById = body %q image %q deletedAt %v, want placeholder", post.Body, post.Image, post.DeletedAt)
+	}
+
+	if post.TotalReplies != 2 || post.IdUser != "alice" {
+		t.Errorf("placeholder lost thread metadata: %+v", post)
+	}
+}
+
+func TestListRepliesKeyset(t *testing.T) {
+	s, mock := newMockStorage(t)
+
+	// reply urut dari yang lama, halaman pertama mulai dari cursor nol
+	mock.ExpectPrepare(`parentId = \$1\s+AND \(createdAt, id\) > \(\$2, \$3\)`).
+		ExpectQuery().
+		WithArgs("post-1", int64(0), "", int32(2), "bob").
+		WillReturnRows(sqlmock.NewRows(postColumns).
+			AddRow("reply-1", "post-1", "post-1", "", "first", "carol", "carol", "Carol", "", int64(0), int64(1), false, int64(1700000001), int64(1700000001), int64(1700000050)).
+			AddRow("reply-2", "post-1", "post-1", "", "second", "dave", "dave", "Dave", "", int64(1), int64(0), true, int64(1700000002), int64(1700000002), nil))
+
+	replies := []Post{}
+	if err := s.ListReplies(library.KeysetCursor{}, "post-1", 2, "bob", &replies); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(replies) != 2 {
+		t.Fatalf("ListReplies returned %d replies, want 2", len(replies))
+	}
+
+	if replies[0].Body != "" || replies[0].DeletedAt != int64(1700000050) {
+		t.Errorf("deleted reply = %+v, want placeholder", replies[0])
+	}
+
+	if replies[1].Body != "second" || replies[1].DeletedAt != nil || !replies[1].LikedByMe {
+		t.Errorf("reply = %+v, want body second, not deleted, liked", replies[1])
+	}
+}
+
+func TestListFeedSources(t *testing.T) {
+	s, mock := newMockStorage(t)
+
+	// timeline + post sendiri + author diatas threshold + post pullOnly, author yang sudah di unfollow disaring
+	mock.ExpectPrepare(`FROM timeline WHERE timeline.idUser = \$3 .*` +
+		`idUser = \$3 AND parentId IS NULL .*` +
+		`idUser = ANY\(\$6\) AND parentId IS NULL .*` +
+		`pullOnly AND idUser = ANY\(\$5\) AND parentId IS NULL .*` +
+		`posts.deletedAt IS NULL .* AND \(posts.idUser = \$3 OR posts.idUser = ANY\(\$5\)\)`).
+		ExpectQuery().
+		WithArgs(int64(922337203685477), "", "viewer", int32(20), pq.Array([]string{"alice"}), pq.Array([]string{})).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "parentId", "rootId", "image", "body", "idUser", "username", "name", "profile",
+			"totalLikes", "totalReplies", "likedByMe", "createdAt", "updatedAt",
+		}))
+
+	posts := []Post{}
+	if err := s.ListFeed(library.KeysetCursor{}, 20, "viewer", []string{"alice"}, []string{}, &posts); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posts) != 0 {
+		t.Errorf("ListFeed = %+v, want empty", posts)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/pewe21/userProto"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -30,7 +31,10 @@ func NewRabbitMQ(cfg AppConfig, store *PostgresStorage) *RabbitMQ {
 	}
 }
 
-func (r *RabbitMQ) Run() {
+func (r *RabbitMQ) Run(userGrpcClient userProto.UserClient) {
+	timelineWorker := NewTimelineWorker(r.AmqpConn, r.Store, userGrpcClient, r.Cfg.FanoutThreshold)
+	go timelineWorker.Consume()
+
 	consumer := NewConsumer(r.AmqpConn, r.Store)
 	consumer.Consume(r.Cfg.RabbitMQHostname)
 }
@@ -8,16 +8,19 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/pewe21/imageProto"
+	"github.com/pewe21/library"
 	"github.com/pewe21/userProto"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 type AppServer struct {
-	Store  *PostgresStorage
-	Cfg    AppConfig
-	Server http.Server
+	Store                 *PostgresStorage
+	Cfg                   AppConfig
+	Server                http.Server
+	UserServiceGrpcClient userProto.UserClient
 }
 
-func NewServer(listenAddr string, store *PostgresStorage, cfg AppConfig) *AppServer {
+func NewServer(listenAddr string, store *PostgresStorage, cfg AppConfig, amqpConn *amqp.Connection) *AppServer {
 
 	userRB := &UserServiceResolverBuilder{
 		UserServiceHostname: cfg.UserServiceHostName,
@@ -42,9 +45,14 @@ func NewServer(listenAddr string, store *PostgresStorage, cfg AppConfig) *AppSer
 
 	userGrpcClient := userProto.NewUserClient(userServiceGrpcConn)
 	imageGrpcClient := imageProto.NewUserClient(imageServiceGrpcConn)
+
+	// JWTMiddleware tolak token yang dibuat sebelum tokensValidAfter user
+	library.SetTokensValidAfterSource(library.NewTokensValidAfterCache(userServiceTokensValidAfter(userGrpcClient), library.TOKENS_VALID_AFTER_CACHE_TTL))
+
 	routes := mux.NewRouter().PathPrefix("/v1/post").Subrouter()
 
-	userService := NewUserService(store, userGrpcClient, imageGrpcClient)
+	rabbitMQ := library.NewRabbitMq(amqpConn)
+	userService := NewUserService(store, userGrpcClient, imageGrpcClient, rabbitMQ, cfg.FanoutThreshold)
 	userService.RegisterRoutes(routes)
 
 	return &AppServer{
@@ -54,6 +62,7 @@ func NewServer(listenAddr string, store *PostgresStorage, cfg AppConfig) *AppSer
 			Addr:    listenAddr,
 			Handler: routes,
 		},
+		UserServiceGrpcClient: userGrpcClient,
 	}
 }
 
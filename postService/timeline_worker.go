@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pewe21/library"
+	"github.com/pewe21/userProto"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const POST_SERVICE_EXCHANGE = "postServiceExchange"
+
+// TIMELINE_RETRY_DELAY --> jeda sebelum event yang gagal di fan-out dikembalikan ke queue,
+// supaya userService/postgres yang sedang down tidak dibanjiri retry
+const TIMELINE_RETRY_DELAY = 2 * time.Second
+
+// TIMELINE_PREFETCH --> jumlah event yang belum di ack yang boleh dipegang worker
+const TIMELINE_PREFETCH = 10
+
+// TIMELINE_BACKFILL_LIMIT --> jumlah post terakhir author yang dimasukkan ke timeline saat di follow,
+// post yang lebih lama dari ini tidak muncul di feed follower baru
+const TIMELINE_BACKFILL_LIMIT = 50
+
+// errInvalidEvent --> event tidak bisa diproses sampai kapanpun, dibuang dan tidak di requeue
+var errInvalidEvent = errors.New("invalid event")
+
+type PostCreatedEvent struct {
+	Id        string `json:"id"`
+	IdUser    string `json:"idUser"`
+	ParentId  string `json:"parentId,omitempty"`
+	Image     string `json:"image,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// UserFollowedEvent --> event user.followed dari userService
+type UserFollowedEvent struct {
+	FollowerId  string `json:"followerId"`
+	FollowingId string `json:"followingId"`
+}
+
+type PostDeletedEvent struct {
+	Id     string `json:"id"`
+	IdUser string `json:"idUser"`
+	Image  string `json:"image,omitempty"`
+}
+
+// TimelineWorker --> consume event post.created dan fan-out post ke timeline setiap follower,
+// dan event user.followed untuk backfill timeline follower baru
+type TimelineWorker struct {
+	Conn                  *amqp.Connection
+	Store                 *PostgresStorage
+	UserServiceGrpcClient userProto.UserClient
+	FanoutThreshold       int64
+}
+
+func NewTimelineWorker(conn *amqp.Connection, store *PostgresStorage, userGrpcClient userProto.UserClient, fanoutThreshold int64) *TimelineWorker {
+	return &TimelineWorker{
+		Conn:                  conn,
+		Store:                 store,
+		UserServiceGrpcClient: userGrpcClient,
+		FanoutThreshold:       fanoutThreshold,
+	}
+}
+
+func (c *TimelineWorker) Consume() {
+	var wg sync.WaitGroup
+
+	ch, err := c.Conn.Channel()
+	if err != nil {
+		log.Println("Error when creating channel:", err)
+		return
+	}
+
+	defer ch.Close()
+
+	for _, exchange := range []string{POST_SERVICE_EXCHANGE, "userServiceExchange"} {
+		err = ch.ExchangeDeclare(
+			exchange,
+			"topic",
+			true,
+			false,
+			false,
+			false,
+			nil,
+		)
+		if err != nil {
+			log.Println("Error when declaring exchange:", err)
+		}
+	}
+
+	q, err := ch.QueueDeclare(
+		"postService_timeline_queue",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		log.Println("Error when declaring queue:", err)
+	}
+
+	bindings := []struct {
+		routingKey string
+		exchange   string
+	}{
+		{"post.created", POST_SERVICE_EXCHANGE},
+		{"user.followed", "userServiceExchange"},
+	}
+
+	for _, b := range bindings {
+		err = ch.QueueBind(
+			q.Name,
+			b.routingKey,
+			b.exchange,
+			false,
+			nil,
+		)
+		if err != nil {
+			log.Println("Error when binding queue:", err)
+		}
+	}
+
+	if err := ch.Qos(TIMELINE_PREFETCH, 0, false); err != nil {
+		log.Println("Error when setting qos:", err)
+	}
+
+	// manual ack --> event baru di ack setelah fan-out berhasil, kalau gagal dikembalikan ke queue
+	msgs, err := ch.Consume(
+		q.Name,
+		"",
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		log.Println("Error when consuming queue:", err)
+	}
+
+	wg.Add(1)
+	go func() {
+		for d := range msgs {
+			log.Println("New event receive:", d.RoutingKey, string(d.Body))
+
+			err := c.handleEvent(d.RoutingKey, d.Body)
+			switch {
+			case err == nil:
+				if err := d.Ack(false); err != nil {
+					log.Println("Error when acking event:", err)
+				}
+			case errors.Is(err, errInvalidEvent):
+				log.Println("Dropping timeline event:", err)
+				if err := d.Nack(false, false); err != nil {
+					log.Println("Error when nacking event:", err)
+				}
+			default:
+				log.Println("Requeue timeline event:", err)
+				time.Sleep(TIMELINE_RETRY_DELAY)
+				if err := d.Nack(false, true); err != nil {
+					log.Println("Error when nacking event:", err)
+				}
+			}
+		}
+		defer wg.Done()
+	}()
+	wg.Wait()
+}
+
+// handleEvent --> error selain errInvalidEvent berarti event nya perlu dicoba lagi
+func (c *TimelineWorker) handleEvent(routingKey string, data []byte) error {
+	switch routingKey {
+	case "post.created":
+		return c.handlePostCreated(data)
+	case "user.followed":
+		return c.handleUserFollowed(data)
+	}
+
+	return nil
+}
+
+// handleUserFollowed --> post terakhir author dimasukkan ke timeline follower baru, post yang dibuat
+// setelah follow masuk lewat fan-out post.created
+func (c *TimelineWorker) handleUserFollowed(data []byte) error {
+	event := &UserFollowedEvent{}
+
+	if err := json.Unmarshal(data, event); err != nil {
+		return errors.Join(errInvalidEvent, err)
+	}
+
+	if err := c.Store.BackfillTimeline(event.FollowerId, event.FollowingId, TIMELINE_BACKFILL_LIMIT); err != nil {
+		log.Println("Error when backfilling timeline:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (c *TimelineWorker) handlePostCreated(data []byte) error {
+	event := &PostCreatedEvent{}
+
+	if err := json.Unmarshal(data, event); err != nil {
+		return errors.Join(errInvalidEvent, err)
+	}
+
+	ctx := context.Background()
+
+	author, err := c.UserServiceGrpcClient.GetUserById(ctx, &userProto.GetUserByIdReq{Id: event.IdUser})
+	if err != nil {
+		log.Println("Error when dialing grpc client with getUserById method:", err)
+		// author nya tidak ada, dicoba ulang juga tetap gagal
+		if library.FromGrpcError(err).Code == library.ERR_NOT_FOUND {
+			return errors.Join(errInvalidEvent, err)
+		}
+		return err
+	}
+
+	// follower terlalu banyak, post nya ditandai pullOnly dan diambil langsung saat feed dibaca
+	if author.GetTotalFollower() > c.FanoutThreshold {
+		log.Println("skip fan-out, follower above threshold:", event.IdUser)
+		if err := c.Store.MarkPostPullOnly(event.Id); err != nil {
+			log.Println("Error when marking post pull only:", err)
+			return err
+		}
+		return nil
+	}
+
+	followers, err := c.UserServiceGrpcClient.ListFollowerById(ctx, &userProto.ListFollowerReq{Id: event.IdUser})
+	if err != nil {
+		log.Println("Error when dialing grpc client with ListFollowerById method:", err)
+		return err
+	}
+
+	if len(followers.GetIds()) == 0 {
+		return nil
+	}
+
+	if err := c.Store.InsertTimelineEntries(event.Id, followers.GetIds()); err != nil {
+		log.Println("Error when inserting timeline entries:", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/pewe21/userProto"
+)
+
+func newTestTimelineWorker(t *testing.T) (*TimelineWorker, sqlmock.Sqlmock) {
+	store, mock := newMockStorage(t)
+
+	users := &fakeUserClient{
+		users: map[string]*userProto.UserResp{
+			"alice":     {Id: "alice", TotalFollower: 2},
+			"celebrity": {Id: "celebrity", TotalFollower: 5000},
+			"loner":     {Id: "loner"},
+		},
+		followers: map[string][]string{
+			"alice":     {"bob", "carol"},
+			"celebrity": {"bob"},
+		},
+	}
+
+	return NewTimelineWorker(nil, store, users, 1000), mock
+}
+
+func TestTimelineWorkerPostCreated(t *testing.T) {
+	t.Run("fan-out to followers", func(t *testing.T) {
+		c, mock := newTestTimelineWorker(t)
+
+		mock.ExpectExec(`INSERT INTO timeline .* UNNEST\(\$2::TEXT\[\]\) .* ON CONFLICT \(idUser, postId\) DO NOTHING`).
+			WithArgs("post-1", pq.Array([]string{"bob", "carol"})).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		if err := c.handleEvent("post.created", []byte(`{"id":"post-1","idUser":"alice","createdAt":1700000000}`)); err != nil {
+			t.Fatalf("handleEvent = %v, want nil", err)
+		}
+	})
+
+	t.Run("author above threshold is pull-only", func(t *testing.T) {
+		c, mock := newTestTimelineWorker(t)
+
+		mock.ExpectPrepare(`SET\s+pullOnly = TRUE`).
+			ExpectExec().
+			WithArgs("post-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err := c.handleEvent("post.created", []byte(`{"id":"post-1","idUser":"celebrity"}`)); err != nil {
+			t.Fatalf("handleEvent = %v, want nil", err)
+		}
+	})
+
+	t.Run("author without followers", func(t *testing.T) {
+		c, _ := newTestTimelineWorker(t)
+
+		if err := c.handleEvent("post.created", []byte(`{"id":"post-1","idUser":"loner"}`)); err != nil {
+			t.Fatalf("handleEvent = %v, want nil", err)
+		}
+	})
+
+	t.Run("store error is retried", func(t *testing.T) {
+		c, mock := newTestTimelineWorker(t)
+
+		mock.ExpectExec(`INSERT INTO timeline`).
+			WithArgs("post-1", pq.Array([]string{"bob", "carol"})).
+			WillReturnError(errors.New("connection refused"))
+
+		err := c.handleEvent("post.created", []byte(`{"id":"post-1","idUser":"alice"}`))
+		if err == nil || errors.Is(err, errInvalidEvent) {
+			t.Fatalf("handleEvent = %v, want retryable error", err)
+		}
+	})
+
+	// event yang tidak akan pernah berhasil dibuang, bukan di requeue
+	invalid := []struct {
+		name string
+		data string
+	}{
+		{"unknown author", `{"id":"post-1","idUser":"ghost"}`},
+		{"malformed json", `{"id":`},
+	}
+
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestTimelineWorker(t)
+
+			if err := c.handleEvent("post.created", []byte(tt.data)); !errors.Is(err, errInvalidEvent) {
+				t.Fatalf("handleEvent = %v, want errInvalidEvent", err)
+			}
+		})
+	}
+}
+
+func TestTimelineWorkerUserFollowed(t *testing.T) {
+	c, mock := newTestTimelineWorker(t)
+
+	mock.ExpectExec(`INSERT INTO timeline .* idUser = \$2\s+AND parentId IS NULL\s+AND deletedAt IS NULL .* LIMIT \$3 ON CONFLICT \(idUser, postId\) DO NOTHING`).
+		WithArgs("bob", "alice", int32(TIMELINE_BACKFILL_LIMIT)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	if err := c.handleEvent("user.followed", []byte(`{"followerId":"bob","followingId":"alice"}`)); err != nil {
+		t.Fatalf("handleEvent = %v, want nil", err)
+	}
+
+	if err := c.handleEvent("user.followed", []byte(`not json`)); !errors.Is(err, errInvalidEvent) {
+		t.Errorf("handleEvent with malformed event = %v, want errInvalidEvent", err)
+	}
+
+	if err := c.handleEvent("post.deleted", []byte(`{}`)); err != nil {
+		t.Errorf("handleEvent with unknown routing key = %v, want nil", err)
+	}
+}
@@ -31,6 +31,15 @@ type UserClient interface {
 	DecrementFollowerById(ctx context.Context, in *RelationReq, opts ...grpc.CallOption) (*RelationResp, error)
 	IncrementFollowingById(ctx context.Context, in *RelationReq, opts ...grpc.CallOption) (*RelationResp, error)
 	DecrementFollowingById(ctx context.Context, in *RelationReq, opts ...grpc.CallOption) (*RelationResp, error)
+	ListFollowingById(ctx context.Context, in *ListFollowingReq, opts ...grpc.CallOption) (*ListFollowingResp, error)
+	ListFollowerById(ctx context.Context, in *ListFollowerReq, opts ...grpc.CallOption) (*ListFollowerResp, error)
+	GetTokensValidAfter(ctx context.Context, in *GetUserByIdReq, opts ...grpc.CallOption) (*TokensValidAfterResp, error)
+	RevokeUserTokens(ctx context.Context, in *GetUserByIdReq, opts ...grpc.CallOption) (*TokensValidAfterResp, error)
+	GetTwoFactor(ctx context.Context, in *GetUserByIdReq, opts ...grpc.CallOption) (*TwoFactorResp, error)
+	SaveTwoFactorSecret(ctx context.Context, in *SaveTwoFactorSecretReq, opts ...grpc.CallOption) (*TwoFactorResp, error)
+	EnableTwoFactor(ctx context.Context, in *EnableTwoFactorReq, opts ...grpc.CallOption) (*TwoFactorResp, error)
+	UseRecoveryCode(ctx context.Context, in *UseRecoveryCodeReq, opts ...grpc.CallOption) (*TwoFactorResp, error)
+	UseTotpStep(ctx context.Context, in *UseTotpStepReq, opts ...grpc.CallOption) (*TwoFactorResp, error)
 }
 
 type userClient struct {
@@ -122,6 +131,87 @@ func (c *userClient) DecrementFollowingById(ctx context.Context, in *RelationReq
 	return out, nil
 }
 
+func (c *userClient) ListFollowingById(ctx context.Context, in *ListFollowingReq, opts ...grpc.CallOption) (*ListFollowingResp, error) {
+	out := new(ListFollowingResp)
+	err := c.cc.Invoke(ctx, "/userProto.User/ListFollowingById", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) ListFollowerById(ctx context.Context, in *ListFollowerReq, opts ...grpc.CallOption) (*ListFollowerResp, error) {
+	out := new(ListFollowerResp)
+	err := c.cc.Invoke(ctx, "/userProto.User/ListFollowerById", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) GetTokensValidAfter(ctx context.Context, in *GetUserByIdReq, opts ...grpc.CallOption) (*TokensValidAfterResp, error) {
+	out := new(TokensValidAfterResp)
+	err := c.cc.Invoke(ctx, "/userProto.User/GetTokensValidAfter", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) RevokeUserTokens(ctx context.Context, in *GetUserByIdReq, opts ...grpc.CallOption) (*TokensValidAfterResp, error) {
+	out := new(TokensValidAfterResp)
+	err := c.cc.Invoke(ctx, "/userProto.User/RevokeUserTokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) GetTwoFactor(ctx context.Context, in *GetUserByIdReq, opts ...grpc.CallOption) (*TwoFactorResp, error) {
+	out := new(TwoFactorResp)
+	err := c.cc.Invoke(ctx, "/userProto.User/GetTwoFactor", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) SaveTwoFactorSecret(ctx context.Context, in *SaveTwoFactorSecretReq, opts ...grpc.CallOption) (*TwoFactorResp, error) {
+	out := new(TwoFactorResp)
+	err := c.cc.Invoke(ctx, "/userProto.User/SaveTwoFactorSecret", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) EnableTwoFactor(ctx context.Context, in *EnableTwoFactorReq, opts ...grpc.CallOption) (*TwoFactorResp, error) {
+	out := new(TwoFactorResp)
+	err := c.cc.Invoke(ctx, "/userProto.User/EnableTwoFactor", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) UseRecoveryCode(ctx context.Context, in *UseRecoveryCodeReq, opts ...grpc.CallOption) (*TwoFactorResp, error) {
+	out := new(TwoFactorResp)
+	err := c.cc.Invoke(ctx, "/userProto.User/UseRecoveryCode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) UseTotpStep(ctx context.Context, in *UseTotpStepReq, opts ...grpc.CallOption) (*TwoFactorResp, error) {
+	out := new(TwoFactorResp)
+	err := c.cc.Invoke(ctx, "/userProto.User/UseTotpStep", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserServer is the server API for User service.
 // All implementations must embed UnimplementedUserServer
 // for forward compatibility
@@ -135,6 +225,15 @@ type UserServer interface {
 	DecrementFollowerById(context.Context, *RelationReq) (*RelationResp, error)
 	IncrementFollowingById(context.Context, *RelationReq) (*RelationResp, error)
 	DecrementFollowingById(context.Context, *RelationReq) (*RelationResp, error)
+	ListFollowingById(context.Context, *ListFollowingReq) (*ListFollowingResp, error)
+	ListFollowerById(context.Context, *ListFollowerReq) (*ListFollowerResp, error)
+	GetTokensValidAfter(context.Context, *GetUserByIdReq) (*TokensValidAfterResp, error)
+	RevokeUserTokens(context.Context, *GetUserByIdReq) (*TokensValidAfterResp, error)
+	GetTwoFactor(context.Context, *GetUserByIdReq) (*TwoFactorResp, error)
+	SaveTwoFactorSecret(context.Context, *SaveTwoFactorSecretReq) (*TwoFactorResp, error)
+	EnableTwoFactor(context.Context, *EnableTwoFactorReq) (*TwoFactorResp, error)
+	UseRecoveryCode(context.Context, *UseRecoveryCodeReq) (*TwoFactorResp, error)
+	UseTotpStep(context.Context, *UseTotpStepReq) (*TwoFactorResp, error)
 	mustEmbedUnimplementedUserServer()
 }
 
@@ -169,6 +268,33 @@ func (UnimplementedUserServer) IncrementFollowingById(context.Context, *Relation
 func (UnimplementedUserServer) DecrementFollowingById(context.Context, *RelationReq) (*RelationResp, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DecrementFollowingById not implemented")
 }
+func (UnimplementedUserServer) ListFollowingById(context.Context, *ListFollowingReq) (*ListFollowingResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFollowingById not implemented")
+}
+func (UnimplementedUserServer) ListFollowerById(context.Context, *ListFollowerReq) (*ListFollowerResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFollowerById not implemented")
+}
+func (UnimplementedUserServer) GetTokensValidAfter(context.Context, *GetUserByIdReq) (*TokensValidAfterResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTokensValidAfter not implemented")
+}
+func (UnimplementedUserServer) RevokeUserTokens(context.Context, *GetUserByIdReq) (*TokensValidAfterResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeUserTokens not implemented")
+}
+func (UnimplementedUserServer) GetTwoFactor(context.Context, *GetUserByIdReq) (*TwoFactorResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTwoFactor not implemented")
+}
+func (UnimplementedUserServer) SaveTwoFactorSecret(context.Context, *SaveTwoFactorSecretReq) (*TwoFactorResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveTwoFactorSecret not implemented")
+}
+func (UnimplementedUserServer) EnableTwoFactor(context.Context, *EnableTwoFactorReq) (*TwoFactorResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnableTwoFactor not implemented")
+}
+func (UnimplementedUserServer) UseRecoveryCode(context.Context, *UseRecoveryCodeReq) (*TwoFactorResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UseRecoveryCode not implemented")
+}
+func (UnimplementedUserServer) UseTotpStep(context.Context, *UseTotpStepReq) (*TwoFactorResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UseTotpStep not implemented")
+}
 func (UnimplementedUserServer) mustEmbedUnimplementedUserServer() {}
 
 // UnsafeUserServer may be embedded to opt out of forward compatibility for this service.
@@ -344,6 +470,168 @@ func _User_DecrementFollowingById_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _User_ListFollowingById_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFollowingReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).ListFollowingById(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/userProto.User/ListFollowingById",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).ListFollowingById(ctx, req.(*ListFollowingReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_ListFollowerById_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFollowerReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).ListFollowerById(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/userProto.User/ListFollowerById",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).ListFollowerById(ctx, req.(*ListFollowerReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_GetTokensValidAfter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserByIdReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).GetTokensValidAfter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/userProto.User/GetTokensValidAfter",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).GetTokensValidAfter(ctx, req.(*GetUserByIdReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_RevokeUserTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserByIdReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).RevokeUserTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/userProto.User/RevokeUserTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).RevokeUserTokens(ctx, req.(*GetUserByIdReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_GetTwoFactor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserByIdReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).GetTwoFactor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/userProto.User/GetTwoFactor",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).GetTwoFactor(ctx, req.(*GetUserByIdReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_SaveTwoFactorSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SaveTwoFactorSecretReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).SaveTwoFactorSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/userProto.User/SaveTwoFactorSecret",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).SaveTwoFactorSecret(ctx, req.(*SaveTwoFactorSecretReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_EnableTwoFactor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnableTwoFactorReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).EnableTwoFactor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/userProto.User/EnableTwoFactor",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).EnableTwoFactor(ctx, req.(*EnableTwoFactorReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_UseRecoveryCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UseRecoveryCodeReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).UseRecoveryCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/userProto.User/UseRecoveryCode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).UseRecoveryCode(ctx, req.(*UseRecoveryCodeReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_UseTotpStep_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UseTotpStepReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).UseTotpStep(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/userProto.User/UseTotpStep",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).UseTotpStep(ctx, req.(*UseTotpStepReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // User_ServiceDesc is the grpc.ServiceDesc for User service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -387,6 +675,42 @@ var User_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DecrementFollowingById",
 			Handler:    _User_DecrementFollowingById_Handler,
 		},
+		{
+			MethodName: "ListFollowingById",
+			Handler:    _User_ListFollowingById_Handler,
+		},
+		{
+			MethodName: "ListFollowerById",
+			Handler:    _User_ListFollowerById_Handler,
+		},
+		{
+			MethodName: "GetTokensValidAfter",
+			Handler:    _User_GetTokensValidAfter_Handler,
+		},
+		{
+			MethodName: "RevokeUserTokens",
+			Handler:    _User_RevokeUserTokens_Handler,
+		},
+		{
+			MethodName: "GetTwoFactor",
+			Handler:    _User_GetTwoFactor_Handler,
+		},
+		{
+			MethodName: "SaveTwoFactorSecret",
+			Handler:    _User_SaveTwoFactorSecret_Handler,
+		},
+		{
+			MethodName: "EnableTwoFactor",
+			Handler:    _User_EnableTwoFactor_Handler,
+		},
+		{
+			MethodName: "UseRecoveryCode",
+			Handler:    _User_UseRecoveryCode_Handler,
+		},
+		{
+			MethodName: "UseTotpStep",
+			Handler:    _User_UseTotpStep_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "user.proto",
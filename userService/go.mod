@@ -3,6 +3,8 @@ module github.com/pewe21/userService
 go 1.22.0
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
 	github.com/pewe21/imageProto v0.0.0-00010101000000-000000000000
@@ -2,12 +2,13 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"database/sql"
+	"errors"
 	"log"
 	"net"
-	"strings"
 	"time"
 
+	"github.com/pewe21/library"
 	"github.com/pewe21/userProto"
 	"google.golang.org/grpc"
 )
@@ -53,7 +54,8 @@ func (s *GrpcServer) IncrementFollowerById(ctx context.Context, req *userProto.R
 
 	err := s.Store.IncrementFollowerById(id)
 	if err != nil {
-		return resp, err
+		log.Println("Error when incrementing follower:", err)
+		return resp, library.Internal(err)
 	}
 
 	resp.Message = "Increment success"
@@ -72,7 +74,8 @@ func (s *GrpcServer) DecrementFollowerById(ctx context.Context, req *userProto.R
 
 	err := s.Store.DecrementFollowerById(id)
 	if err != nil {
-		return resp, err
+		log.Println("Error when decrementing follower:", err)
+		return resp, library.Internal(err)
 	}
 
 	resp.Message = "Decrement success"
@@ -90,7 +93,8 @@ func (s *GrpcServer) IncrementFollowingById(ctx context.Context, req *userProto.
 
 	err := s.Store.IncrementFollowingById(id)
 	if err != nil {
-		return resp, err
+		log.Println("Error when incrementing following:", err)
+		return resp, library.Internal(err)
 	}
 
 	resp.Message = "Increment success"
@@ -108,7 +112,8 @@ func (s *GrpcServer) DecrementFollowingById(ctx context.Context, req *userProto.
 
 	err := s.Store.DecrementFollowingById(id)
 	if err != nil {
-		return resp, err
+		log.Println("Error when decrementing following:", err)
+		return resp, library.Internal(err)
 	}
 
 	resp.Message = "Increment success"
@@ -116,6 +121,54 @@ func (s *GrpcServer) DecrementFollowingById(ctx context.Context, req *userProto.
 	return resp, nil
 }
 
+func (s *GrpcServer) ListFollowingById(ctx context.Context, req *userProto.ListFollowingReq) (*userProto.ListFollowingResp, error) {
+
+	log.Println("hit list following by id grpc")
+
+	id := req.GetId()
+
+	resp := &userProto.ListFollowingResp{}
+
+	users := []FollowingCount{}
+
+	err := s.Store.ListAllFollowing(id, &users)
+	if err != nil {
+		log.Println("Error when listing following:", err)
+		return resp, library.Internal(err)
+	}
+
+	for _, user := range users {
+		resp.Ids = append(resp.Ids, user.Id)
+		resp.Users = append(resp.Users, &userProto.FollowingUser{
+			Id:            user.Id,
+			TotalFollower: user.TotalFollower,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *GrpcServer) ListFollowerById(ctx context.Context, req *userProto.ListFollowerReq) (*userProto.ListFollowerResp, error) {
+
+	log.Println("hit list follower by id grpc")
+
+	id := req.GetId()
+
+	resp := &userProto.ListFollowerResp{}
+
+	ids := []string{}
+
+	err := s.Store.ListFollowerIds(id, &ids)
+	if err != nil {
+		log.Println("Error when listing follower ids:", err)
+		return resp, library.Internal(err)
+	}
+
+	resp.Ids = ids
+
+	return resp, nil
+}
+
 func (s *GrpcServer) GetUserPasswordById(ctx context.Context, req *userProto.GetUserByIdReq) (*userProto.UserPasswordResp, error) {
 
 	log.Println("hit get user password by id grpc")
@@ -126,14 +179,18 @@ func (s *GrpcServer) GetUserPasswordById(ctx context.Context, req *userProto.Get
 
 	err := s.Store.GetUserPasswordById(id, user)
 	if err != nil {
-		return &userProto.UserPasswordResp{}, err
+		log.Println("Error when getting user password:", err)
+		return &userProto.UserPasswordResp{}, library.ClassifyPgError(err, ErrUserNotFound, nil)
 	}
 
 	returnUser := &userProto.UserPasswordResp{
-		Id:           user.Id,
-		HashPassword: user.HashPassword,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
+		Id:               user.Id,
+		HashPassword:     user.HashPassword,
+		Role:             user.Role,
+		SuspendedAt:      user.SuspendedAt,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
 	}
 
 	return returnUser, nil
@@ -148,14 +205,18 @@ func (s *GrpcServer) GetUserPasswordByUsername(ctx context.Context, req *userPro
 
 	err := s.Store.GetUserPasswordByUsername(username, user)
 	if err != nil {
-		return &userProto.UserPasswordResp{}, err
+		log.Println("Error when getting user password:", err)
+		return &userProto.UserPasswordResp{}, library.ClassifyPgError(err, ErrUserNotFound, nil)
 	}
 
 	returnUser := &userProto.UserPasswordResp{
-		Id:           user.Id,
-		HashPassword: user.HashPassword,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
+		Id:               user.Id,
+		HashPassword:     user.HashPassword,
+		Role:             user.Role,
+		SuspendedAt:      user.SuspendedAt,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
 	}
 
 	return returnUser, nil
@@ -178,10 +239,7 @@ func (s *GrpcServer) CreateUser(ctx context.Context, req *userProto.CreateUserRe
 		unixEpoch,
 	); err != nil {
 		log.Println("Error when inserting user:", err)
-		if strings.HasPrefix(err.Error(), "UNIQUE constraint failed") {
-			return resp, fmt.Errorf("username already used")
-		}
-		return resp, fmt.Errorf("something went wrong")
+		return resp, library.ClassifyPgError(err, nil, library.ErrUsernameTaken)
 	}
 
 	resp.Message = "User created!"
@@ -200,16 +258,21 @@ func (s *GrpcServer) GetUserById(ctx context.Context, req *userProto.GetUserById
 
 	err := s.Store.GetUserById(id, user)
 	if err != nil {
-		return &userProto.UserResp{}, err
+		log.Println("Error when getting user:", err)
+		return &userProto.UserResp{}, library.ClassifyPgError(err, ErrUserNotFound, nil)
 	}
 
 	returnUser := &userProto.UserResp{
-		Id:        user.Id,
-		Username:  user.Username,
-		Name:      user.Name,
-		Profile:   user.Profile,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+		Id:             user.Id,
+		Username:       user.Username,
+		Name:           user.Name,
+		Profile:        user.Profile,
+		TotalFollower:  user.TotalFollower,
+		TotalFollowing: user.TotalFollowing,
+		Role:           user.Role,
+		SuspendedAt:    user.SuspendedAt,
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
 	}
 
 	return returnUser, nil
@@ -226,17 +289,165 @@ func (s *GrpcServer) GetUserByUsername(ctx context.Context, req *userProto.GetUs
 
 	err := s.Store.GetUserByUsername(username, user)
 	if err != nil {
-		return &userProto.UserResp{}, err
+		log.Println("Error when getting user:", err)
+		return &userProto.UserResp{}, library.ClassifyPgError(err, ErrUserNotFound, nil)
 	}
 
 	returnUser := &userProto.UserResp{
-		Id:        user.Id,
-		Username:  user.Username,
-		Name:      user.Name,
-		Profile:   user.Profile,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+		Id:             user.Id,
+		Username:       user.Username,
+		Name:           user.Name,
+		Profile:        user.Profile,
+		TotalFollower:  user.TotalFollower,
+		TotalFollowing: user.TotalFollowing,
+		Role:           user.Role,
+		SuspendedAt:    user.SuspendedAt,
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
 	}
 
 	return returnUser, nil
 }
+
+func (s *GrpcServer) GetTokensValidAfter(ctx context.Context, req *userProto.GetUserByIdReq) (*userProto.TokensValidAfterResp, error) {
+	log.Println("hit get tokens valid after grpc")
+
+	id := req.GetId()
+
+	resp := &userProto.TokensValidAfterResp{Id: id}
+
+	if err := s.Store.GetTokensValidAfterById(id, &resp.TokensValidAfter); err != nil {
+		log.Println("Error when getting tokensValidAfter:", err)
+		return resp, library.ClassifyPgError(err, ErrUserNotFound, nil)
+	}
+
+	return resp, nil
+}
+
+func (s *GrpcServer) RevokeUserTokens(ctx context.Context, req *userProto.GetUserByIdReq) (*userProto.TokensValidAfterResp, error) {
+	log.Println("hit revoke user tokens grpc")
+
+	id := req.GetId()
+
+	resp := &userProto.TokensValidAfterResp{Id: id}
+
+	if err := s.Store.RevokeUserTokensById(id, &resp.TokensValidAfter); err != nil {
+		log.Println("Error when revoking user tokens:", err)
+		return resp, library.ClassifyPgError(err, ErrUserNotFound, nil)
+	}
+
+	return resp, nil
+}
+
+func (s *GrpcServer) GetTwoFactor(ctx context.Context, req *userProto.GetUserByIdReq) (*userProto.TwoFactorResp, error) {
+	log.Println("hit get two factor grpc")
+
+	id := req.GetId()
+
+	twoFactor := &TwoFactor{}
+
+	if err := s.Store.GetTwoFactorById(id, twoFactor); err != nil {
+		log.Println("Error when getting two factor:", err)
+		return &userProto.TwoFactorResp{}, library.ClassifyPgError(err, ErrUserNotFound, nil)
+	}
+
+	return twoFactorResp(twoFactor), nil
+}
+
+// SaveTwoFactorSecret --> FailedPrecondition kalau 2FA user sudah aktif
+func (s *GrpcServer) SaveTwoFactorSecret(ctx context.Context, req *userProto.SaveTwoFactorSecretReq) (*userProto.TwoFactorResp, error) {
+	log.Println("hit save two factor secret grpc")
+
+	id := req.GetId()
+
+	if req.GetEncryptedSecret() == "" {
+		return &userProto.TwoFactorResp{}, library.InvalidInput("secret is required")
+	}
+
+	if err := s.Store.SaveTwoFactorSecretById(id, req.GetEncryptedSecret()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.twoFactorError(id, library.FailedPrecondition("two factor already enabled"))
+		}
+		log.Println("Error when saving two factor secret:", err)
+		return &userProto.TwoFactorResp{}, library.Internal(err)
+	}
+
+	return s.GetTwoFactor(ctx, &userProto.GetUserByIdReq{Id: id})
+}
+
+// EnableTwoFactor --> FailedPrecondition kalau secret belum di setup atau 2FA nya sudah aktif
+func (s *GrpcServer) EnableTwoFactor(ctx context.Context, req *userProto.EnableTwoFactorReq) (*userProto.TwoFactorResp, error) {
+	log.Println("hit enable two factor grpc")
+
+	id := req.GetId()
+
+	if len(req.GetRecoveryCodeHashes()) == 0 {
+		return &userProto.TwoFactorResp{}, library.InvalidInput("recovery codes are required")
+	}
+
+	if err := s.Store.EnableTwoFactorById(id, req.GetRecoveryCodeHashes()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.twoFactorError(id, library.FailedPrecondition("two factor setup not started or already enabled"))
+		}
+		log.Println("Error when enabling two factor:", err)
+		return &userProto.TwoFactorResp{}, library.Internal(err)
+	}
+
+	return s.GetTwoFactor(ctx, &userProto.GetUserByIdReq{Id: id})
+}
+
+// UseRecoveryCode --> NotFound kalau recovery code salah atau sudah dipakai
+func (s *GrpcServer) UseRecoveryCode(ctx context.Context, req *userProto.UseRecoveryCodeReq) (*userProto.TwoFactorResp, error) {
+	log.Println("hit use recovery code grpc")
+
+	id := req.GetId()
+
+	if err := s.Store.UseRecoveryCode(id, req.GetCodeHash()); err != nil {
+		log.Println("Error when using recovery code:", err)
+		return &userProto.TwoFactorResp{}, library.ClassifyPgError(err, library.NotFound("recovery code not found"), nil)
+	}
+
+	return s.GetTwoFactor(ctx, &userProto.GetUserByIdReq{Id: id})
+}
+
+// UseTotpStep --> FailedPrecondition kalau kode TOTP di step itu atau sesudahnya sudah pernah dipakai
+func (s *GrpcServer) UseTotpStep(ctx context.Context, req *userProto.UseTotpStepReq) (*userProto.TwoFactorResp, error) {
+	log.Println("hit use totp step grpc")
+
+	id := req.GetId()
+
+	if req.GetStep() <= 0 {
+		return &userProto.TwoFactorResp{}, library.InvalidInput("step is required")
+	}
+
+	if err := s.Store.UseTotpStepById(id, req.GetStep()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.twoFactorError(id, library.FailedPrecondition("two factor code already used"))
+		}
+		log.Println("Error when using totp step:", err)
+		return &userProto.TwoFactorResp{}, library.Internal(err)
+	}
+
+	return s.GetTwoFactor(ctx, &userProto.GetUserByIdReq{Id: id})
+}
+
+// twoFactorError --> update yang tidak kena row bisa karena user nya tidak ada, bedakan dengan NotFound
+func (s *GrpcServer) twoFactorError(id string, appErr *library.AppError) (*userProto.TwoFactorResp, error) {
+	twoFactor := &TwoFactor{}
+
+	if err := s.Store.GetTwoFactorById(id, twoFactor); err != nil {
+		log.Println("Error when getting two factor:", err)
+		return &userProto.TwoFactorResp{}, library.ClassifyPgError(err, ErrUserNotFound, nil)
+	}
+
+	return &userProto.TwoFactorResp{}, appErr
+}
+
+func twoFactorResp(twoFactor *TwoFactor) *userProto.TwoFactorResp {
+	return &userProto.TwoFactorResp{
+		Id:                     twoFactor.Id,
+		EncryptedSecret:        twoFactor.EncryptedSecret,
+		Enabled:                twoFactor.Enabled,
+		RemainingRecoveryCodes: twoFactor.RemainingRecoveryCodes,
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/pewe21/library"
 )
 
 // http port
@@ -39,6 +41,9 @@ func main() {
 	postgresStorage.db.SetMaxIdleConns(25)
 	postgresStorage.db.SetConnMaxLifetime(5 * time.Minute)
 
+	// JWTMiddleware tolak token yang dibuat sebelum tokensValidAfter user
+	library.SetTokensValidAfterSource(library.NewTokensValidAfterCache(postgresStorage, library.TOKENS_VALID_AFTER_CACHE_TTL))
+
 	//grpcServer :4002
 	grpcServer := NewGrpcServer(GRPCPORT, postgresStorage)
 
@@ -80,5 +85,12 @@ func main() {
 		log.Println("GRPC server closed")
 	}()
 
+	// shutdown rabbitmq
+	if err := httpServer.AmqpConn.Close(); err != nil {
+		log.Println("Error when closing rabbitMQ", err)
+	} else {
+		log.Println("rabbitMQ connection closed..")
+	}
+
 	wg.Wait()
 }
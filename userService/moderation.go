@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pewe21/library"
+)
+
+// handleAdminSuspendUser --> suspend user {id}, semua token user langsung tidak valid dan user tidak bisa login
+func (s *UserService) handleAdminSuspendUser(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle admin suspend user")
+
+	return s.moderateUser(w, r, library.MODERATION_SUSPEND_USER, s.Store.SuspendUserById, "User suspended!")
+}
+
+// handleAdminRestoreUser --> cabut suspend user {id}
+func (s *UserService) handleAdminRestoreUser(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle admin restore user")
+
+	return s.moderateUser(w, r, library.MODERATION_RESTORE_USER, s.Store.RestoreUserById, "User restored!")
+}
+
+func (s *UserService) moderateUser(w http.ResponseWriter, r *http.Request, action string, moderate func(string, *library.ModerationLog) error, message string) (int, error) {
+	vars := mux.Vars(r)
+	targetId := vars["id"]
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+
+	if targetId == principal.UserId {
+		return http.StatusBadRequest, fmt.Errorf("cannot moderate your own account")
+	}
+
+	reason, err := library.ReadModerationReason(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	entry := &library.ModerationLog{
+		Id:         uuid.NewString(),
+		ActorId:    principal.UserId,
+		Action:     action,
+		TargetType: "user",
+		TargetId:   targetId,
+		Reason:     reason,
+	}
+
+	if err := moderate(targetId, entry); err != nil {
+		log.Println("Error when moderating user:", err)
+		appErr := library.ClassifyPgError(err, ErrUserNotFound, nil)
+		return appErr.HttpStatus, appErr
+	}
+
+	resp := library.NewResp(message, map[string]interface{}{"moderation": entry})
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+// handleAdminListDeletedUsers --> list user yang sudah di soft delete, cursor nya "deletedAt_id"
+func (s *UserService) handleAdminListDeletedUsers(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle admin list deleted users")
+
+	urlQuery := r.URL.Query()
+	cursor := library.ParseKeysetCursor(urlQuery.Get("cursor"))
+	limit := library.ParsePageLimit(urlQuery.Get("limit"))
+
+	users := &[]DeletedUser{}
+
+	if err := s.Store.ListDeletedUsers(cursor, limit, users); err != nil {
+		log.Println("Error when listing deleted users:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	meta := struct {
+		Cursor string `json:"cursor"`
+	}{}
+
+	if len(*users) > 0 {
+		last := (*users)[len(*users)-1]
+		meta.Cursor = library.KeysetCursor{CreatedAt: last.DeletedAt, Id: last.Id}.String()
+	}
+
+	resp := library.NewResp("success", map[string]interface{}{
+		"users": users,
+		"meta":  meta,
+	})
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/pewe21/library"
 )
 
 type PostgresStorage struct {
@@ -42,6 +45,30 @@ func (s *PostgresStorage) Init() {
 	if err := s.createUserTable(); err != nil {
 		log.Fatal(err)
 	}
+
+	if err := s.createFollowTable(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.alterUserTableTokensValidAfter(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.alterUserTableModeration(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.createModerationLogTable(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.alterUserTableTwoFactor(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := s.createRecoveryCodeTable(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func (s *PostgresStorage) createUserTable() error {
@@ -66,13 +93,114 @@ func (s *PostgresStorage) createUserTable() error {
 	return nil
 }
 
+// follows --> relasi follower/following, followerId follow followingId
+func (s *PostgresStorage) createFollowTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS follows (
+            followerId TEXT NOT NULL REFERENCES users(id),
+            followingId TEXT NOT NULL REFERENCES users(id),
+
+            createdAt INTEGER NOT NULL,
+            PRIMARY KEY (followerId, followingId)
+        )`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        CREATE INDEX IF NOT EXISTS follows_following_idx
+        ON follows (followingId, createdAt)`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// tokensValidAfter --> unix time milidetik, access/refresh token user yang dibuat sebelum waktu ini ditolak.
+// Nilai lama masih dalam detik (revoke + 1), dikonversi ke milidetik sekali saat kolom diubah ke BIGINT
+func (s *PostgresStorage) alterUserTableTokensValidAfter() error {
+	_, err := s.db.Exec(`
+        ALTER TABLE users ADD COLUMN IF NOT EXISTS tokensValidAfter BIGINT DEFAULT 0 NOT NULL`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+        DO $$
+        BEGIN
+            IF (SELECT data_type FROM information_schema.columns
+                WHERE table_name = 'users' AND column_name = 'tokensvalidafter') = 'integer' THEN
+                ALTER TABLE users ALTER COLUMN tokensValidAfter TYPE BIGINT;
+                UPDATE users SET tokensValidAfter = tokensValidAfter * 1000 WHERE tokensValidAfter > 0;
+            END IF;
+        END $$`)
+
+	return err
+}
+
+// role --> library.ROLE_USER/ROLE_ADMIN, admin diset manual lewat database.
+// suspendedAt --> unix time user di suspend admin, user yang di suspend tidak bisa login
+func (s *PostgresStorage) alterUserTableModeration() error {
+	_, err := s.db.Exec(`
+        ALTER TABLE users
+            ADD COLUMN IF NOT EXISTS role TEXT DEFAULT 'user' NOT NULL,
+            ADD COLUMN IF NOT EXISTS suspendedAt INTEGER`)
+
+	return err
+}
+
+// moderation_logs --> audit log aksi admin terhadap user
+func (s *PostgresStorage) createModerationLogTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS moderation_logs (
+            id TEXT PRIMARY KEY,
+            actorId TEXT NOT NULL,
+            action TEXT NOT NULL,
+            targetType TEXT NOT NULL,
+            targetId TEXT NOT NULL,
+            reason TEXT NOT NULL,
+
+            createdAt INTEGER NOT NULL
+        )`)
+
+	return err
+}
+
+// totpSecret --> secret TOTP yang sudah dienkripsi authService, totpEnabledAt terisi setelah user verifikasi kode pertama.
+// totpLastStep --> step TOTP terakhir yang diterima, supaya satu kode tidak bisa dipakai dua kali
+func (s *PostgresStorage) alterUserTableTwoFactor() error {
+	_, err := s.db.Exec(`
+        ALTER TABLE users
+            ADD COLUMN IF NOT EXISTS totpSecret TEXT,
+            ADD COLUMN IF NOT EXISTS totpEnabledAt INTEGER,
+            ADD COLUMN IF NOT EXISTS totpLastStep BIGINT DEFAULT 0 NOT NULL`)
+
+	return err
+}
+
+// user_recovery_codes --> recovery code 2FA (sha256 hex), satu code hanya bisa dipakai sekali
+func (s *PostgresStorage) createRecoveryCodeTable() error {
+	_, err := s.db.Exec(`
+        CREATE TABLE IF NOT EXISTS user_recovery_codes (
+            userId TEXT NOT NULL REFERENCES users(id),
+            codeHash TEXT NOT NULL,
+
+            createdAt INTEGER NOT NULL,
+            usedAt INTEGER,
+            PRIMARY KEY (userId, codeHash)
+        )`)
+
+	return err
+}
+
 func (s *PostgresStorage) UpdateProfileById(profileUrl, id string) error {
 	stmt, err := s.db.Prepare(`
         UPDATE users
         SET
-            profile = ?
+            profile = $1
         WHERE
-            id = ?
+            id = $2
         `)
 	if err != nil {
 		return err
@@ -91,7 +219,7 @@ func (s *PostgresStorage) IncrementFollowerById(id string) error {
         SET
             totalFollower = totalFollower + 1
         WHERE
-            id = ?
+            id = $1
         `)
 	if err != nil {
 		return err
@@ -110,7 +238,7 @@ func (s *PostgresStorage) DecrementFollowerById(id string) error {
         SET
             totalFollower = totalFollower - 1
         WHERE
-            id = ?
+            id = $1
         `)
 	if err != nil {
 		return err
@@ -129,7 +257,7 @@ func (s *PostgresStorage) IncrementFollowingById(id string) error {
         SET
             totalFollowing = totalFollowing + 1
         WHERE
-            id = ?
+            id = $1
         `)
 	if err != nil {
 		return err
@@ -148,7 +276,7 @@ func (s *PostgresStorage) DecrementFollowingById(id string) error {
         SET
             totalFollowing = totalFollowing - 1
         WHERE
-            id = ?
+            id = $1
         `)
 	if err != nil {
 		return err
@@ -202,6 +330,9 @@ func (s *PostgresStorage) GetUserPasswordByUsername(username string, user *User)
         id,
         username,
         hashPassword,
+        role,
+        COALESCE(suspendedAt, 0),
+        totpEnabledAt IS NOT NULL,
         createdAt,
         updatedAt 
         FROM users WHERE username = $1 AND deletedAt IS NULL`)
@@ -215,6 +346,9 @@ func (s *PostgresStorage) GetUserPasswordByUsername(username string, user *User)
 		&user.Id,
 		&user.Username,
 		&user.HashPassword,
+		&user.Role,
+		&user.SuspendedAt,
+		&user.TwoFactorEnabled,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	); err != nil {
@@ -228,6 +362,9 @@ func (s *PostgresStorage) GetUserPasswordById(id string, user *User) error {
         SELECT 
         id,
         hashPassword,
+        role,
+        COALESCE(suspendedAt, 0),
+        totpEnabledAt IS NOT NULL,
         createdAt,
         updatedAt 
         FROM users WHERE id = $1 AND deletedAt IS NULL`)
@@ -240,6 +377,9 @@ func (s *PostgresStorage) GetUserPasswordById(id string, user *User) error {
 	if err := stmt.QueryRow(id).Scan(
 		&user.Id,
 		&user.HashPassword,
+		&user.Role,
+		&user.SuspendedAt,
+		&user.TwoFactorEnabled,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	); err != nil {
@@ -254,41 +394,94 @@ func (s *PostgresStorage) UpdateUserPasswordById(newPassword, id string) error {
         UPDATE users
         SET 
             hashPassword = $1,
-            updatedAt = $2
-        WHERE id = $3`)
+            updatedAt = $2,
+            tokensValidAfter = $3
+        WHERE id = $4`)
 	if err != nil {
 		return err
 	}
 
 	defer stmt.Close()
 
-	unixEpoch := time.Now().Unix()
+	now := time.Now()
 
-	if _, err := stmt.Exec(newPassword, unixEpoch, id); err != nil {
+	if _, err := stmt.Exec(newPassword, now.Unix(), tokensValidAfterNow(now), id); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *PostgresStorage) DeleteUserById(id string) error {
+// DeleteUserById --> soft delete user, profile diisi profile user supaya referensi image nya bisa dilepas.
+// Return sql.ErrNoRows kalau user tidak ada atau sudah dihapus
+func (s *PostgresStorage) DeleteUserById(id string, profile *string) error {
 	stmt, err := s.db.Prepare(`
         UPDATE users
-        SET deletedAt = $1
-        WHERE id = $2`)
+        SET
+            deletedAt = $1,
+            tokensValidAfter = $2
+        WHERE
+            id = $3
+            AND deletedAt IS NULL
+        RETURNING profile`)
 	if err != nil {
 		return err
 	}
 
 	defer stmt.Close()
 
-	unixEpoch := time.Now().Unix()
+	now := time.Now()
 
-	if _, err := stmt.Exec(unixEpoch, id); err != nil {
+	return stmt.QueryRow(now.Unix(), tokensValidAfterNow(now), id).Scan(profile)
+}
+
+func (s *PostgresStorage) GetTokensValidAfterById(id string, validAfter *int64) error {
+	stmt, err := s.db.Prepare(`
+        SELECT tokensValidAfter
+        FROM users WHERE id = $1`)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	defer stmt.Close()
+
+	return stmt.QueryRow(id).Scan(validAfter)
+}
+
+// TokensValidAfter --> implementasi library.TokensValidAfterSource untuk JWTMiddleware
+func (s *PostgresStorage) TokensValidAfter(ctx context.Context, userId string) (time.Time, error) {
+	var validAfter int64
+
+	if err := s.GetTokensValidAfterById(userId, &validAfter); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, library.ErrUnknownTokenSubject
+		}
+		return time.Time{}, err
+	}
+
+	return time.UnixMilli(validAfter), nil
+}
+
+// RevokeUserTokensById --> semua token user yang sudah dibuat jadi tidak valid (logout-all)
+func (s *PostgresStorage) RevokeUserTokensById(id string, validAfter *int64) error {
+	stmt, err := s.db.Prepare(`
+        UPDATE users
+        SET tokensValidAfter = $1
+        WHERE id = $2
+        RETURNING tokensValidAfter`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	return stmt.QueryRow(tokensValidAfterNow(time.Now()), id).Scan(validAfter)
+}
+
+// tokensValidAfterNow --> tokensValidAfter untuk revoke sekarang, presisi milidetik sama dengan iat token
+// supaya login ulang di detik yang sama dengan revoke tetap valid
+func tokensValidAfterNow(now time.Time) int64 {
+	return now.UnixMilli()
 }
 
 func (s *PostgresStorage) UpdateUserNameAndProfile(name, profile, id string) error {
@@ -320,6 +513,10 @@ func (s *PostgresStorage) GetUserByUsername(username string, user *ReturnUser) e
         username,
         name,
         profile,
+        totalFollower,
+        totalFollowing,
+        role,
+        COALESCE(suspendedAt, 0),
         createdAt,
         updatedAt 
         FROM users WHERE username = $1 AND deletedAt IS NULL`)
@@ -334,6 +531,10 @@ func (s *PostgresStorage) GetUserByUsername(username string, user *ReturnUser) e
 		&user.Username,
 		&user.Name,
 		&user.Profile,
+		&user.TotalFollower,
+		&user.TotalFollowing,
+		&user.Role,
+		&user.SuspendedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	); err != nil {
@@ -350,6 +551,10 @@ func (s *PostgresStorage) GetUserById(id string, user *ReturnUser) error {
         username,
         name,
         profile,
+        totalFollower,
+        totalFollowing,
+        role,
+        COALESCE(suspendedAt, 0),
         createdAt,
         updatedAt
         FROM users WHERE id = $1`)
@@ -364,6 +569,10 @@ func (s *PostgresStorage) GetUserById(id string, user *ReturnUser) error {
 		&user.Username,
 		&user.Name,
 		&user.Profile,
+		&user.TotalFollower,
+		&user.TotalFollowing,
+		&user.Role,
+		&user.SuspendedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	); err != nil {
@@ -372,3 +581,541 @@ func (s *PostgresStorage) GetUserById(id string, user *ReturnUser) error {
 
 	return nil
 }
+
+// FollowUser --> followerId follow followingId, counter diupdate dalam transaksi yang sama
+// supaya totalFollower/totalFollowing tidak drift. Follow ulang tidak menambah counter.
+func (s *PostgresStorage) FollowUser(followerId, followingId string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`
+        SELECT 1 FROM users WHERE id = $1 AND deletedAt IS NULL`, followingId).Scan(&exists); err != nil {
+		return err
+	}
+
+	unixEpoch := time.Now().Unix()
+
+	res, err := tx.Exec(`
+        INSERT INTO follows (
+            followerId,
+            followingId,
+            createdAt
+        ) VALUES ($1,$2,$3)
+        ON CONFLICT (followerId, followingId) DO NOTHING`, followerId, followingId, unixEpoch)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// sudah follow sebelumnya
+	if inserted == 0 {
+		return tx.Commit()
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE users
+        SET
+            totalFollowing = totalFollowing + 1
+        WHERE
+            id = $1`, followerId); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE users
+        SET
+            totalFollower = totalFollower + 1
+        WHERE
+            id = $1`, followingId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnfollowUser --> kebalikan FollowUser, unfollow yang belum di follow tidak mengurangi counter
+func (s *PostgresStorage) UnfollowUser(followerId, followingId string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+        DELETE FROM follows
+        WHERE
+            followerId = $1
+            AND followingId = $2`, followerId, followingId)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return tx.Commit()
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE users
+        SET
+            totalFollowing = totalFollowing - 1
+        WHERE
+            id = $1`, followerId); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE users
+        SET
+            totalFollower = totalFollower - 1
+        WHERE
+            id = $1`, followingId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListFollowers --> list user yang follow userId
+func (s *PostgresStorage) ListFollowers(cursor library.KeysetCursor, userId string, limit int32, users *[]FollowUser) error {
+	return s.listFollows(`
+        SELECT
+            users.id,
+            users.username,
+            users.name,
+            users.profile,
+            follows.createdAt
+        FROM
+            follows
+            JOIN users ON users.id = follows.followerId
+        WHERE
+            follows.followingId = $1
+            AND users.deletedAt IS NULL
+            AND (follows.createdAt, follows.followerId) < ($2, $3)
+        ORDER BY
+            follows.createdAt DESC,
+            follows.followerId DESC
+        LIMIT $4`, cursor, userId, limit, users)
+}
+
+// ListFollowing --> list user yang di follow oleh userId
+func (s *PostgresStorage) ListFollowing(cursor library.KeysetCursor, userId string, limit int32, users *[]FollowUser) error {
+	return s.listFollows(`
+        SELECT
+            users.id,
+            users.username,
+            users.name,
+            users.profile,
+            follows.createdAt
+        FROM
+            follows
+            JOIN users ON users.id = follows.followingId
+        WHERE
+            follows.followerId = $1
+            AND users.deletedAt IS NULL
+            AND (follows.createdAt, follows.followingId) < ($2, $3)
+        ORDER BY
+            follows.createdAt DESC,
+            follows.followingId DESC
+        LIMIT $4`, cursor, userId, limit, users)
+}
+
+// listFollows --> keyset pagination (createdAt, id) yang terbaru duluan
+func (s *PostgresStorage) listFollows(queryStr string, cursor library.KeysetCursor, userId string, limit int32, users *[]FollowUser) error {
+	stmt, err := s.db.Prepare(queryStr)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	if cursor.IsZero() {
+		cursor.CreatedAt = 922337203685477
+	}
+
+	rows, err := stmt.Query(userId, cursor.CreatedAt, cursor.Id, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var user FollowUser
+		if err := rows.Scan(
+			&user.Id,
+			&user.Username,
+			&user.Name,
+			&user.Profile,
+			&user.FollowedAt,
+		); err != nil {
+			return err
+		}
+		*users = append(*users, user)
+	}
+
+	return rows.Err()
+}
+
+// ListAllFollowing --> semua user yang di follow oleh userId beserta jumlah followernya,
+// dipakai postService untuk feed
+func (s *PostgresStorage) ListAllFollowing(userId string, users *[]FollowingCount) error {
+	stmt, err := s.db.Prepare(`
+        SELECT
+            users.id,
+            users.totalFollower
+        FROM
+            follows
+            JOIN users ON users.id = follows.followingId
+        WHERE
+            follows.followerId = $1
+            AND users.deletedAt IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(userId)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var user FollowingCount
+		if err := rows.Scan(&user.Id, &user.TotalFollower); err != nil {
+			return err
+		}
+		*users = append(*users, user)
+	}
+
+	return rows.Err()
+}
+
+// ListFollowerIds --> semua id follower userId, dipakai timeline worker postService untuk fan-out
+func (s *PostgresStorage) ListFollowerIds(userId string, ids *[]string) error {
+	stmt, err := s.db.Prepare(`
+        SELECT
+            follows.followerId
+        FROM
+            follows
+            JOIN users ON users.id = follows.followerId
+        WHERE
+            follows.followingId = $1
+            AND users.deletedAt IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(userId)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		*ids = append(*ids, id)
+	}
+
+	return rows.Err()
+}
+
+// SuspendUserById --> suspend user dan revoke semua token nya, audit log ditulis dalam transaksi yang sama.
+// Suspend ulang tidak mengubah suspendedAt
+func (s *PostgresStorage) SuspendUserById(id string, entry *library.ModerationLog) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now()
+	unixEpoch := now.Unix()
+
+	var userId string
+	if err := tx.QueryRow(`
+        UPDATE users
+        SET
+            suspendedAt = COALESCE(suspendedAt, $1),
+            tokensValidAfter = $2
+        WHERE
+            id = $3
+            AND deletedAt IS NULL
+        RETURNING id
+        `, unixEpoch, tokensValidAfterNow(now), id).Scan(&userId); err != nil {
+		return err
+	}
+
+	if err := insertModerationLog(tx, entry, unixEpoch); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RestoreUserById --> cabut suspend user, token lama tetap tidak valid jadi user harus login ulang
+func (s *PostgresStorage) RestoreUserById(id string, entry *library.ModerationLog) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	unixEpoch := time.Now().Unix()
+
+	var userId string
+	if err := tx.QueryRow(`
+        UPDATE users
+        SET
+            suspendedAt = NULL
+        WHERE
+            id = $1
+            AND deletedAt IS NULL
+        RETURNING id
+        `, id).Scan(&userId); err != nil {
+		return err
+	}
+
+	if err := insertModerationLog(tx, entry, unixEpoch); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertModerationLog(tx *sql.Tx, entry *library.ModerationLog, createdAt int64) error {
+	entry.CreatedAt = createdAt
+
+	_, err := tx.Exec(`
+        INSERT INTO moderation_logs (id, actorId, action, targetType, targetId, reason, createdAt)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.Id,
+		entry.ActorId,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetId,
+		entry.Reason,
+		entry.CreatedAt)
+
+	return err
+}
+
+// ListDeletedUsers --> user yang sudah di soft delete, urut dari deletedAt terbaru.
+// Keyset pagination (deletedAt, id), CreatedAt di cursor berisi deletedAt
+func (s *PostgresStorage) ListDeletedUsers(cursor library.KeysetCursor, limit int32, users *[]DeletedUser) error {
+	stmt, err := s.db.Prepare(`
+        SELECT
+            id,
+            username,
+            name,
+            profile,
+            createdAt,
+            deletedAt
+        FROM
+            users
+        WHERE
+            deletedAt IS NOT NULL
+            AND (deletedAt, id) < ($1, $2)
+        ORDER BY
+            deletedAt DESC,
+            id DESC
+        LIMIT $3`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	if cursor.IsZero() {
+		cursor.CreatedAt = 922337203685477
+	}
+
+	rows, err := stmt.Query(cursor.CreatedAt, cursor.Id, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var user DeletedUser
+		if err := rows.Scan(
+			&user.Id,
+			&user.Username,
+			&user.Name,
+			&user.Profile,
+			&user.CreatedAt,
+			&user.DeletedAt,
+		); err != nil {
+			return err
+		}
+		*users = append(*users, user)
+	}
+
+	return rows.Err()
+}
+
+func (s *PostgresStorage) GetTwoFactorById(id string, twoFactor *TwoFactor) error {
+	stmt, err := s.db.Prepare(`
+        SELECT
+            id,
+            COALESCE(totpSecret, ''),
+            totpEnabledAt IS NOT NULL,
+            (
+                SELECT COUNT(*) FROM user_recovery_codes
+                WHERE user_recovery_codes.userId = users.id AND usedAt IS NULL
+            )
+        FROM users WHERE id = $1 AND deletedAt IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	return stmt.QueryRow(id).Scan(
+		&twoFactor.Id,
+		&twoFactor.EncryptedSecret,
+		&twoFactor.Enabled,
+		&twoFactor.RemainingRecoveryCodes,
+	)
+}
+
+// SaveTwoFactorSecretById --> simpan secret baru selama 2FA belum aktif, setup ulang menimpa secret lama.
+// sql.ErrNoRows kalau user tidak ada atau 2FA nya sudah aktif
+func (s *PostgresStorage) SaveTwoFactorSecretById(id, encryptedSecret string) error {
+	stmt, err := s.db.Prepare(`
+        UPDATE users
+        SET
+            totpSecret = $1,
+            updatedAt = $2
+        WHERE
+            id = $3
+            AND deletedAt IS NULL
+            AND totpEnabledAt IS NULL
+        RETURNING id`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	unixEpoch := time.Now().Unix()
+
+	var userId string
+	return stmt.QueryRow(encryptedSecret, unixEpoch, id).Scan(&userId)
+}
+
+// EnableTwoFactorById --> aktifkan 2FA dan ganti semua recovery code dalam satu transaksi.
+// sql.ErrNoRows kalau secret belum di setup atau 2FA nya sudah aktif
+func (s *PostgresStorage) EnableTwoFactorById(id string, recoveryCodeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	unixEpoch := time.Now().Unix()
+
+	var userId string
+	if err := tx.QueryRow(`
+        UPDATE users
+        SET
+            totpEnabledAt = $1,
+            updatedAt = $1
+        WHERE
+            id = $2
+            AND deletedAt IS NULL
+            AND totpSecret IS NOT NULL
+            AND totpEnabledAt IS NULL
+        RETURNING id
+        `, unixEpoch, id).Scan(&userId); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+        DELETE FROM user_recovery_codes WHERE userId = $1`, id); err != nil {
+		return err
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err := tx.Exec(`
+            INSERT INTO user_recovery_codes (userId, codeHash, createdAt)
+            VALUES ($1, $2, $3)`, id, codeHash, unixEpoch); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode --> tandai recovery code sudah dipakai secara atomic, sql.ErrNoRows kalau code nya salah/sudah dipakai
+func (s *PostgresStorage) UseRecoveryCode(id, codeHash string) error {
+	stmt, err := s.db.Prepare(`
+        UPDATE user_recovery_codes
+        SET
+            usedAt = $1
+        WHERE
+            userId = $2
+            AND codeHash = $3
+            AND usedAt IS NULL
+        RETURNING userId`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	unixEpoch := time.Now().Unix()
+
+	var userId string
+	return stmt.QueryRow(unixEpoch, id, codeHash).Scan(&userId)
+}
+
+// UseTotpStepById --> simpan step TOTP yang diterima secara atomic, sql.ErrNoRows kalau step nya
+// tidak lebih baru dari step terakhir (kode dipakai ulang) atau secret belum di setup
+func (s *PostgresStorage) UseTotpStepById(id string, step int64) error {
+	stmt, err := s.db.Prepare(`
+        UPDATE users
+        SET
+            totpLastStep = $1
+        WHERE
+            id = $2
+            AND deletedAt IS NULL
+            AND totpSecret IS NOT NULL
+            AND totpLastStep < $1
+        RETURNING id`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	var userId string
+	return stmt.QueryRow(step, id).Scan(&userId)
+}
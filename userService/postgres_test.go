@@ -0,0 +1,176 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pewe21/library"
+)
+
+// newMockStorage --> PostgresStorage dengan sqlmock, query dicocokkan dengan regex
+func newMockStorage(t *testing.T) (*PostgresStorage, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return &PostgresStorage{db: db}, mock
+}
+
+func TestFollowUser(t *testing.T) {
+	t.Run("new follow updates both counters", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT 1 FROM users WHERE id = \$1 AND deletedAt IS NULL`).
+			WithArgs("bob").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO follows .* ON CONFLICT \(followerId, followingId\) DO NOTHING`).
+			WithArgs("alice", "bob", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`totalFollowing = totalFollowing \+ 1`).
+			WithArgs("alice").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`totalFollower = totalFollower \+ 1`).
+			WithArgs("bob").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := s.FollowUser("alice", "bob"); err != nil {
+			t.Fatalf("FollowUser = %v, want nil", err)
+		}
+	})
+
+	t.Run("follow again does not change counters", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT 1 FROM users`).
+			WithArgs("bob").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO follows`).
+			WithArgs("alice", "bob", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		if err := s.FollowUser("alice", "bob"); err != nil {
+			t.Fatalf("FollowUser = %v, want nil", err)
+		}
+	})
+
+	t.Run("deleted or missing user", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT 1 FROM users`).
+			WithArgs("ghost").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		if err := s.FollowUser("alice", "ghost"); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("FollowUser = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("counter update failure rolls back the follow", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT 1 FROM users`).
+			WithArgs("bob").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO follows`).
+			WithArgs("alice", "bob", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`totalFollowing = totalFollowing \+ 1`).
+			WithArgs("alice").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`totalFollower = totalFollower \+ 1`).
+			WithArgs("bob").
+			WillReturnError(errors.New("deadlock detected"))
+		mock.ExpectRollback()
+
+		if err := s.FollowUser("alice", "bob"); err == nil {
+			t.Fatal("FollowUser error = nil, want error")
+		}
+	})
+}
+
+func TestUnfollowUser(t *testing.T) {
+	t.Run("unfollow updates both counters", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM follows`).
+			WithArgs("alice", "bob").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`totalFollowing = totalFollowing - 1`).
+			WithArgs("alice").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`totalFollower = totalFollower - 1`).
+			WithArgs("bob").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := s.UnfollowUser("alice", "bob"); err != nil {
+			t.Fatalf("UnfollowUser = %v, want nil", err)
+		}
+	})
+
+	t.Run("unfollow without follow does not change counters", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM follows`).
+			WithArgs("alice", "bob").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		if err := s.UnfollowUser("alice", "bob"); err != nil {
+			t.Fatalf("UnfollowUser = %v, want nil", err)
+		}
+	})
+}
+
+func TestListFollowersKeyset(t *testing.T) {
+	tests := []struct {
+		name      string
+		cursor    library.KeysetCursor
+		createdAt int64
+		id        string
+	}{
+		{"first page", library.KeysetCursor{}, 922337203685477, ""},
+		{"next page", library.KeysetCursor{CreatedAt: 1700000000, Id: "carol"}, 1700000000, "carol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockStorage(t)
+
+			mock.ExpectPrepare(`\(follows.createdAt, follows.followerId\) < \(\$2, \$3\)`).
+				ExpectQuery().
+				WithArgs("bob", tt.createdAt, tt.id, int32(2)).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "username", "name", "profile", "createdAt"}).
+					AddRow("dave", "dave", "Dave", "", int64(1699999999)).
+					AddRow("alice", "alice", "Alice", "alice.png", int64(1699999998)))
+
+			users := []FollowUser{}
+			if err := s.ListFollowers(tt.cursor, "bob", 2, &users); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(users) != 2 || users[0].Id != "dave" || users[1].FollowedAt != 1699999998 {
+				t.Errorf("ListFollowers = %+v, want dave then alice", users)
+			}
+		})
+	}
+}
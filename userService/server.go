@@ -40,7 +40,7 @@ func NewServer(listenAddr string, store *PostgresStorage, cfg AppConfig) *AppSer
 	if err != nil {
 		log.Println("Error when creating rabbitMq connection:", err)
 	}
-	defer conn.Close()
+	// conn dipakai selama server jalan, ditutup di main saat shutdown
 	rabbitMQ := library.NewRabbitMq(conn)
 	userService := NewUserService(store, rabbitMQ, imageGrpcClient)
 	userService.RegisterRoutes(routes)
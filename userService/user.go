@@ -1,11 +1,19 @@
 package main
 
+import "github.com/pewe21/library"
+
+var ErrUserNotFound = library.NotFound("user not found")
+
 type User struct {
 	Id           string `json:"id"`
 	Username     string `json:"username"`
 	Name         string `json:"name"`
 	HashPassword string `json:"hashPassword"`
 	Profile      string `json:"profile"`
+	Role         string `json:"role"`
+	SuspendedAt  int64  `json:"suspendedAt"`
+
+	TwoFactorEnabled bool `json:"twoFactorEnabled"`
 
 	CreatedAt int64       `json:"createdAt"`
 	UpdatedAt int64       `json:"updatedAt"`
@@ -13,13 +21,52 @@ type User struct {
 }
 
 type ReturnUser struct {
-	Id           string `json:"id"`
-	Username     string `json:"username"`
-	Name         string `json:"name"`
-	HashPassword string `json:"-"`
-	Profile      string `json:"profile"`
+	Id             string `json:"id"`
+	Username       string `json:"username"`
+	Name           string `json:"name"`
+	HashPassword   string `json:"-"`
+	Profile        string `json:"profile"`
+	TotalFollower  int64  `json:"totalFollower"`
+	TotalFollowing int64  `json:"totalFollowing"`
+	Role           string `json:"role"`
+	SuspendedAt    int64  `json:"suspendedAt,omitempty"`
 
 	CreatedAt int64       `json:"createdAt"`
 	UpdatedAt int64       `json:"updatedAt"`
 	DeletedAt interface{} `json:"-"`
 }
+
+type FollowUser struct {
+	Id       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Profile  string `json:"profile"`
+
+	FollowedAt int64 `json:"followedAt"`
+}
+
+// FollowingCount --> user yang di follow beserta jumlah followernya, dipakai postService
+// untuk menentukan feed fan-out-on-write atau fan-out-on-read
+type FollowingCount struct {
+	Id            string
+	TotalFollower int64
+}
+
+// DeletedUser --> user yang sudah di soft delete, hanya untuk admin
+type DeletedUser struct {
+	Id       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Profile  string `json:"profile"`
+
+	CreatedAt int64 `json:"createdAt"`
+	DeletedAt int64 `json:"deletedAt"`
+}
+
+// TwoFactor --> status 2FA TOTP user, secret nya masih terenkripsi
+type TwoFactor struct {
+	Id                     string
+	EncryptedSecret        string
+	Enabled                bool
+	RemainingRecoveryCodes int64
+}
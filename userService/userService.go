@@ -1,12 +1,13 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/pewe21/imageProto"
@@ -25,9 +26,9 @@ const defaultProfile = "1714794135-a06a41d8-6351-4dbb-9141-a7e2ace86a35.jpg"
 
 func NewUserService(store *PostgresStorage, producer *library.RabbitMq, imageGrpcClient imageProto.UserClient) *UserService {
 	return &UserService{
-		Store:    store,
-		RabbitMQ: producer,
-		// ImageGrpcClient: imageGrpcClient,
+		Store:           store,
+		RabbitMQ:        producer,
+		ImageGrpcClient: imageGrpcClient,
 	}
 }
 
@@ -44,6 +45,136 @@ func (s *UserService) RegisterRoutes(r *mux.Router) {
 
 	// v1/user/update_password
 	r.HandleFunc("/update_password", library.CreateHandler(library.JWTMiddleware(s.handleUpdateUserPassword))).Methods(http.MethodPost, http.MethodOptions)
+
+	// v1/user/{id}/follow --> follow/unfollow user {id} sebagai user dari jwt
+	r.HandleFunc("/{id}/follow", library.CreateHandler(library.JWTMiddleware(s.handleFollowUser))).Methods(http.MethodPost, http.MethodOptions)
+	r.HandleFunc("/{id}/follow", library.CreateHandler(library.JWTMiddleware(s.handleUnfollowUser))).Methods(http.MethodDelete, http.MethodOptions)
+
+	// v1/user/{id}/followers?cursor=&limit=
+	r.HandleFunc("/{id}/followers", library.CreateHandler(library.JWTMiddleware(s.handleListFollowers))).Methods(http.MethodGet, http.MethodOptions)
+
+	// v1/user/{id}/following?cursor=&limit=
+	r.HandleFunc("/{id}/following", library.CreateHandler(library.JWTMiddleware(s.handleListFollowing))).Methods(http.MethodGet, http.MethodOptions)
+
+	// v1/user/admin/{id}/suspend, v1/user/admin/{id}/restore --> khusus admin, dicatat di moderation_logs
+	r.HandleFunc("/admin/{id}/suspend", library.CreateHandler(library.JWTMiddleware(library.RequireRole(library.ROLE_ADMIN, s.handleAdminSuspendUser)))).Methods(http.MethodPost, http.MethodOptions)
+	r.HandleFunc("/admin/{id}/restore", library.CreateHandler(library.JWTMiddleware(library.RequireRole(library.ROLE_ADMIN, s.handleAdminRestoreUser)))).Methods(http.MethodPost, http.MethodOptions)
+
+	// v1/user/admin/deleted?cursor=&limit= --> list user yang sudah di soft delete, khusus admin
+	r.HandleFunc("/admin/deleted", library.CreateHandler(library.JWTMiddleware(library.RequireRole(library.ROLE_ADMIN, s.handleAdminListDeletedUsers)))).Methods(http.MethodGet, http.MethodOptions)
+}
+
+func (s *UserService) handleFollowUser(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle follow user")
+
+	vars := mux.Vars(r)
+	followingId := vars["id"]
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	followerId := principal.UserId
+
+	if followingId == followerId {
+		return http.StatusBadRequest, fmt.Errorf("cannot follow yourself")
+	}
+
+	if err := s.Store.FollowUser(followerId, followingId); err != nil {
+		log.Println("Error when following user:", err)
+		appErr := library.ClassifyPgError(err, ErrUserNotFound, nil)
+		return appErr.HttpStatus, appErr
+	}
+
+	// postService memasukkan post terakhir user yang di follow ke timeline follower. Follow sudah
+	// tersimpan, kalau publish gagal post lama nya saja yang tidak muncul di feed
+	type UserFollowedEvent struct {
+		FollowerId  string `json:"followerId"`
+		FollowingId string `json:"followingId"`
+	}
+
+	event := UserFollowedEvent{
+		FollowerId:  followerId,
+		FollowingId: followingId,
+	}
+
+	if err := s.publishEvent(r.Context(), "user.followed", event); err != nil {
+		log.Println("Error when publishing user.followed event:", err)
+	}
+
+	resp := library.NewResp("User followed!", nil)
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+func (s *UserService) handleUnfollowUser(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle unfollow user")
+
+	vars := mux.Vars(r)
+	followingId := vars["id"]
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	followerId := principal.UserId
+
+	if err := s.Store.UnfollowUser(followerId, followingId); err != nil {
+		log.Println("Error when unfollowing user:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	resp := library.NewResp("User unfollowed!", nil)
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
+}
+
+func (s *UserService) handleListFollowers(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle list followers")
+
+	return s.listFollows(w, r, s.Store.ListFollowers)
+}
+
+func (s *UserService) handleListFollowing(w http.ResponseWriter, r *http.Request) (int, error) {
+	log.Println("hit handle list following")
+
+	return s.listFollows(w, r, s.Store.ListFollowing)
+}
+
+func (s *UserService) listFollows(w http.ResponseWriter, r *http.Request, list func(library.KeysetCursor, string, int32, *[]FollowUser) error) (int, error) {
+	urlQuery := r.URL.Query()
+	cursor := library.ParseKeysetCursor(urlQuery.Get("cursor"))
+	limit := library.ParsePageLimit(urlQuery.Get("limit"))
+	vars := mux.Vars(r)
+	userId := vars["id"]
+
+	users := &[]FollowUser{}
+
+	if err := list(cursor, userId, limit, users); err != nil {
+		log.Println("Error when listing follows:", err)
+		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+	}
+
+	// cursor halaman berikutnya "followedAt_id" dari user terakhir
+	meta := struct {
+		Cursor string `json:"cursor"`
+	}{}
+
+	if len(*users) > 0 {
+		last := (*users)[len(*users)-1]
+		meta.Cursor = library.KeysetCursor{CreatedAt: last.FollowedAt, Id: last.Id}.String()
+	}
+
+	resp := library.NewResp("success", map[string]interface{}{
+		"users": users,
+		"meta":  meta,
+	})
+
+	library.WriteJson(w, http.StatusOK, resp)
+
+	return http.StatusOK, nil
 }
 
 func (s *UserService) handleDeleteUserById(w http.ResponseWriter, r *http.Request) (int, error) {
@@ -51,17 +182,32 @@ func (s *UserService) handleDeleteUserById(w http.ResponseWriter, r *http.Reques
 
 	vars := mux.Vars(r)
 	urlIdUser := vars["id"]
-	idUser := library.GetUserIdFromJWT(r)
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	idUser := principal.UserId
 
 	if urlIdUser != idUser {
 		return http.StatusUnauthorized, fmt.Errorf("Unauthorized")
 	}
 
-	if err := s.Store.DeleteUserById(idUser); err != nil {
-		if err == sql.ErrNoRows {
-			return http.StatusNotFound, fmt.Errorf("User didnot exists")
-		}
+	var profile string
+
+	if err := s.Store.DeleteUserById(idUser, &profile); err != nil {
+		log.Println("Error when deleting user:", err)
+		appErr := library.ClassifyPgError(err, ErrUserNotFound, nil)
+		return appErr.HttpStatus, appErr
+	}
 
+	// imageService melepas referensi profile image user yang dihapus
+	type UserDeletedEvent struct {
+		Id      string `json:"id"`
+		Profile string `json:"profile"`
+	}
+
+	if err := s.publishEvent(r.Context(), "user.deleted", UserDeletedEvent{Id: idUser, Profile: profile}); err != nil {
+		log.Println("Error when publishing user.deleted event:", err)
 		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
 	}
 
@@ -82,7 +228,9 @@ func (s *UserService) handleGetUserById(w http.ResponseWriter, r *http.Request)
 
 	err := s.Store.GetUserById(id, user)
 	if err != nil {
-		return http.StatusNotFound, fmt.Errorf("user did not exists/not found")
+		log.Println("Error when getting user by id", err)
+		appErr := library.ClassifyPgError(err, ErrUserNotFound, nil)
+		return appErr.HttpStatus, appErr
 	}
 
 	resp := library.NewResp("Success", map[string]interface{}{"user": user})
@@ -102,7 +250,8 @@ func (s *UserService) handleGetUserByUsername(w http.ResponseWriter, r *http.Req
 	err := s.Store.GetUserByUsername(username, user)
 	if err != nil {
 		log.Println("Error when getting user by username", err)
-		return http.StatusNotFound, fmt.Errorf("user did not exists/not found")
+		appErr := library.ClassifyPgError(err, ErrUserNotFound, nil)
+		return appErr.HttpStatus, appErr
 	}
 
 	resp := library.NewResp("Success", map[string]interface{}{"user": user})
@@ -120,7 +269,11 @@ func (s *UserService) handleUpdateUserPassword(w http.ResponseWriter, r *http.Re
 
 	log.Println("hit handle update user password")
 
-	userIdJWT := library.GetUserIdFromJWT(r)
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userIdJWT := principal.UserId
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -136,13 +289,16 @@ func (s *UserService) handleUpdateUserPassword(w http.ResponseWriter, r *http.Re
 		return http.StatusBadRequest, fmt.Errorf("invalid user detail")
 	}
 
+	v := library.NewValidator()
+	v.Field("newPassword", changePass.NewPassword, library.PasswordRules(principal.Username)...)
+	if err := v.Err(); err != nil {
+		return http.StatusBadRequest, err
+	}
+
 	if changePass.NewPassword != changePass.ConfirmNewPassword {
 		return http.StatusBadRequest, fmt.Errorf("new password didnot match with confirm new password")
 	}
 
-	/////////////////////////////
-	//TODO validasi input user//
-	///////////////////////////
 	newPassword, err := bcrypt.GenerateFromPassword([]byte(changePass.NewPassword), 12)
 	if err != nil {
 		log.Println("Error when hashing password:", err)
@@ -164,12 +320,17 @@ func (s *UserService) handleUpdateUserPassword(w http.ResponseWriter, r *http.Re
 func (s *UserService) handleUpdateUserByJWT(w http.ResponseWriter, r *http.Request) (int, error) {
 	// semua input user pake formData
 	type UserDetailChangeEvent struct {
-		Id      string `json:"id"`
-		Name    string `json:"name"`
-		Profile string `json:"profile"`
+		Id         string `json:"id"`
+		Name       string `json:"name"`
+		Profile    string `json:"profile"`
+		OldProfile string `json:"oldProfile"`
 	}
 
-	userIdJWT := library.GetUserIdFromJWT(r)
+	principal, ok := library.PrincipalFrom(r.Context())
+	if !ok {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token")
+	}
+	userIdJWT := principal.UserId
 
 	log.Println("hit handle update user name")
 
@@ -180,10 +341,13 @@ func (s *UserService) handleUpdateUserByJWT(w http.ResponseWriter, r *http.Reque
 		return http.StatusBadRequest, fmt.Errorf("invalid image/image is too big")
 	}
 
-	reqName := r.FormValue("reqName")
-	if reqName == "" {
-		log.Println("Error when getting reqName, invalid/missing form data")
-		return http.StatusBadRequest, fmt.Errorf("invalid/missing form data")
+	reqName := strings.TrimSpace(r.FormValue("reqName"))
+
+	// divalidasi sebelum upload image supaya image tidak terupload untuk request yang ditolak
+	v := library.NewValidator()
+	v.Field("reqName", reqName, library.NameRules()...)
+	if err := v.Err(); err != nil {
+		return http.StatusBadRequest, err
 	}
 
 	userData := &ReturnUser{}
@@ -202,31 +366,17 @@ func (s *UserService) handleUpdateUserByJWT(w http.ResponseWriter, r *http.Reque
 		newUserData.Profile = userData.Profile
 	} else {
 		defer file.Close()
-		bytesFile, err := io.ReadAll(file)
-		if err != nil {
-			log.Println("Error when reading file from form data:", err)
-			return http.StatusBadRequest, fmt.Errorf("invalid post detail")
-		}
-
-		createImageGrpcReq := &imageProto.CreateImageReq{
-			ImageFile: bytesFile,
-			FileName:  handler.Filename,
-		}
 
-		imageGrpcResp, err := s.ImageGrpcClient.CreateImage(r.Context(), createImageGrpcReq)
+		newUserData.Profile, err = uploadImage(r.Context(), s.ImageGrpcClient, userIdJWT, file, handler)
 		if err != nil {
-			log.Println("Error when calling createImage grpc:", err)
-			return http.StatusInternalServerError, fmt.Errorf("something went wrong")
+			log.Println("Error when calling uploadImage grpc:", err)
+			appErr := imageUploadError(err)
+			return appErr.HttpStatus, appErr
 		}
-		newUserData.Profile = imageGrpcResp.GetFilename()
 	}
 
 	log.Printf("newUserData: %+v", newUserData)
 
-	/////////////////////////////
-	//TODO validasi input user//
-	///////////////////////////
-
 	if err := s.Store.UpdateUserNameAndProfile(newUserData.Name, newUserData.Profile, userIdJWT); err != nil {
 		log.Println("Error when updating username:", err)
 		return http.StatusInternalServerError, fmt.Errorf("something went wrong")
@@ -254,9 +404,10 @@ func (s *UserService) handleUpdateUserByJWT(w http.ResponseWriter, r *http.Reque
 	}
 
 	event := UserDetailChangeEvent{
-		Id:      userIdJWT,
-		Name:    newUserData.Name,
-		Profile: newUserData.Profile,
+		Id:         userIdJWT,
+		Name:       newUserData.Name,
+		Profile:    newUserData.Profile,
+		OldProfile: userData.Profile,
 	}
 
 	publishBody, err := json.Marshal(event)
@@ -288,3 +439,43 @@ func (s *UserService) handleUpdateUserByJWT(w http.ResponseWriter, r *http.Reque
 
 	return http.StatusOK, nil
 }
+
+// publishEvent --> publish event ke userServiceExchange dengan routingKey
+func (s *UserService) publishEvent(ctx context.Context, routingKey string, event interface{}) error {
+	ch, err := s.RabbitMQ.Conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	err = ch.ExchangeDeclare(
+		"userServiceExchange",
+		"topic",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	publishBody, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return ch.PublishWithContext(
+		ctx,
+		"userServiceExchange",
+		routingKey,
+		false,
+		false,
+		amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			ContentType:  "text/plain",
+			Body:         publishBody,
+		},
+	)
+}